@@ -0,0 +1,77 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigIndexSpec defines the desired state of ConfigIndex
+type ConfigIndexSpec struct {
+	// Kind of the indexed object, ConfigMap or Secret
+	//+kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+	// Name of the ConfigMap or Secret in the index's namespace
+	Name string `json:"name"`
+	// UpdateMethod decides how the workloads are rolled to a new revision,
+	// it takes precedence over the updateMethod annotation of the ConfigMap or Secret
+	//+kubebuilder:validation:Enum=ignoreWhenShared;rollAll;sequential;manual
+	//+optional
+	UpdateMethod string `json:"updateMethod,omitempty"`
+}
+
+// ConfigIndexStatus defines the observed state of ConfigIndex
+type ConfigIndexStatus struct {
+	// CurrentVersion is the version of the revision the ConfigMap or Secret holds
+	CurrentVersion string `json:"currentVersion,omitempty"`
+	// CurrentRevision is the CustomConfigMap or CustomSecret holding CurrentVersion
+	CurrentRevision string `json:"currentRevision,omitempty"`
+	// Consumers lists the workloads whose pod template references the ConfigMap or Secret
+	Consumers []ConsumerReference `json:"consumers,omitempty"`
+}
+
+// +genclient
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:path=configindexes,shortName=cidx
+//+kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.kind`
+//+kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.currentVersion`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ConfigIndex keeps the revision bookkeeping of a ConfigMap or Secret, so that
+// the ConfigMap or Secret itself is left untouched
+type ConfigIndex struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConfigIndexSpec   `json:"spec,omitempty"`
+	Status ConfigIndexStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ConfigIndexList contains a list of ConfigIndex
+type ConfigIndexList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ConfigIndex `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ConfigIndex{}, &ConfigIndexList{})
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigRollbackSpec defines the desired state of ConfigRollback
+type ConfigRollbackSpec struct {
+	// Kind of the object to roll back, ConfigMap or Secret
+	//+kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+	// Name of the ConfigMap or Secret in the rollback's namespace
+	Name string `json:"name"`
+	// Revision to restore, either the version recorded on the revision
+	// or the name of the CustomConfigMap or CustomSecret
+	Revision string `json:"revision"`
+}
+
+// ConfigRollbackPhase is the progress of a rollback
+type ConfigRollbackPhase string
+
+const (
+	ConfigRollbackPending    ConfigRollbackPhase = "Pending"
+	ConfigRollbackInProgress ConfigRollbackPhase = "InProgress"
+	ConfigRollbackCompleted  ConfigRollbackPhase = "Completed"
+	ConfigRollbackFailed     ConfigRollbackPhase = "Failed"
+)
+
+// ConsumerRollbackStatus is the outcome of rolling a single workload
+type ConsumerRollbackStatus struct {
+	ConsumerReference `json:",inline"`
+	// Updated is true once the restored version was set on the pod template
+	Updated bool `json:"updated"`
+	// Message describes why the workload could not be updated
+	Message string `json:"message,omitempty"`
+}
+
+// ConfigRollbackStatus defines the observed state of ConfigRollback
+type ConfigRollbackStatus struct {
+	// Phase is Pending, InProgress, Completed or Failed
+	Phase ConfigRollbackPhase `json:"phase,omitempty"`
+	// RevisionName is the CustomConfigMap or CustomSecret that was restored
+	RevisionName string `json:"revisionName,omitempty"`
+	// PreviousVersion is the version that was current before the rollback
+	PreviousVersion string `json:"previousVersion,omitempty"`
+	// Version is the restored version
+	Version string `json:"version,omitempty"`
+	// Consumers lists the workloads rolled to the restored version
+	Consumers []ConsumerRollbackStatus `json:"consumers,omitempty"`
+	// Message describes the last failure
+	Message string `json:"message,omitempty"`
+	// StartedAt is the time the rollback started
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// CompletedAt is the time the rollback completed or failed
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// ObservedGeneration is the generation of the spec the status refers to
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions holds the latest observations of the rollback's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=crb
+//+kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.kind`
+//+kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.spec.revision`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ConfigRollback is the Schema for the configrollbacks API
+type ConfigRollback struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConfigRollbackSpec   `json:"spec,omitempty"`
+	Status ConfigRollbackStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ConfigRollbackList contains a list of ConfigRollback
+type ConfigRollbackList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ConfigRollback `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ConfigRollback{}, &ConfigRollbackList{})
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigRolloutSpec defines the desired state of ConfigRollout
+type ConfigRolloutSpec struct {
+	// Kind of the object whose revision is rolled out, ConfigMap or Secret
+	//+kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+	// Name of the ConfigMap or Secret in the rollout's namespace
+	Name string `json:"name"`
+	// Version rolled out to the workloads
+	Version string `json:"version"`
+	// PreviousVersion is the version the workloads ran before the rollout,
+	// restored when the rollout is rolled back
+	//+optional
+	PreviousVersion string `json:"previousVersion,omitempty"`
+	// UpdateMethod is rollAll to roll every workload at once or sequential to
+	// roll the next workload once the previous one is available
+	//+kubebuilder:validation:Enum=ignoreWhenShared;rollAll;sequential
+	//+optional
+	UpdateMethod string `json:"updateMethod,omitempty"`
+	// ProgressDeadlineSeconds is how long a workload may take to become
+	// available before the rollout halts
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default=600
+	//+optional
+	ProgressDeadlineSeconds int32 `json:"progressDeadlineSeconds,omitempty"`
+	// AutoRollback restores PreviousVersion when the rolled workloads fail
+	//+optional
+	AutoRollback *AutoRollbackPolicy `json:"autoRollback,omitempty"`
+}
+
+// AutoRollbackPolicy decides when a rollout is rolled back
+type AutoRollbackPolicy struct {
+	// MaxRestarts is how often the containers of the pods running the version
+	// may restart before the rollout is rolled back
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:default=3
+	//+optional
+	MaxRestarts int32 `json:"maxRestarts,omitempty"`
+	// WindowSeconds is how long the workloads are watched once available
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default=300
+	//+optional
+	WindowSeconds int32 `json:"windowSeconds,omitempty"`
+}
+
+// ConfigRolloutPhase is the progress of a rollout
+type ConfigRolloutPhase string
+
+const (
+	ConfigRolloutProgressing ConfigRolloutPhase = "Progressing"
+	ConfigRolloutVerifying   ConfigRolloutPhase = "Verifying"
+	ConfigRolloutCompleted   ConfigRolloutPhase = "Completed"
+	ConfigRolloutHalted      ConfigRolloutPhase = "Halted"
+	ConfigRolloutSuperseded  ConfigRolloutPhase = "Superseded"
+	ConfigRolloutRolledBack  ConfigRolloutPhase = "RolledBack"
+)
+
+// WorkloadRolloutPhase is the progress of a single workload in a rollout
+type WorkloadRolloutPhase string
+
+const (
+	WorkloadRolloutPending     WorkloadRolloutPhase = "Pending"
+	WorkloadRolloutProgressing WorkloadRolloutPhase = "Progressing"
+	WorkloadRolloutAvailable   WorkloadRolloutPhase = "Available"
+	WorkloadRolloutTimedOut    WorkloadRolloutPhase = "TimedOut"
+	WorkloadRolloutSkipped     WorkloadRolloutPhase = "Skipped"
+)
+
+// WorkloadRolloutStatus is the progress of rolling a single workload
+type WorkloadRolloutStatus struct {
+	ConsumerReference `json:",inline"`
+	// Phase is Pending, Progressing, Available, TimedOut or Skipped
+	Phase WorkloadRolloutPhase `json:"phase"`
+	// Generation is the generation of the workload once the version was set
+	// on its pod template
+	Generation int64 `json:"generation,omitempty"`
+	// StartedAt is the time the version was set on the pod template
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// AvailableAt is the time the workload became available
+	AvailableAt *metav1.Time `json:"availableAt,omitempty"`
+	// Restarts is how often the containers of the pods running the version restarted
+	Restarts int32 `json:"restarts,omitempty"`
+	// Message describes why the workload was skipped or is not progressing
+	Message string `json:"message,omitempty"`
+}
+
+// ConfigRolloutStatus defines the observed state of ConfigRollout
+type ConfigRolloutStatus struct {
+	// Phase is Progressing, Verifying, Completed, Halted, Superseded or RolledBack
+	Phase ConfigRolloutPhase `json:"phase,omitempty"`
+	// Workloads lists the progress of every workload, in the order they are rolled
+	Workloads []WorkloadRolloutStatus `json:"workloads,omitempty"`
+	// Message describes why the rollout halted or was rolled back
+	Message string `json:"message,omitempty"`
+	// RollbackName is the ConfigRollback restoring PreviousVersion
+	RollbackName string `json:"rollbackName,omitempty"`
+	// StartedAt is the time the rollout started
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// CompletedAt is the time the rollout completed, halted, was superseded or
+	// was rolled back
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// ObservedGeneration is the generation of the spec the status refers to
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions holds the latest observations of the rollout's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +genclient
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=cro
+//+kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.kind`
+//+kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.version`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ConfigRollout is the Schema for the configrollouts API. It records the
+// rollout of a revision of a ConfigMap or Secret to the workloads using it.
+type ConfigRollout struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConfigRolloutSpec   `json:"spec,omitempty"`
+	Status ConfigRolloutStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ConfigRolloutList contains a list of ConfigRollout
+type ConfigRolloutList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ConfigRollout `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ConfigRollout{}, &ConfigRolloutList{})
+}
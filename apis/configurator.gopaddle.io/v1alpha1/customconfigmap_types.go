@@ -30,16 +30,44 @@ type CustomConfigMapSpec struct {
 	BinaryData    map[string][]byte `json:"binaryData,omitempty"`
 }
 
+// ConsumerReference identifies a workload whose pod template runs a revision
+type ConsumerReference struct {
+	// Kind of the workload, e.g. Deployment or StatefulSet
+	Kind string `json:"kind"`
+	// Name of the workload in the revision's namespace
+	Name string `json:"name"`
+}
+
+// CustomConfigMapStatus defines the observed state of CustomConfigMap
+type CustomConfigMapStatus struct {
+	// Consumers lists the workloads whose pod template references this revision
+	Consumers []ConsumerReference `json:"consumers,omitempty"`
+	// Current is true when this revision holds the content of the ConfigMap
+	Current bool `json:"current,omitempty"`
+	// CreatedAt is the time this revision was recorded
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+	// SupersededAt is the time another revision replaced this one as current. It
+	// stays unset for a staged revision that has never been current
+	SupersededAt *metav1.Time `json:"supersededAt,omitempty"`
+	// Conditions holds the latest observations of the revision's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
 // +genclient
-// +genclient:noStatus
 //+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=ccm
+//+kubebuilder:printcolumn:name="ConfigMap",type=string,JSONPath=`.spec.configMapName`
+//+kubebuilder:printcolumn:name="Current",type=boolean,JSONPath=`.status.current`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // CustomConfigMap is the Schema for the customconfigmaps API
 type CustomConfigMap struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec CustomConfigMapSpec `json:"spec,omitempty"`
+	Spec   CustomConfigMapSpec   `json:"spec,omitempty"`
+	Status CustomConfigMapStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -33,16 +33,36 @@ type CustomSecretSpec struct {
 	SecretAnnotations map[string]string `json:"secretAnnotations,omitempty"`
 }
 
+// CustomSecretStatus defines the observed state of CustomSecret
+type CustomSecretStatus struct {
+	// Consumers lists the workloads whose pod template references this revision
+	Consumers []ConsumerReference `json:"consumers,omitempty"`
+	// Current is true when this revision holds the content of the Secret
+	Current bool `json:"current,omitempty"`
+	// CreatedAt is the time this revision was recorded
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+	// SupersededAt is the time another revision replaced this one as current. It
+	// stays unset for a staged revision that has never been current
+	SupersededAt *metav1.Time `json:"supersededAt,omitempty"`
+	// Conditions holds the latest observations of the revision's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
 // +genclient
-// +genclient:noStatus
 //+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=ccs
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.spec.secretName`
+//+kubebuilder:printcolumn:name="Current",type=boolean,JSONPath=`.status.current`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // CustomSecret is the Schema for the customsecrets API
 type CustomSecret struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec CustomSecretSpec `json:"spec,omitempty"`
+	Spec   CustomSecretSpec   `json:"spec,omitempty"`
+	Status CustomSecretStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
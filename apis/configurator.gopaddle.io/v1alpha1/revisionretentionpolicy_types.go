@@ -0,0 +1,101 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RevisionRetentionPolicySpec defines the desired state of RevisionRetentionPolicy
+type RevisionRetentionPolicySpec struct {
+	// KeepRevisions is how many of the newest revisions of every ConfigMap or
+	// Secret are kept
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	KeepRevisions *int32 `json:"keepRevisions,omitempty"`
+	// MaxAge prunes the revisions older than it, e.g. 720h
+	//+optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+	// MaxBytes is the total size of the data of the revisions of every ConfigMap
+	// or Secret, the oldest revisions beyond it are pruned
+	//+kubebuilder:validation:Minimum=1
+	//+optional
+	MaxBytes *int64 `json:"maxBytes,omitempty"`
+	// DryRun only reports the revisions that would be pruned in the status
+	//+optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// RevisionRetentionPolicyStatus defines the observed state of RevisionRetentionPolicy
+type RevisionRetentionPolicyStatus struct {
+	// LastRunTime is the time the policy was last enforced
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+	// Pruned lists the CustomConfigMaps and CustomSecrets pruned by the last run,
+	// or that would have been pruned in dry run
+	Pruned []PrunedRevision `json:"pruned,omitempty"`
+	// ObservedGeneration is the generation of the spec the status refers to
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions holds the latest observations of the policy's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// PrunedRevision is a revision pruned by a retention policy
+type PrunedRevision struct {
+	// Kind of the revision, CustomConfigMap or CustomSecret
+	Kind string `json:"kind"`
+	// Namespace of the revision
+	Namespace string `json:"namespace"`
+	// Name of the revision
+	Name string `json:"name"`
+	// Reason is keepRevisions, maxAge or maxBytes
+	Reason string `json:"reason"`
+}
+
+// +genclient
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=rrp
+//+kubebuilder:printcolumn:name="Keep",type=integer,JSONPath=`.spec.keepRevisions`
+//+kubebuilder:printcolumn:name="MaxAge",type=string,JSONPath=`.spec.maxAge`
+//+kubebuilder:printcolumn:name="DryRun",type=boolean,JSONPath=`.spec.dryRun`
+//+kubebuilder:printcolumn:name="LastRun",type=date,JSONPath=`.status.lastRunTime`
+
+// RevisionRetentionPolicy is the Schema for the revisionretentionpolicies API.
+// The policy named default in a namespace prunes the revision history of its
+// ConfigMaps and Secrets, the policy named default in the namespace of the
+// controller applies to the namespaces without one. Revisions still referenced
+// by a workload or its revision history are never pruned.
+type RevisionRetentionPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RevisionRetentionPolicySpec   `json:"spec,omitempty"`
+	Status RevisionRetentionPolicyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// RevisionRetentionPolicyList contains a list of RevisionRetentionPolicy
+type RevisionRetentionPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RevisionRetentionPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RevisionRetentionPolicy{}, &RevisionRetentionPolicyList{})
+}
@@ -2,24 +2,33 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	b64 "encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"regexp"
-	"strings"
 	"time"
 
 	customConfigMapv1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
 	customSecretv1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
 	client "github.com/gopaddle-io/configurator/pkg/client/clientset/versioned"
+	"github.com/gopaddle-io/configurator/pkg/podtemplate"
+	"github.com/gopaddle-io/configurator/pkg/selection"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 )
 
+//templateAnnotationsPath is the json patch path of the pod template annotations
+//of deployments, statefulsets and daemonsets
+const templateAnnotationsPath = "/spec/template/metadata/annotations"
+
 func initController() error {
 	//getting cluster config for K8s
 	var cfg *rest.Config
@@ -39,6 +48,9 @@ func initController() error {
 	}
 	if namespaceList != nil {
 		for _, ns := range namespaceList.Items {
+			if !selection.Namespace(ns.Name) {
+				continue
+			}
 			//get all deployment form the Namespace
 			deploymentList, errs := clientSet.AppsV1().Deployments(ns.Name).List(context.TODO(), metav1.ListOptions{})
 			if errs != nil {
@@ -50,389 +62,14 @@ func initController() error {
 			// if the deployment contain configMap/secret check the version of ccm and cs
 			// version available add annotation if not create new version
 			for _, deploy := range deploymentList.Items {
-
-				//check the configMap/secret
-				annotations := make(map[string]string)
-				for _, volume := range deploy.Spec.Template.Spec.Volumes {
-
-					if volume.ConfigMap != nil {
-						if deploy.Spec.Template.Annotations["ccm-"+volume.ConfigMap.Name] == "" || len(deploy.Spec.Template.Annotations) == 0 {
-
-							//get configMap
-							configmap, e := clientSet.CoreV1().ConfigMaps(ns.Name).Get(context.TODO(), volume.ConfigMap.Name, metav1.GetOptions{})
-							if e != nil {
-								klog.Errorf("Failed on getting configmap: %v", e.Error())
-								return e
-							}
-
-							if configmap.Annotations["currentCustomConfigMapVersion"] == "" || len(configmap.Annotations) == 0 {
-								//create new ccm
-								configuratorClientSet, err := client.NewForConfig(cfg)
-								if err != nil {
-									klog.Error("Error building example clientset: %s", err.Error())
-									return err
-								}
-								ccm, version := newCustomConfigMap(configmap)
-								_, er := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(ns.Name).Create(context.TODO(), ccm, metav1.CreateOptions{})
-								if er != nil {
-									return er
-								}
-								//update ccmVersion in configMap
-								if len(configmap.Annotations) == 0 {
-									confannotations := make(map[string]string)
-									confannotations["currentCustomConfigMapVersion"] = version
-									confannotations["customConfigMap-name"] = ccm.Name
-									confannotations["updateMethod"] = "ignoreWhenShared"
-									confannotations["deployments"] = deploy.Name
-									configmap.Annotations = confannotations
-								} else {
-									configmap.Annotations["currentCustomConfigMapVersion"] = version
-									configmap.Annotations["customConfigMap-name"] = ccm.Name
-									configmap.Annotations["updateMethod"] = "ignoreWhenShared"
-									if configmap.Annotations["deployments"] != "" {
-										// check that deployment name already exist in configMapName
-										annotation := configmap.Annotations["deployments"]
-										split := strings.Split(annotation, ",")
-										check := false
-										for _, s := range split {
-											if s == deploy.Name {
-												check = true
-											}
-										}
-										if !check {
-											configmap.Annotations["deployments"] = configmap.Annotations["deployments"] + "," + deploy.Name
-										}
-									} else {
-										configmap.Annotations["deployments"] = deploy.Name
-									}
-								}
-
-								_, errs := clientSet.CoreV1().ConfigMaps(ns.Name).Update(context.TODO(), configmap, metav1.UpdateOptions{})
-								if errs != nil {
-									klog.Error("configmap update failed", errs.Error())
-									return errs
-								}
-								annotations["ccm-"+volume.ConfigMap.Name] = version
-							}
-						}
-					} else if volume.Secret != nil {
-						if deploy.Spec.Template.Annotations["cs-"+volume.Secret.SecretName] == "" || len(deploy.Spec.Template.Annotations) == 0 {
-							//get secret
-							secret, e := clientSet.CoreV1().Secrets(ns.Name).Get(context.TODO(), volume.Secret.SecretName, metav1.GetOptions{})
-							if e != nil {
-								klog.Errorf("Failed on getting configmap: %v", e.Error())
-								return e
-							}
-							if secret.Annotations["currentCustomSecretVersion"] == "" || len(secret.Annotations) == 0 {
-								configuratorClientSet, err := client.NewForConfig(cfg)
-								if err != nil {
-									klog.Error("Error building example clientset: %s", err.Error())
-									return err
-								}
-								cs, version := newCustomSecret(secret)
-								cs, er := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(ns.Name).Create(context.TODO(), cs, metav1.CreateOptions{})
-								if er != nil {
-									klog.Error("Error creating customConfigmap: %v", er.Error())
-									return er
-								}
-								if len(secret.Annotations) == 0 {
-									secretannotations := make(map[string]string)
-									secretannotations["currentCustomSecretVersion"] = version
-									secretannotations["customSecret-name"] = cs.Name
-									secretannotations["updateMethod"] = "ignoreWhenShared"
-									secretannotations["deployments"] = deploy.Name
-									secret.Annotations = secretannotations
-								} else {
-									secret.Annotations["currentCustomSecretVersion"] = version
-									secret.Annotations["customSecret-name"] = cs.Name
-									secret.Annotations["updateMethod"] = "ignoreWhenShared"
-									if secret.Annotations["deployments"] != "" {
-										// check that deployment name already exist in secret
-										annotation := secret.Annotations["deployments"]
-										split := strings.Split(annotation, ",")
-										check := false
-										for _, s := range split {
-											if s == deploy.Name {
-												check = true
-											}
-										}
-										if !check {
-											secret.Annotations["deployments"] = secret.Annotations["deployments"] + "," + deploy.Name
-										}
-									} else {
-										secret.Annotations["deployments"] = deploy.Name
-									}
-								}
-								_, errs := clientSet.CoreV1().Secrets(ns.Name).Update(context.TODO(), secret, metav1.UpdateOptions{})
-								if errs != nil {
-									klog.Error("secret update failed", errs.Error())
-									return errs
-								}
-								annotations["cs-"+volume.Secret.SecretName] = version
-							}
-						}
-					}
-				}
-
-				//envfrom config and secret container resource in deployment
-				for _, container := range deploy.Spec.Template.Spec.Containers {
-					for _, env := range container.EnvFrom {
-						if env.ConfigMapRef != nil {
-							if deploy.Spec.Template.Annotations["ccm-"+env.ConfigMapRef.Name] == "" || len(deploy.Spec.Template.Annotations) == 0 {
-
-								//get configMap
-								configmap, e := clientSet.CoreV1().ConfigMaps(ns.Name).Get(context.TODO(), env.ConfigMapRef.Name, metav1.GetOptions{})
-								if e != nil {
-									klog.Errorf("Failed on getting configmap: %v", e.Error())
-									return e
-								}
-
-								if configmap.Annotations["currentCustomConfigMapVersion"] == "" || len(configmap.Annotations) == 0 {
-									//create new ccm
-									configuratorClientSet, err := client.NewForConfig(cfg)
-									if err != nil {
-										klog.Error("Error building example clientset: %s", err.Error())
-										return err
-									}
-									ccm, version := newCustomConfigMap(configmap)
-									_, er := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(ns.Name).Create(context.TODO(), ccm, metav1.CreateOptions{})
-									if er != nil {
-										return er
-									}
-									//update ccmVersion in configMap
-									if len(configmap.Annotations) == 0 {
-										confannotations := make(map[string]string)
-										confannotations["currentCustomConfigMapVersion"] = version
-										confannotations["customConfigMap-name"] = ccm.Name
-										confannotations["updateMethod"] = "ignoreWhenShared"
-										confannotations["deployments"] = deploy.Name
-										configmap.Annotations = confannotations
-									} else {
-										configmap.Annotations["currentCustomConfigMapVersion"] = version
-										configmap.Annotations["customConfigMap-name"] = ccm.Name
-										configmap.Annotations["updateMethod"] = "ignoreWhenShared"
-										if configmap.Annotations["deployments"] != "" {
-											// check that deployment name already exist in configMapName
-											annotation := configmap.Annotations["deployments"]
-											split := strings.Split(annotation, ",")
-											check := false
-											for _, s := range split {
-												if s == deploy.Name {
-													check = true
-												}
-											}
-											if !check {
-												configmap.Annotations["deployments"] = configmap.Annotations["deployments"] + "," + deploy.Name
-											}
-										} else {
-											configmap.Annotations["deployments"] = deploy.Name
-										}
-									}
-
-									_, errs := clientSet.CoreV1().ConfigMaps(ns.Name).Update(context.TODO(), configmap, metav1.UpdateOptions{})
-									if errs != nil {
-										klog.Error("configmap update failed", errs.Error())
-										return errs
-									}
-									annotations["ccm-"+env.ConfigMapRef.Name] = version
-								}
-							}
-						} else if env.SecretRef != nil {
-							if deploy.Spec.Template.Annotations["cs-"+env.SecretRef.Name] == "" || len(deploy.Spec.Template.Annotations) == 0 {
-								//get secret
-								secret, e := clientSet.CoreV1().Secrets(ns.Name).Get(context.TODO(), env.SecretRef.Name, metav1.GetOptions{})
-								if e != nil {
-									klog.Errorf("Failed on getting configmap: %v", e.Error())
-									return e
-								}
-								if secret.Annotations["currentCustomSecretVersion"] == "" || len(secret.Annotations) == 0 {
-									configuratorClientSet, err := client.NewForConfig(cfg)
-									if err != nil {
-										klog.Error("Error building example clientset: %s", err.Error())
-										return err
-									}
-									cs, version := newCustomSecret(secret)
-									cs, er := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(ns.Name).Create(context.TODO(), cs, metav1.CreateOptions{})
-									if er != nil {
-										klog.Error("Error creating customConfigmap: %v", er.Error())
-										return er
-									}
-									if len(secret.Annotations) == 0 {
-										secretannotations := make(map[string]string)
-										secretannotations["currentCustomSecretVersion"] = version
-										secretannotations["customSecret-name"] = cs.Name
-										secretannotations["updateMethod"] = "ignoreWhenShared"
-										secretannotations["deployments"] = deploy.Name
-										secret.Annotations = secretannotations
-									} else {
-										secret.Annotations["currentCustomSecretVersion"] = version
-										secret.Annotations["customSecret-name"] = cs.Name
-										secret.Annotations["updateMethod"] = "ignoreWhenShared"
-										if secret.Annotations["deployments"] != "" {
-											// check that deployment name already exist in secret
-											annotation := secret.Annotations["deployments"]
-											split := strings.Split(annotation, ",")
-											check := false
-											for _, s := range split {
-												if s == deploy.Name {
-													check = true
-												}
-											}
-											if !check {
-												secret.Annotations["deployments"] = secret.Annotations["deployments"] + "," + deploy.Name
-											}
-										} else {
-											secret.Annotations["deployments"] = deploy.Name
-										}
-									}
-									_, errs := clientSet.CoreV1().Secrets(ns.Name).Update(context.TODO(), secret, metav1.UpdateOptions{})
-									if errs != nil {
-										klog.Error("secret update failed", errs.Error())
-										return errs
-									}
-									annotations["cs-"+env.SecretRef.Name] = version
-								}
-							}
-						}
-					}
-				}
-
-				//envfrom config and secret initContainer resource in deployment
-				for _, initContainer := range deploy.Spec.Template.Spec.InitContainers {
-					for _, env := range initContainer.EnvFrom {
-						if env.ConfigMapRef != nil {
-							if deploy.Spec.Template.Annotations["ccm-"+env.ConfigMapRef.Name] == "" || len(deploy.Spec.Template.Annotations) == 0 {
-
-								//get configMap
-								configmap, e := clientSet.CoreV1().ConfigMaps(ns.Name).Get(context.TODO(), env.ConfigMapRef.Name, metav1.GetOptions{})
-								if e != nil {
-									klog.Errorf("Failed on getting configmap: %v", e.Error())
-									return e
-								}
-
-								if configmap.Annotations["currentCustomConfigMapVersion"] == "" || len(configmap.Annotations) == 0 {
-									//create new ccm
-									configuratorClientSet, err := client.NewForConfig(cfg)
-									if err != nil {
-										klog.Error("Error building example clientset: %s", err.Error())
-										return err
-									}
-									ccm, version := newCustomConfigMap(configmap)
-									_, er := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(ns.Name).Create(context.TODO(), ccm, metav1.CreateOptions{})
-									if er != nil {
-										return er
-									}
-									//update ccmVersion in configMap
-									if len(configmap.Annotations) == 0 {
-										confannotations := make(map[string]string)
-										confannotations["currentCustomConfigMapVersion"] = version
-										confannotations["customConfigMap-name"] = ccm.Name
-										confannotations["updateMethod"] = "ignoreWhenShared"
-										confannotations["deployments"] = deploy.Name
-										configmap.Annotations = confannotations
-									} else {
-										configmap.Annotations["currentCustomConfigMapVersion"] = version
-										configmap.Annotations["customConfigMap-name"] = ccm.Name
-										configmap.Annotations["updateMethod"] = "ignoreWhenShared"
-										if configmap.Annotations["deployments"] != "" {
-											// check that deployment name already exist in configMapName
-											annotation := configmap.Annotations["deployments"]
-											split := strings.Split(annotation, ",")
-											check := false
-											for _, s := range split {
-												if s == deploy.Name {
-													check = true
-												}
-											}
-											if !check {
-												configmap.Annotations["deployments"] = configmap.Annotations["deployments"] + "," + deploy.Name
-											}
-										} else {
-											configmap.Annotations["deployments"] = deploy.Name
-										}
-									}
-
-									_, errs := clientSet.CoreV1().ConfigMaps(ns.Name).Update(context.TODO(), configmap, metav1.UpdateOptions{})
-									if errs != nil {
-										klog.Error("configmap update failed", errs.Error())
-										return errs
-									}
-									annotations["ccm-"+env.ConfigMapRef.Name] = version
-								}
-							}
-						} else if env.SecretRef != nil {
-							if deploy.Spec.Template.Annotations["cs-"+env.SecretRef.Name] == "" || len(deploy.Spec.Template.Annotations) == 0 {
-								//get secret
-								secret, e := clientSet.CoreV1().Secrets(ns.Name).Get(context.TODO(), env.SecretRef.Name, metav1.GetOptions{})
-								if e != nil {
-									klog.Errorf("Failed on getting configmap: %v", e.Error())
-									return e
-								}
-								if secret.Annotations["currentCustomSecretVersion"] == "" || len(secret.Annotations) == 0 {
-									configuratorClientSet, err := client.NewForConfig(cfg)
-									if err != nil {
-										klog.Error("Error building example clientset: %s", err.Error())
-										return err
-									}
-									cs, version := newCustomSecret(secret)
-									cs, er := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(ns.Name).Create(context.TODO(), cs, metav1.CreateOptions{})
-									if er != nil {
-										klog.Error("Error creating customConfigmap: %v", er.Error())
-										return er
-									}
-									if len(secret.Annotations) == 0 {
-										secretannotations := make(map[string]string)
-										secretannotations["currentCustomSecretVersion"] = version
-										secretannotations["customSecret-name"] = cs.Name
-										secretannotations["updateMethod"] = "ignoreWhenShared"
-										secretannotations["deployments"] = deploy.Name
-										secret.Annotations = secretannotations
-									} else {
-										secret.Annotations["currentCustomSecretVersion"] = version
-										secret.Annotations["customSecret-name"] = cs.Name
-										secret.Annotations["updateMethod"] = "ignoreWhenShared"
-										if secret.Annotations["deployments"] != "" {
-											// check that deployment name already exist in secret
-											annotation := secret.Annotations["deployments"]
-											split := strings.Split(annotation, ",")
-											check := false
-											for _, s := range split {
-												if s == deploy.Name {
-													check = true
-												}
-											}
-											if !check {
-												secret.Annotations["deployments"] = secret.Annotations["deployments"] + "," + deploy.Name
-											}
-										} else {
-											secret.Annotations["deployments"] = deploy.Name
-										}
-									}
-									_, errs := clientSet.CoreV1().Secrets(ns.Name).Update(context.TODO(), secret, metav1.UpdateOptions{})
-									if errs != nil {
-										klog.Error("secret update failed", errs.Error())
-										return errs
-									}
-									annotations["cs-"+env.SecretRef.Name] = version
-								}
-							}
-						}
-					}
-				}
-
-				if len(deploy.Spec.Template.Annotations) == 0 {
-					deploy.Spec.Template.Annotations = annotations
-				} else {
-					if len(annotations) != 0 {
-						for key, value := range annotations {
-							deploy.Spec.Template.Annotations[key] = value
-						}
-					}
+				patch, err := pinTemplate(clientSet, cfg, ns.Name, &deploy.Spec.Template)
+				if err != nil {
+					return err
 				}
 
 				//update deployment
-				if len(annotations) != 0 {
-					_, err := clientSet.AppsV1().Deployments(ns.Name).Update(context.TODO(), &deploy, metav1.UpdateOptions{})
+				if patch != nil {
+					_, err := clientSet.AppsV1().Deployments(ns.Name).Patch(context.TODO(), deploy.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
 					if err != nil {
 						klog.Errorf("Failed on updating deployment with annotation: %v", err.Error())
 						return err
@@ -451,399 +88,237 @@ func initController() error {
 			// if the statefulset contain configMap/secret check the version of ccm and cs
 			// version available add annotation if not create new version
 			for _, sts := range stsList.Items {
-
-				stsAnnotation := make(map[string]string)
-				for _, volume := range sts.Spec.Template.Spec.Volumes {
-					if volume.ConfigMap != nil {
-						if sts.Spec.Template.Annotations["ccm-"+volume.ConfigMap.Name] == "" || len(sts.Spec.Template.Annotations) == 0 {
-							//get configMap
-							configmap, e := clientSet.CoreV1().ConfigMaps(ns.Name).Get(context.TODO(), volume.ConfigMap.Name, metav1.GetOptions{})
-							if e != nil {
-								klog.Errorf("Failed on getting configmap: %v", e.Error())
-								return e
-							}
-
-							if configmap.Annotations["currentCustomConfigMapVersion"] == "" || len(configmap.Annotations) == 0 {
-								//create new ccm
-								configuratorClientSet, err := client.NewForConfig(cfg)
-								if err != nil {
-									klog.Error("Error building example clientset: %s", err.Error())
-									return err
-								}
-								ccm, version := newCustomConfigMap(configmap)
-								_, er := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(ns.Name).Create(context.TODO(), ccm, metav1.CreateOptions{})
-								if er != nil {
-									return er
-								}
-								//update ccmVersion in configMap
-								if len(configmap.Annotations) == 0 {
-									confannotations := make(map[string]string)
-									confannotations["currentCustomConfigMapVersion"] = version
-									confannotations["customConfigMap-name"] = ccm.Name
-									confannotations["updateMethod"] = "ignoreWhenShared"
-									confannotations["statefulsets"] = sts.Name
-									configmap.Annotations = confannotations
-								} else {
-									configmap.Annotations["currentCustomConfigMapVersion"] = version
-									configmap.Annotations["customConfigMap-name"] = ccm.Name
-									configmap.Annotations["updateMethod"] = "ignoreWhenShared"
-									if configmap.Annotations["statefulsets"] != "" {
-										// check that deployment name already exist in configMapName
-										annotation := configmap.Annotations["statefulsets"]
-										split := strings.Split(annotation, ",")
-										check := false
-										for _, s := range split {
-											if s == sts.Name {
-												check = true
-											}
-										}
-										if !check {
-											configmap.Annotations["statefulsets"] = configmap.Annotations["statefulsets"] + "," + sts.Name
-										}
-									} else {
-										configmap.Annotations["statefulsets"] = sts.Name
-									}
-								}
-								_, errs := clientSet.CoreV1().ConfigMaps(ns.Name).Update(context.TODO(), configmap, metav1.UpdateOptions{})
-								if errs != nil {
-									klog.Error("configmap update failed", errs.Error())
-									return errs
-								}
-								stsAnnotation["ccm-"+volume.ConfigMap.Name] = version
-							}
-						}
-					} else if volume.Secret != nil {
-						if sts.Spec.Template.Annotations["cs-"+volume.Secret.SecretName] == "" || len(sts.Spec.Template.Annotations) == 0 {
-							//get secret
-							secret, e := clientSet.CoreV1().Secrets(ns.Name).Get(context.TODO(), volume.Secret.SecretName, metav1.GetOptions{})
-							if e != nil {
-								klog.Errorf("Failed on getting configmap: %v", e.Error())
-								return e
-							}
-							if secret.Annotations["currentCustomSecretVersion"] == "" || len(secret.Annotations) == 0 {
-								configuratorClientSet, err := client.NewForConfig(cfg)
-								if err != nil {
-									klog.Error("Error building example clientset: %s", err.Error())
-									return err
-								}
-								cs, version := newCustomSecret(secret)
-								cs, er := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(ns.Name).Create(context.TODO(), cs, metav1.CreateOptions{})
-								if er != nil {
-									klog.Error("Error creating customConfigmap: %v", er.Error())
-									return er
-								}
-
-								if len(secret.Annotations) == 0 {
-									secretannotations := make(map[string]string)
-									secretannotations["currentCustomSecretVersion"] = version
-									secretannotations["customSecret-name"] = cs.Name
-									secretannotations["updateMethod"] = "ignoreWhenShared"
-									secretannotations["statefulsets"] = sts.Name
-									secret.Annotations = secretannotations
-								} else {
-									secret.Annotations["currentCustomSecretVersion"] = version
-									secret.Annotations["customSecret-name"] = cs.Name
-									secret.Annotations["updateMethod"] = "ignoreWhenShared"
-									if secret.Annotations["statefulsets"] != "" {
-										// check that deployment name already exist in secret
-										annotation := secret.Annotations["statefulsets"]
-										split := strings.Split(annotation, ",")
-										check := false
-										for _, s := range split {
-											if s == sts.Name {
-												check = true
-											}
-										}
-										if !check {
-											secret.Annotations["statefulsets"] = secret.Annotations["statefulsets"] + "," + sts.Name
-										}
-									} else {
-										secret.Annotations["statefulsets"] = sts.Name
-									}
-								}
-								_, errs := clientSet.CoreV1().Secrets(ns.Name).Update(context.TODO(), secret, metav1.UpdateOptions{})
-								if errs != nil {
-									klog.Error("secret update failed", errs.Error())
-									return errs
-								}
-								stsAnnotation["cs-"+volume.Secret.SecretName] = version
-							}
-						}
-					}
+				patch, err := pinTemplate(clientSet, cfg, ns.Name, &sts.Spec.Template)
+				if err != nil {
+					return err
 				}
 
-				//envfrom config and secret container resource in statefulset
-				for _, container := range sts.Spec.Template.Spec.Containers {
-					for _, env := range container.EnvFrom {
-						if env.ConfigMapRef != nil {
-							if sts.Spec.Template.Annotations["ccm-"+env.ConfigMapRef.Name] == "" || len(sts.Spec.Template.Annotations) == 0 {
-
-								//get configMap
-								configmap, e := clientSet.CoreV1().ConfigMaps(ns.Name).Get(context.TODO(), env.ConfigMapRef.Name, metav1.GetOptions{})
-								if e != nil {
-									klog.Errorf("Failed on getting configmap: %v", e.Error())
-									return e
-								}
-
-								if configmap.Annotations["currentCustomConfigMapVersion"] == "" || len(configmap.Annotations) == 0 {
-									//create new ccm
-									configuratorClientSet, err := client.NewForConfig(cfg)
-									if err != nil {
-										klog.Error("Error building example clientset: %s", err.Error())
-										return err
-									}
-									ccm, version := newCustomConfigMap(configmap)
-									_, er := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(ns.Name).Create(context.TODO(), ccm, metav1.CreateOptions{})
-									if er != nil {
-										return er
-									}
-									//update ccmVersion in configMap
-									if len(configmap.Annotations) == 0 {
-										confannotations := make(map[string]string)
-										confannotations["currentCustomConfigMapVersion"] = version
-										confannotations["customConfigMap-name"] = ccm.Name
-										confannotations["updateMethod"] = "ignoreWhenShared"
-										confannotations["statefulsets"] = sts.Name
-										configmap.Annotations = confannotations
-									} else {
-										configmap.Annotations["currentCustomConfigMapVersion"] = version
-										configmap.Annotations["customConfigMap-name"] = ccm.Name
-										configmap.Annotations["updateMethod"] = "ignoreWhenShared"
-										if configmap.Annotations["statefulsets"] != "" {
-											// check that deployment name already exist in configMapName
-											annotation := configmap.Annotations["statefulsets"]
-											split := strings.Split(annotation, ",")
-											check := false
-											for _, s := range split {
-												if s == sts.Name {
-													check = true
-												}
-											}
-											if !check {
-												configmap.Annotations["statefulsets"] = configmap.Annotations["statefulsets"] + "," + sts.Name
-											}
-										} else {
-											configmap.Annotations["statefulsets"] = sts.Name
-										}
-									}
-
-									_, errs := clientSet.CoreV1().ConfigMaps(ns.Name).Update(context.TODO(), configmap, metav1.UpdateOptions{})
-									if errs != nil {
-										klog.Error("configmap update failed", errs.Error())
-										return errs
-									}
-									stsAnnotation["ccm-"+env.ConfigMapRef.Name] = version
-								}
-							}
-						} else if env.SecretRef != nil {
-							if sts.Spec.Template.Annotations["cs-"+env.SecretRef.Name] == "" || len(sts.Spec.Template.Annotations) == 0 {
-								//get secret
-								secret, e := clientSet.CoreV1().Secrets(ns.Name).Get(context.TODO(), env.SecretRef.Name, metav1.GetOptions{})
-								if e != nil {
-									klog.Errorf("Failed on getting configmap: %v", e.Error())
-									return e
-								}
-								if secret.Annotations["currentCustomSecretVersion"] == "" || len(secret.Annotations) == 0 {
-									configuratorClientSet, err := client.NewForConfig(cfg)
-									if err != nil {
-										klog.Error("Error building example clientset: %s", err.Error())
-										return err
-									}
-									cs, version := newCustomSecret(secret)
-									cs, er := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(ns.Name).Create(context.TODO(), cs, metav1.CreateOptions{})
-									if er != nil {
-										klog.Error("Error creating customConfigmap: %v", er.Error())
-										return er
-									}
-									if len(secret.Annotations) == 0 {
-										secretannotations := make(map[string]string)
-										secretannotations["currentCustomSecretVersion"] = version
-										secretannotations["customSecret-name"] = cs.Name
-										secretannotations["updateMethod"] = "ignoreWhenShared"
-										secretannotations["statefulsets"] = sts.Name
-										secret.Annotations = secretannotations
-									} else {
-										secret.Annotations["currentCustomSecretVersion"] = version
-										secret.Annotations["customSecret-name"] = cs.Name
-										secret.Annotations["updateMethod"] = "ignoreWhenShared"
-										if secret.Annotations["statefulsets"] != "" {
-											// check that deployment name already exist in secret
-											annotation := secret.Annotations["statefulsets"]
-											split := strings.Split(annotation, ",")
-											check := false
-											for _, s := range split {
-												if s == sts.Name {
-													check = true
-												}
-											}
-											if !check {
-												secret.Annotations["statefulsets"] = secret.Annotations["statefulsets"] + "," + sts.Name
-											}
-										} else {
-											secret.Annotations["statefulsets"] = sts.Name
-										}
-									}
-									_, errs := clientSet.CoreV1().Secrets(ns.Name).Update(context.TODO(), secret, metav1.UpdateOptions{})
-									if errs != nil {
-										klog.Error("secret update failed", errs.Error())
-										return errs
-									}
-									stsAnnotation["cs-"+env.SecretRef.Name] = version
-								}
-							}
-						}
+				//update statefulset
+				if patch != nil {
+					_, err := clientSet.AppsV1().StatefulSets(ns.Name).Patch(context.TODO(), sts.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+					if err != nil {
+						klog.Errorf("Failed on updating statefulset with annotation: %v", err.Error())
+						return err
 					}
 				}
+			}
 
-				//envfrom config and secret initContainer resource in statefulset
-				for _, initContainer := range sts.Spec.Template.Spec.InitContainers {
-					for _, env := range initContainer.EnvFrom {
-						if env.ConfigMapRef != nil {
-							if sts.Spec.Template.Annotations["ccm-"+env.ConfigMapRef.Name] == "" || len(sts.Spec.Template.Annotations) == 0 {
-
-								//get configMap
-								configmap, e := clientSet.CoreV1().ConfigMaps(ns.Name).Get(context.TODO(), env.ConfigMapRef.Name, metav1.GetOptions{})
-								if e != nil {
-									klog.Errorf("Failed on getting configmap: %v", e.Error())
-									return e
-								}
-
-								if configmap.Annotations["currentCustomConfigMapVersion"] == "" || len(configmap.Annotations) == 0 {
-									//create new ccm
-									configuratorClientSet, err := client.NewForConfig(cfg)
-									if err != nil {
-										klog.Error("Error building example clientset: %s", err.Error())
-										return err
-									}
-									ccm, version := newCustomConfigMap(configmap)
-									_, er := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(ns.Name).Create(context.TODO(), ccm, metav1.CreateOptions{})
-									if er != nil {
-										return er
-									}
-									//update ccmVersion in configMap
-									if len(configmap.Annotations) == 0 {
-										confannotations := make(map[string]string)
-										confannotations["currentCustomConfigMapVersion"] = version
-										confannotations["customConfigMap-name"] = ccm.Name
-										confannotations["updateMethod"] = "ignoreWhenShared"
-										confannotations["statefulsets"] = sts.Name
-										configmap.Annotations = confannotations
-									} else {
-										configmap.Annotations["currentCustomConfigMapVersion"] = version
-										configmap.Annotations["customConfigMap-name"] = ccm.Name
-										configmap.Annotations["updateMethod"] = "ignoreWhenShared"
-										if configmap.Annotations["statefulsets"] != "" {
-											// check that deployment name already exist in configMapName
-											annotation := configmap.Annotations["statefulsets"]
-											split := strings.Split(annotation, ",")
-											check := false
-											for _, s := range split {
-												if s == sts.Name {
-													check = true
-												}
-											}
-											if !check {
-												configmap.Annotations["statefulsets"] = configmap.Annotations["statefulsets"] + "," + sts.Name
-											}
-										} else {
-											configmap.Annotations["statefulsets"] = sts.Name
-										}
-									}
-
-									_, errs := clientSet.CoreV1().ConfigMaps(ns.Name).Update(context.TODO(), configmap, metav1.UpdateOptions{})
-									if errs != nil {
-										klog.Error("configmap update failed", errs.Error())
-										return errs
-									}
-									stsAnnotation["ccm-"+env.ConfigMapRef.Name] = version
-								}
-							}
-						} else if env.SecretRef != nil {
-							if sts.Spec.Template.Annotations["cs-"+env.SecretRef.Name] == "" || len(sts.Spec.Template.Annotations) == 0 {
-								//get secret
-								secret, e := clientSet.CoreV1().Secrets(ns.Name).Get(context.TODO(), env.SecretRef.Name, metav1.GetOptions{})
-								if e != nil {
-									klog.Errorf("Failed on getting configmap: %v", e.Error())
-									return e
-								}
-								if secret.Annotations["currentCustomSecretVersion"] == "" || len(secret.Annotations) == 0 {
-									configuratorClientSet, err := client.NewForConfig(cfg)
-									if err != nil {
-										klog.Error("Error building example clientset: %s", err.Error())
-										return err
-									}
-									cs, version := newCustomSecret(secret)
-									cs, er := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(ns.Name).Create(context.TODO(), cs, metav1.CreateOptions{})
-									if er != nil {
-										klog.Error("Error creating customConfigmap: %v", er.Error())
-										return er
-									}
-									if len(secret.Annotations) == 0 {
-										secretannotations := make(map[string]string)
-										secretannotations["currentCustomSecretVersion"] = version
-										secretannotations["customSecret-name"] = cs.Name
-										secretannotations["updateMethod"] = "ignoreWhenShared"
-										secretannotations["statefulsets"] = sts.Name
-										secret.Annotations = secretannotations
-									} else {
-										secret.Annotations["currentCustomSecretVersion"] = version
-										secret.Annotations["customSecret-name"] = cs.Name
-										secret.Annotations["updateMethod"] = "ignoreWhenShared"
-										if secret.Annotations["statefulsets"] != "" {
-											// check that deployment name already exist in secret
-											annotation := secret.Annotations["statefulsets"]
-											split := strings.Split(annotation, ",")
-											check := false
-											for _, s := range split {
-												if s == sts.Name {
-													check = true
-												}
-											}
-											if !check {
-												secret.Annotations["statefulsets"] = secret.Annotations["statefulsets"] + "," + sts.Name
-											}
-										} else {
-											secret.Annotations["statefulsets"] = sts.Name
-										}
-									}
-									_, errs := clientSet.CoreV1().Secrets(ns.Name).Update(context.TODO(), secret, metav1.UpdateOptions{})
-									if errs != nil {
-										klog.Error("secret update failed", errs.Error())
-										return errs
-									}
-									stsAnnotation["cs-"+env.SecretRef.Name] = version
-								}
-							}
-						}
-					}
-				}
+			//get all daemonset form the Namespace
+			dsList, errs := clientSet.AppsV1().DaemonSets(ns.Name).List(context.TODO(), metav1.ListOptions{})
+			if errs != nil {
+				klog.Errorf("Failed on listing daemonset: %v", errs.Error())
+				return errs
+			}
 
-				if len(sts.Spec.Template.Annotations) == 0 {
-					sts.Spec.Template.Annotations = stsAnnotation
-				} else {
-					if len(stsAnnotation) != 0 {
-						for key, value := range stsAnnotation {
-							sts.Spec.Template.Annotations[key] = value
-						}
-					}
+			// check the daemonset contain configMap/secret
+			// if the daemonset contain configMap/secret check the version of ccm and cs
+			// version available add annotation if not create new version
+			for _, ds := range dsList.Items {
+				patch, err := pinTemplate(clientSet, cfg, ns.Name, &ds.Spec.Template)
+				if err != nil {
+					return err
 				}
 
-				//update statefulset
-				if len(stsAnnotation) != 0 {
-					_, err := clientSet.AppsV1().StatefulSets(ns.Name).Update(context.TODO(), &sts, metav1.UpdateOptions{})
+				//update daemonset
+				if patch != nil {
+					_, err := clientSet.AppsV1().DaemonSets(ns.Name).Patch(context.TODO(), ds.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
 					if err != nil {
-						klog.Errorf("Failed on updating statefulset with annotation: %v", err.Error())
+						klog.Errorf("Failed on updating daemonset with annotation: %v", err.Error())
 						return err
 					}
 				}
-
 			}
-
 		}
 	}
 	return nil
 }
 
+//pinTemplate versions the configMaps and secrets referenced by a pod template that
+//don't have a revision yet, and returns the json patch pinning the template to
+//them, nil when there is nothing to pin
+func pinTemplate(clientSet *kubernetes.Clientset, cfg *rest.Config, namespace string, template *corev1.PodTemplateSpec) ([]byte, error) {
+	added, err := annotateTemplate(clientSet, cfg, namespace, template)
+	if err != nil || len(added) == 0 {
+		return nil, err
+	}
+	annotations := make(map[string]string)
+	for key, value := range template.Annotations {
+		annotations[key] = value
+	}
+	for key, value := range added {
+		annotations[key] = value
+	}
+	return json.Marshal(podtemplate.Patch(template, templateAnnotationsPath, annotations, nil, nil))
+}
+
+//annotateTemplate versions the configMaps and secrets referenced by a pod template
+//that don't have a revision yet, and returns the annotations to add to the template
+func annotateTemplate(clientSet *kubernetes.Clientset, cfg *rest.Config, namespace string, template *corev1.PodTemplateSpec) (map[string]string, error) {
+	annotations := make(map[string]string)
+	configMaps, secrets := podtemplate.References(template)
+	for _, name := range configMaps {
+		if template.Annotations["ccm-"+name] != "" || annotations["ccm-"+name] != "" {
+			continue
+		}
+		version, err := versionConfigMap(clientSet, cfg, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		if version != "" {
+			annotations["ccm-"+name] = version
+		}
+	}
+	for _, name := range secrets {
+		if template.Annotations["cs-"+name] != "" || annotations["cs-"+name] != "" {
+			continue
+		}
+		version, err := versionSecret(clientSet, cfg, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		if version != "" {
+			annotations["cs-"+name] = version
+		}
+	}
+	return annotations, nil
+}
+
+//versionConfigMap creates the first customConfigMap of a configMap. It returns an
+//empty version when the configMap already has a revision.
+func versionConfigMap(clientSet *kubernetes.Clientset, cfg *rest.Config, namespace string, name string) (string, error) {
+	//get configMap
+	configmap, e := clientSet.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if e != nil {
+		klog.Errorf("Failed on getting configmap: %v", e.Error())
+		return "", e
+	}
+	if configmap.Annotations["currentCustomConfigMapVersion"] != "" || !selection.ConfigMap(configmap) {
+		return "", nil
+	}
+
+	//create new ccm
+	configuratorClientSet, err := client.NewForConfig(cfg)
+	if err != nil {
+		klog.Error("Error building example clientset: %s", err.Error())
+		return "", err
+	}
+	if bookkeeping == "index" {
+		indexed, err := hasConfigIndex(configuratorClientSet, namespace, "configmap-"+name)
+		if err != nil || indexed {
+			return "", err
+		}
+	}
+	ccm, version := newCustomConfigMap(configmap)
+	_, er := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(namespace).Create(context.TODO(), ccm, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(er) {
+		//reuse the recorded revision only when it holds the same content
+		existing, err := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(namespace).Get(context.TODO(), ccm.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		if !equality.Semantic.DeepEqual(existing.Spec.Data, ccm.Spec.Data) || !equality.Semantic.DeepEqual(existing.Spec.BinaryData, ccm.Spec.BinaryData) {
+			return "", fmt.Errorf("customConfigMap %s/%s holds another content than configMap %s", namespace, ccm.Name, name)
+		}
+		er = nil
+	}
+	if er != nil {
+		return "", er
+	}
+	if bookkeeping == "index" {
+		//the controller records the current customConfigMap in the ConfigIndex
+		return version, nil
+	}
+	//update ccmVersion in configMap
+	if len(configmap.Annotations) == 0 {
+		configmap.Annotations = make(map[string]string)
+	}
+	configmap.Annotations["currentCustomConfigMapVersion"] = version
+	configmap.Annotations["customConfigMap-name"] = ccm.Name
+	//keep an updateMethod set by the user
+	if configmap.Annotations["updateMethod"] == "" {
+		configmap.Annotations["updateMethod"] = "ignoreWhenShared"
+	}
+
+	_, errs := clientSet.CoreV1().ConfigMaps(namespace).Update(context.TODO(), configmap, metav1.UpdateOptions{})
+	if errs != nil {
+		klog.Error("configmap update failed", errs.Error())
+		return "", errs
+	}
+	return version, nil
+}
+
+//versionSecret creates the first customSecret of a secret. It returns an empty
+//version when the secret already has a revision.
+func versionSecret(clientSet *kubernetes.Clientset, cfg *rest.Config, namespace string, name string) (string, error) {
+	//get secret
+	secret, e := clientSet.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if e != nil {
+		klog.Errorf("Failed on getting secret: %v", e.Error())
+		return "", e
+	}
+	if secret.Annotations["currentCustomSecretVersion"] != "" || !selection.Secret(secret) {
+		return "", nil
+	}
+
+	configuratorClientSet, err := client.NewForConfig(cfg)
+	if err != nil {
+		klog.Error("Error building example clientset: %s", err.Error())
+		return "", err
+	}
+	if bookkeeping == "index" {
+		indexed, err := hasConfigIndex(configuratorClientSet, namespace, "secret-"+name)
+		if err != nil || indexed {
+			return "", err
+		}
+	}
+	cs, version := newCustomSecret(secret)
+	_, er := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(namespace).Create(context.TODO(), cs, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(er) {
+		//reuse the recorded revision only when it holds the same content
+		existing, err := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(namespace).Get(context.TODO(), cs.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		if !equality.Semantic.DeepEqual(existing.Spec.Data, cs.Spec.Data) || existing.Spec.Type != cs.Spec.Type || !equality.Semantic.DeepEqual(existing.Spec.SecretAnnotations, cs.Spec.SecretAnnotations) {
+			return "", fmt.Errorf("customSecret %s/%s holds another content than secret %s", namespace, cs.Name, name)
+		}
+		er = nil
+	}
+	if er != nil {
+		klog.Error("Error creating customSecret: %v", er.Error())
+		return "", er
+	}
+	if bookkeeping == "index" {
+		//the controller records the current customSecret in the ConfigIndex
+		return version, nil
+	}
+	if len(secret.Annotations) == 0 {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations["currentCustomSecretVersion"] = version
+	secret.Annotations["customSecret-name"] = cs.Name
+	//keep an updateMethod set by the user
+	if secret.Annotations["updateMethod"] == "" {
+		secret.Annotations["updateMethod"] = "ignoreWhenShared"
+	}
+
+	_, errs := clientSet.CoreV1().Secrets(namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+	if errs != nil {
+		klog.Error("secret update failed", errs.Error())
+		return "", errs
+	}
+	return version, nil
+}
+
+//hasConfigIndex reports whether the controller already keeps a ConfigIndex of that name
+func hasConfigIndex(configuratorClientSet *client.Clientset, namespace string, name string) (bool, error) {
+	_, err := configuratorClientSet.ConfiguratorV1alpha1().ConfigIndexes(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func newCustomConfigMap(configmap *corev1.ConfigMap) (*customConfigMapv1alpha1.CustomConfigMap, string) {
 	labels := map[string]string{
 		"name":    configmap.Name,
@@ -851,7 +326,7 @@ func newCustomConfigMap(configmap *corev1.ConfigMap) (*customConfigMapv1alpha1.C
 		"current": "true",
 	}
 
-	version := RandomSequence(5)
+	version := configMapVersion(configmap)
 	name := fmt.Sprintf("%s-%s", configmap.Name, version)
 	configName := NameValidation(name)
 	annotation := map[string]string{
@@ -885,13 +360,6 @@ func newCustomSecret(secret *corev1.Secret) (*customSecretv1alpha1.CustomSecret,
 		"latest":  "true",
 		"current": "true",
 	}
-	version := RandomSequence(5)
-	name := fmt.Sprintf("%s-%s", secret.Name, version)
-	secretName := NameValidation(name)
-	annotation := map[string]string{
-		"customSecretVersion": version,
-	}
-
 	//coverting stringdata into data
 	var data = make(map[string][]byte)
 	for k, v := range secret.StringData {
@@ -914,8 +382,21 @@ func newCustomSecret(secret *corev1.Secret) (*customSecretv1alpha1.CustomSecret,
 	delete(secretAnnotation, "currentCustomSecretVersion")
 	delete(secretAnnotation, "customSecret-name")
 	delete(secretAnnotation, "updateMethod")
+	delete(secretAnnotation, "autoRollback")
+	delete(secretAnnotation, "autoRollbackMaxRestarts")
+	delete(secretAnnotation, "autoRollbackWindow")
+	delete(secretAnnotation, "debounce")
 	delete(secretAnnotation, "deployments")
 	delete(secretAnnotation, "statefulsets")
+	delete(secretAnnotation, "daemonsets")
+	delete(secretAnnotation, "cronjobs")
+
+	version := secretVersion(data, secret.Type, secretAnnotation)
+	name := fmt.Sprintf("%s-%s", secret.Name, version)
+	secretName := NameValidation(name)
+	annotation := map[string]string{
+		"customSecretVersion": version,
+	}
 	cs := &customSecretv1alpha1.CustomSecret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      secretName,
@@ -936,14 +417,38 @@ func newCustomSecret(secret *corev1.Secret) (*customSecretv1alpha1.CustomSecret,
 	return cs, version
 }
 
-func RandomSequence(n int) string {
-	var letters = []rune("abcdefghijklmnopqrstuvwxyz0123456789")
-	rand.Seed(time.Now().UTC().UnixNano())
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
+//configMapVersion returns a stable version for the content of the configMap
+func configMapVersion(configmap *corev1.ConfigMap) string {
+	content, _ := json.Marshal(struct {
+		Data       map[string]string `json:"data,omitempty"`
+		BinaryData map[string][]byte `json:"binaryData,omitempty"`
+	}{
+		Data:       configmap.Data,
+		BinaryData: configmap.BinaryData,
+	})
+	return contentHash(content)
+}
+
+//secretVersion returns a stable version for the data, type and annotations of a secret
+func secretVersion(data map[string][]byte, secretType corev1.SecretType, annotations map[string]string) string {
+	content, _ := json.Marshal(struct {
+		Data        map[string][]byte `json:"data,omitempty"`
+		Type        corev1.SecretType `json:"type,omitempty"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+	}{
+		Data:        data,
+		Type:        secretType,
+		Annotations: annotations,
+	})
+	return contentHash(content)
+}
+
+//contentHash hashes the content into a string that is safe to use in resource
+//names. The version names the revision holding the content, it is the sha256 of
+//the content truncated to 64 bits so that distinct contents don't share a revision.
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
 }
 
 func NameValidation(name string) string {
@@ -11,6 +11,7 @@ require (
 )
 
 replace (
+	github.com/gopaddle-io/configurator => ../
 	k8s.io/api => k8s.io/api v0.0.0-20210115125903-c873f2e8ab25
 	k8s.io/apimachinery => k8s.io/apimachinery v0.0.0-20210116005712-af2ce7e24233
 	k8s.io/client-go => k8s.io/client-go v0.0.0-20210114130407-537eda74d850
@@ -1,12 +1,31 @@
 package main
 
 import (
+	"flag"
 	"os"
 
+	"github.com/gopaddle-io/configurator/pkg/selection"
 	"k8s.io/klog/v2"
 )
 
+//bookkeeping is where the controller keeps the current revision of configMaps
+//and secrets, annotations on them or index for ConfigIndex objects
+var bookkeeping string
+
 func main() {
+	flag.StringVar(&bookkeeping, "bookkeeping", "annotations", "Where the controller keeps the current revision of configMaps and secrets, annotations or index.")
+	var selectionConfig string
+	flag.StringVar(&selectionConfig, "selection-config", "", "The file selecting the configMaps and secrets to version.")
+	flag.Parse()
+
+	if selectionConfig != "" {
+		config, err := selection.LoadConfig(selectionConfig)
+		if err != nil {
+			klog.Errorf("Failed on loading selection config: %v", err.Error())
+			os.Exit(1)
+		}
+		selection.Set(config)
+	}
 
 	er := initController()
 	if er != nil {
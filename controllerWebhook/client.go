@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	configuratorv1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	clientset "github.com/gopaddle-io/configurator/pkg/client/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+//LookupTimeout bounds the reads going to the API server while the informer caches
+//are not synced yet, or when an object is not in the cache yet
+var LookupTimeout = 2 * time.Second
+
+//Clients is the single client of the webhook server. Admission decisions are
+//served from the informers of the configMaps, secrets and ConfigIndexes.
+type Clients struct {
+	KubeClient         kubernetes.Interface
+	ConfiguratorClient clientset.Interface
+
+	kubeInformers informers.SharedInformerFactory
+	configMaps    cache.SharedIndexInformer
+	secrets       cache.SharedIndexInformer
+	configIndexes cache.SharedIndexInformer
+}
+
+//NewClients creates the clientsets of the webhook server and its informers
+func NewClients(cfg *rest.Config, resync time.Duration) (*Clients, error) {
+	kubeClient, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	configuratorClient, err := clientset.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &Clients{
+		KubeClient:         kubeClient,
+		ConfiguratorClient: configuratorClient,
+		kubeInformers:      informers.NewSharedInformerFactory(kubeClient, resync),
+	}
+	c.configMaps = c.kubeInformers.Core().V1().ConfigMaps().Informer()
+	c.secrets = c.kubeInformers.Core().V1().Secrets().Informer()
+
+	crds := configuratorClient.ConfiguratorV1alpha1()
+	c.configIndexes = newInformer(&configuratorv1alpha1.ConfigIndex{}, resync,
+		func(options metav1.ListOptions) (runtime.Object, error) {
+			return crds.ConfigIndexes(metav1.NamespaceAll).List(context.TODO(), options)
+		},
+		func(options metav1.ListOptions) (watch.Interface, error) {
+			return crds.ConfigIndexes(metav1.NamespaceAll).Watch(context.TODO(), options)
+		})
+	return c, nil
+}
+
+func newInformer(obj runtime.Object, resync time.Duration, list cache.ListFunc, watch cache.WatchFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(&cache.ListWatch{ListFunc: list, WatchFunc: watch}, obj, resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+//Start runs the informers until stop is closed. Admission requests are served
+//before the caches are synced, from the API server.
+func (c *Clients) Start(stop <-chan struct{}) {
+	c.kubeInformers.Start(stop)
+	go c.configIndexes.Run(stop)
+	go func() {
+		if cache.WaitForCacheSync(stop, c.configMaps.HasSynced, c.secrets.HasSynced, c.configIndexes.HasSynced) {
+			klog.Info("webhook informer caches synced")
+		}
+	}()
+}
+
+//cached returns the object namespace/name from the informer when its cache is
+//synced and holds it
+func cached(informer cache.SharedIndexInformer, namespace string, name string) (interface{}, bool) {
+	if !informer.HasSynced() {
+		return nil, false
+	}
+	obj, exists, err := informer.GetIndexer().GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil, false
+	}
+	return obj, true
+}
+
+//GetConfigMap returns a copy of the configMap, from the cache when it holds it
+func (c *Clients) GetConfigMap(namespace string, name string) (*corev1.ConfigMap, error) {
+	if obj, ok := cached(c.configMaps, namespace, name); ok {
+		return obj.(*corev1.ConfigMap).DeepCopy(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), LookupTimeout)
+	defer cancel()
+	return c.KubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+//GetSecret returns a copy of the secret, from the cache when it holds it
+func (c *Clients) GetSecret(namespace string, name string) (*corev1.Secret, error) {
+	if obj, ok := cached(c.secrets, namespace, name); ok {
+		return obj.(*corev1.Secret).DeepCopy(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), LookupTimeout)
+	defer cancel()
+	return c.KubeClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+//GetConfigIndex returns a copy of the ConfigIndex. Once the cache is synced a
+//ConfigIndex missing from it is reported not found without asking the API server,
+//most configMaps and secrets have none.
+func (c *Clients) GetConfigIndex(namespace string, name string) (*configuratorv1alpha1.ConfigIndex, error) {
+	if c.configIndexes.HasSynced() {
+		obj, exists, err := c.configIndexes.GetIndexer().GetByKey(namespace + "/" + name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, errors.NewNotFound(configuratorv1alpha1.GroupVersion.WithResource("configindexes").GroupResource(), name)
+		}
+		return obj.(*configuratorv1alpha1.ConfigIndex).DeepCopy(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), LookupTimeout)
+	defer cancel()
+	return c.ConfiguratorClient.ConfiguratorV1alpha1().ConfigIndexes(namespace).Get(ctx, name, metav1.GetOptions{})
+}
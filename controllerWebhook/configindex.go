@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+//configIndexName returns the name of the ConfigIndex the controller keeps for a
+//configMap or secret when it runs with index bookkeeping
+func configIndexName(kind string, name string) string {
+	return strings.ToLower(kind) + "-" + name
+}
+
+//versionAnnotation returns the annotation holding the current version of a configMap or secret
+func versionAnnotation(kind string) string {
+	if kind == "Secret" {
+		return "currentCustomSecretVersion"
+	}
+	return "currentCustomConfigMapVersion"
+}
+
+//currentVersion returns the version a configMap or secret holds, from its
+//annotation or, when the controller keeps the bookkeeping in ConfigIndexes, only
+//from its ConfigIndex. An annotation left over from annotation bookkeeping is stale.
+func (whsvr *WebhookServer) currentVersion(namespace string, kind string, name string, annotations map[string]string) (string, error) {
+	if !whsvr.IndexBookkeeping {
+		return annotations[versionAnnotation(kind)], nil
+	}
+	configIndex, err := whsvr.Clients.GetConfigIndex(namespace, configIndexName(kind, name))
+	if errors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return configIndex.Status.CurrentVersion, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	v1 "k8s.io/api/admission/v1"
+	batchV1beta1 "k8s.io/api/batch/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+func (whsvr *WebhookServer) CronJobController(w http.ResponseWriter, r *http.Request) {
+	serveMutate(w, r, whsvr.cronjobMutate)
+}
+
+//cronjobMutate it create the AdmisionResponse of cronjob patch
+func (whsvr *WebhookServer) cronjobMutate(ar *v1.AdmissionReview) *v1.AdmissionResponse {
+	req := ar.Request
+	var cronjob batchV1beta1.CronJob
+	if err := json.Unmarshal(req.Object.Raw, &cronjob); err != nil {
+		klog.Errorf("Could not unmarshal raw object: %v", err)
+		return &v1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+
+	klog.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v (%v) UID=%v patchOperation=%v DryRun=%v UserInfo=%v",
+		req.Kind, req.Namespace, req.Name, cronjob.Name, req.UID, req.Operation, dryRun(req), req.UserInfo)
+
+	//the namespace isn't always set on the object on create
+	if cronjob.Namespace == "" {
+		cronjob.Namespace = req.Namespace
+	}
+	return patchResponse(whsvr.createCronjobPatch(&cronjob))
+}
+
+//createCronjobPatch it create a cronjob patch on the pod template of its jobTemplate
+func (whsvr *WebhookServer) createCronjobPatch(cronjob *batchV1beta1.CronJob) ([]byte, error) {
+	return whsvr.createPodTemplatePatch(cronjob.Namespace, &cronjob.Spec.JobTemplate.Spec.Template, jobTemplateAnnotationsPath)
+}
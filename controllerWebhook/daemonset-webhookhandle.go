@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	v1 "k8s.io/api/admission/v1"
+	appsV1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+func (whsvr *WebhookServer) DaemonSetController(w http.ResponseWriter, r *http.Request) {
+	serveMutate(w, r, whsvr.daemonsetMutate)
+}
+
+//daemonsetMutate it create the AdmisionResponse of daemonset patch
+func (whsvr *WebhookServer) daemonsetMutate(ar *v1.AdmissionReview) *v1.AdmissionResponse {
+	req := ar.Request
+	var daemonset appsV1.DaemonSet
+	if err := json.Unmarshal(req.Object.Raw, &daemonset); err != nil {
+		klog.Errorf("Could not unmarshal raw object: %v", err)
+		return &v1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+
+	klog.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v (%v) UID=%v patchOperation=%v DryRun=%v UserInfo=%v",
+		req.Kind, req.Namespace, req.Name, daemonset.Name, req.UID, req.Operation, dryRun(req), req.UserInfo)
+
+	//the namespace isn't always set on the object on create
+	if daemonset.Namespace == "" {
+		daemonset.Namespace = req.Namespace
+	}
+	return patchResponse(whsvr.createDaemonsetPatch(&daemonset))
+}
+
+//createDaemonsetPatch it create a daemonset patch
+func (whsvr *WebhookServer) createDaemonsetPatch(daemonset *appsV1.DaemonSet) ([]byte, error) {
+	return whsvr.createPodTemplatePatch(daemonset.Namespace, &daemonset.Spec.Template, templateAnnotationsPath)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	v1 "k8s.io/api/admission/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+func (whsvr *WebhookServer) JobController(w http.ResponseWriter, r *http.Request) {
+	serveMutate(w, r, whsvr.jobMutate)
+}
+
+//jobMutate it create the AdmisionResponse of job patch
+func (whsvr *WebhookServer) jobMutate(ar *v1.AdmissionReview) *v1.AdmissionResponse {
+	req := ar.Request
+	var job batchV1.Job
+	if err := json.Unmarshal(req.Object.Raw, &job); err != nil {
+		klog.Errorf("Could not unmarshal raw object: %v", err)
+		return &v1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+
+	klog.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v (%v) UID=%v patchOperation=%v DryRun=%v UserInfo=%v",
+		req.Kind, req.Namespace, req.Name, job.Name, req.UID, req.Operation, dryRun(req), req.UserInfo)
+
+	//the namespace isn't always set on the object on create
+	if job.Namespace == "" {
+		job.Namespace = req.Namespace
+	}
+	return patchResponse(whsvr.createJobPatch(&job))
+}
+
+//createJobPatch it create a job patch pinning its pod template to the current
+//version of the configMaps and secrets it references. A job created by a cronjob
+//keeps the version stamped on the cronjob's jobTemplate.
+func (whsvr *WebhookServer) createJobPatch(job *batchV1.Job) ([]byte, error) {
+	return whsvr.createPodTemplatePatch(job.Namespace, &job.Spec.Template, templateAnnotationsPath)
+}
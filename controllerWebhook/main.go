@@ -6,10 +6,19 @@ import (
 	"fmt"
 
 	"net/http"
+	"os"
+	"time"
 
 	_ "net/http/pprof"
 
 	"github.com/golang/glog"
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+	"github.com/gopaddle-io/configurator/pkg/revision"
+	"github.com/gopaddle-io/configurator/pkg/selection"
+	"github.com/gopaddle-io/configurator/pkg/workload"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/rest"
 )
 
 var ch chan *struct{}
@@ -20,24 +29,77 @@ func main() {
 
 	flag.StringVar(&parameters.CertFile, "tlsCertFile", "/etc/webhook/certs/cert.pem", "File containing the x509 Certificate for HTTPS.")
 	flag.StringVar(&parameters.KeyFile, "tlsKeyFile", "/etc/webhook/certs/key.pem", "File containing the x509 private key to --tlsCertFile.")
+	flag.StringVar(&parameters.WorkloadConfig, "workloadConfig", "/etc/webhook/workloads/workloads.yaml", "File describing the custom workload resources to annotate.")
+	flag.StringVar(&parameters.SelectionConfig, "selectionConfig", "/etc/webhook/selection/selection.yaml", "File selecting the configMaps and secrets to version.")
+	flag.DurationVar(&parameters.InformerResync, "informerResync", 10*time.Minute, "How often the informers of the configMaps, secrets and configurator resources resync.")
+	flag.DurationVar(&LookupTimeout, "lookupTimeout", LookupTimeout, "How long a read of an object missing from the informer caches may take.")
+	flag.BoolVar(&parameters.ImmutableRevisions, "immutableRevisions", false, "Point the pod templates at the immutable configMaps and secrets the controller materializes every revision as.")
+	flag.StringVar(&parameters.Bookkeeping, "bookkeeping", "annotations", "Where the controller keeps the current revision of configMaps and secrets, annotations or index.")
+	flag.Parse()
+	if parameters.Bookkeeping != "annotations" && parameters.Bookkeeping != "index" {
+		glog.Fatalf("Invalid bookkeeping %q, expected annotations or index", parameters.Bookkeeping)
+	}
+	revision.SetImmutable(parameters.ImmutableRevisions)
 
 	pair, err := tls.LoadX509KeyPair(parameters.CertFile, parameters.KeyFile)
 	if err != nil {
 		glog.Errorf("Failed to load key pair: %v", err)
 	}
 
+	//custom workloads are optional
+	if _, err := os.Stat(parameters.WorkloadConfig); err == nil {
+		config, err := workload.LoadConfig(parameters.WorkloadConfig)
+		if err != nil {
+			glog.Errorf("Failed to load workload config: %v", err)
+		} else {
+			workload.Register(config.Workloads...)
+		}
+	}
+
+	//the default selection applies without a selection config
+	if _, err := os.Stat(parameters.SelectionConfig); err == nil {
+		config, err := selection.LoadConfig(parameters.SelectionConfig)
+		if err != nil {
+			glog.Errorf("Failed to load selection config: %v", err)
+		} else {
+			selection.Set(config)
+		}
+	}
+
+	//a single client for every admission request, reading from its informers
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		glog.Fatalf("Failed to load in-cluster config: %v", err)
+	}
+	clients, err := NewClients(cfg, parameters.InformerResync)
+	if err != nil {
+		glog.Fatalf("Failed to build clients: %v", err)
+	}
+	stop := make(chan struct{})
+	clients.Start(stop)
+
 	whsvr := &WebhookServer{
 		Server: &http.Server{
 			Addr:      fmt.Sprintf(":%v", "8015"),
 			TLSConfig: &tls.Config{Certificates: []tls.Certificate{pair}},
 		},
+		Clients:          clients,
+		IndexBookkeeping: parameters.Bookkeeping == "index",
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/status", GetControllerWebhookStatus)
-	mux.HandleFunc("/deploycontroller", whsvr.DeployController)
-	mux.HandleFunc("/podcontroller", whsvr.PodConfigController)
-	mux.HandleFunc("/stscontroller", whsvr.StatefulSetController)
+	mux.HandleFunc("/deploycontroller", instrument("deploycontroller", whsvr.DeployController))
+	mux.HandleFunc("/podcontroller", instrument("podcontroller", whsvr.PodConfigController))
+	mux.HandleFunc("/stscontroller", instrument("stscontroller", whsvr.StatefulSetController))
+	mux.HandleFunc("/dscontroller", instrument("dscontroller", whsvr.DaemonSetController))
+	mux.HandleFunc("/jobcontroller", instrument("jobcontroller", whsvr.JobController))
+	mux.HandleFunc("/cronjobcontroller", instrument("cronjobcontroller", whsvr.CronJobController))
+	mux.HandleFunc("/workloadcontroller", instrument("workloadcontroller", whsvr.WorkloadController))
+	//admission and restore metrics
+	registry := prometheus.NewRegistry()
+	metrics.Register(registry)
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
 	whsvr.Server.Handler = mux
 
 	fmt.Printf("Server listening at %s", port)
@@ -55,3 +117,11 @@ func GetControllerWebhookStatus(rw http.ResponseWriter, req *http.Request) {
 	rw.WriteHeader(200)
 	rw.Write([]byte(message))
 }
+
+//instrument observes the time the handler takes to admit a request to endpoint
+func instrument(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		defer metrics.ObserveAdmission(endpoint, time.Now())
+		handler(rw, req)
+	}
+}
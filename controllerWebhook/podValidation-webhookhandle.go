@@ -1,20 +1,16 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strings"
-	"time"
 
-	clientset "github.com/gopaddle-io/configurator/pkg/client/clientset/versioned"
+	"github.com/gopaddle-io/configurator/pkg/podtemplate"
+	"github.com/gopaddle-io/configurator/pkg/selection"
 	v1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 )
 
@@ -41,7 +37,7 @@ func (whsvr *WebhookServer) PodConfigController(w http.ResponseWriter, r *http.R
 			},
 		}
 	} else {
-		admissionResponse = ConfigValidation(&ar)
+		admissionResponse = whsvr.ConfigValidation(&ar)
 	}
 
 	if admissionResponse != nil {
@@ -55,14 +51,18 @@ func (whsvr *WebhookServer) PodConfigController(w http.ResponseWriter, r *http.R
 		klog.Errorf("Can't encode response: %v", err)
 		http.Error(w, fmt.Sprintf("could not encode response: %v", err), http.StatusInternalServerError)
 	}
-	klog.Infof("Ready to write reponse ...", string(resp))
+	klog.V(4).Infof("Ready to write reponse %s", string(resp))
 	if _, err := w.Write(resp); err != nil {
 		klog.Errorf("Can't write response: %v", err)
 		http.Error(w, fmt.Sprintf("could not write response: %v", err), http.StatusInternalServerError)
 	}
 }
 
-func ConfigValidation(ar *v1.AdmissionReview) *v1.AdmissionResponse {
+//ConfigValidation allows the pod, with a warning for every configMap or secret
+//holding another version than the one pinned on the pod. It has no side effects,
+//the controller restores the pinned version once the replicaSet or
+//controllerRevision of the pod becomes active.
+func (whsvr *WebhookServer) ConfigValidation(ar *v1.AdmissionReview) *v1.AdmissionResponse {
 
 	req := ar.Request
 	var pod corev1.Pod
@@ -74,369 +74,75 @@ func ConfigValidation(ar *v1.AdmissionReview) *v1.AdmissionResponse {
 			},
 		}
 	}
+	if pod.Namespace == "" {
+		pod.Namespace = req.Namespace
+	}
 
-	klog.Info("AdmissionReview for Kind=%v, Namespace=%v Name=%v (%v) UID=%v validateOperation=%v UserInfo=%v",
-		req.Kind, req.Namespace, req.Name, pod.Name, req.UID, req.Operation, req.UserInfo)
+	klog.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v (%v) UID=%v validateOperation=%v DryRun=%v UserInfo=%v",
+		req.Kind, req.Namespace, req.Name, pod.Name, req.UID, req.Operation, dryRun(req), req.UserInfo)
 
-	// it only allow the deployment/statefulset created pod to validate the version match
+	// it only checks the deployment/statefulset/daemonset/job created pod for the version match
+	var warnings []string
 	if len(pod.Annotations) != 0 && pod.Annotations["config-sync-controller"] == "configurator" {
-		for _, volume := range pod.Spec.Volumes {
-			if volume.ConfigMap != nil {
-				//reading configmapVersion from configmap
-				//get clusterConf
-				var cfg *rest.Config
-				var err error
-				cfg, err = rest.InClusterConfig()
-				//create clientset
-				clientSet, err := kubernetes.NewForConfig(cfg)
-				if err != nil {
-					klog.Error("Error building kubernetes clientset: %s", err.Error(), time.Now().UTC())
-				}
-
-				configMap, err := clientSet.CoreV1().ConfigMaps(pod.Namespace).Get(context.TODO(), volume.ConfigMap.Name, metav1.GetOptions{})
-				if err != nil {
-					if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-						return &v1.AdmissionResponse{
-							Result: &metav1.Status{
-								Message: err.Error(),
-							},
-						}
-					}
-				}
-				if pod.Annotations["ccm-"+volume.ConfigMap.Name] == configMap.Annotations["currentCustomConfigMapVersion"] {
-					klog.Info("customConfigMap version is equal to pod configVersion")
-				} else {
-					//copy configMap
-					configMap.Annotations["currentCustomConfigMapVersion"] = pod.Annotations["ccm-"+volume.ConfigMap.Name]
-					err := CopyCCMToCM(configMap)
-					if err != nil {
-						if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-							return &v1.AdmissionResponse{
-								Result: &metav1.Status{
-									Message: err.Error(),
-								},
-							}
-						}
-					}
-				}
-
-			} else if volume.Secret != nil {
-				//get clusterConf
-				var cfg *rest.Config
-				var err error
-				cfg, err = rest.InClusterConfig()
-				//create clientset
-				clientSet, err := kubernetes.NewForConfig(cfg)
-				if err != nil {
-					klog.Error("Error building kubernetes clientset: %s", err.Error(), time.Now().UTC())
-				}
-
-				secret, err := clientSet.CoreV1().Secrets(pod.Namespace).Get(context.TODO(), volume.Secret.SecretName, metav1.GetOptions{})
-				if err != nil {
-					if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-						return &v1.AdmissionResponse{
-							Result: &metav1.Status{
-								Message: err.Error(),
-							},
-						}
-					}
-				}
-				if pod.Annotations["cs-"+volume.Secret.SecretName] == secret.Annotations["currentCustomSecretVersion"] {
-					klog.Info("customSecret version is equal to pod SecretVersion")
-				} else {
-					//copy CS to secret
-					secret.Annotations["currentCustomSecretVersion"] = pod.Annotations["cs-"+volume.Secret.SecretName]
-					err := CopyCSToSecret(secret)
-					if err != nil {
-						if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-							return &v1.AdmissionResponse{
-								Result: &metav1.Status{
-									Message: err.Error(),
-								},
-							}
-						}
-					}
-				}
-			}
-		}
-
-		//check the envfrom
-		for _, container := range pod.Spec.Containers {
-			for _, env := range container.EnvFrom {
-				if env.ConfigMapRef != nil {
-					//reading configmapVersion from configmap
-					//get clusterConf
-					var cfg *rest.Config
-					var err error
-					cfg, err = rest.InClusterConfig()
-					//create clientset
-					clientSet, err := kubernetes.NewForConfig(cfg)
-					if err != nil {
-						klog.Error("Error building kubernetes clientset: %s", err.Error(), time.Now().UTC())
-					}
-
-					configMap, err := clientSet.CoreV1().ConfigMaps(pod.Namespace).Get(context.TODO(), env.ConfigMapRef.Name, metav1.GetOptions{})
-					if err != nil {
-						if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-							return &v1.AdmissionResponse{
-								Result: &metav1.Status{
-									Message: err.Error(),
-								},
-							}
-						}
-					}
-					if pod.Annotations["ccm-"+env.ConfigMapRef.Name] == configMap.Annotations["currentCustomConfigMapVersion"] {
-						klog.Info("customConfigMap version is equal to pod configVersion")
-					} else {
-						//copy configMap
-						configMap.Annotations["currentCustomConfigMapVersion"] = pod.Annotations["ccm-"+env.ConfigMapRef.Name]
-						err := CopyCCMToCM(configMap)
-						if err != nil {
-							if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-								return &v1.AdmissionResponse{
-									Result: &metav1.Status{
-										Message: err.Error(),
-									},
-								}
-							}
-						}
-					}
-				} else if env.SecretRef != nil {
-					//get clusterConf
-					var cfg *rest.Config
-					var err error
-					cfg, err = rest.InClusterConfig()
-					//create clientset
-					clientSet, err := kubernetes.NewForConfig(cfg)
-					if err != nil {
-						klog.Error("Error building kubernetes clientset: %s", err.Error(), time.Now().UTC())
-					}
-
-					secret, err := clientSet.CoreV1().Secrets(pod.Namespace).Get(context.TODO(), env.SecretRef.Name, metav1.GetOptions{})
-					if err != nil {
-						if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-							return &v1.AdmissionResponse{
-								Result: &metav1.Status{
-									Message: err.Error(),
-								},
-							}
-						}
-					}
-					if pod.Annotations["cs-"+env.SecretRef.Name] == secret.Annotations["currentCustomSecretVersion"] {
-						klog.Info("customSecret version is equal to pod SecretVersion")
-					} else {
-						//copy CS to secret
-						secret.Annotations["currentCustomSecretVersion"] = pod.Annotations["cs-"+env.SecretRef.Name]
-						err := CopyCSToSecret(secret)
-						if err != nil {
-							if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-								return &v1.AdmissionResponse{
-									Result: &metav1.Status{
-										Message: err.Error(),
-									},
-								}
-							}
-						}
-					}
-				}
+		configMaps, secrets := podtemplate.References(&corev1.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec})
+		for _, name := range configMaps {
+			if warning := whsvr.validateConfigMap(&pod, name); warning != "" {
+				warnings = append(warnings, warning)
 			}
 		}
-
-
-		for _, container := range pod.Spec.InitContainers {
-			for _, env := range container.EnvFrom {
-				if env.ConfigMapRef != nil {
-					//reading configmapVersion from configmap
-					//get clusterConf
-					var cfg *rest.Config
-					var err error
-					cfg, err = rest.InClusterConfig()
-					//create clientset
-					clientSet, err := kubernetes.NewForConfig(cfg)
-					if err != nil {
-						klog.Error("Error building kubernetes clientset: %s", err.Error(), time.Now().UTC())
-					}
-
-					configMap, err := clientSet.CoreV1().ConfigMaps(pod.Namespace).Get(context.TODO(), env.ConfigMapRef.Name, metav1.GetOptions{})
-					if err != nil {
-						if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-							return &v1.AdmissionResponse{
-								Result: &metav1.Status{
-									Message: err.Error(),
-								},
-							}
-						}
-					}
-					if pod.Annotations["ccm-"+env.ConfigMapRef.Name] == configMap.Annotations["currentCustomConfigMapVersion"] {
-						klog.Info("customConfigMap version is equal to pod configVersion")
-					} else {
-						//copy configMap
-						configMap.Annotations["currentCustomConfigMapVersion"] = pod.Annotations["ccm-"+env.ConfigMapRef.Name]
-						err := CopyCCMToCM(configMap)
-						if err != nil {
-							if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-								return &v1.AdmissionResponse{
-									Result: &metav1.Status{
-										Message: err.Error(),
-									},
-								}
-							}
-						}
-					}
-				} else if env.SecretRef != nil {
-					//get clusterConf
-					var cfg *rest.Config
-					var err error
-					cfg, err = rest.InClusterConfig()
-					//create clientset
-					clientSet, err := kubernetes.NewForConfig(cfg)
-					if err != nil {
-						klog.Error("Error building kubernetes clientset: %s", err.Error(), time.Now().UTC())
-					}
-
-					secret, err := clientSet.CoreV1().Secrets(pod.Namespace).Get(context.TODO(), env.SecretRef.Name, metav1.GetOptions{})
-					if err != nil {
-						if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-							return &v1.AdmissionResponse{
-								Result: &metav1.Status{
-									Message: err.Error(),
-								},
-							}
-						}
-					}
-					if pod.Annotations["cs-"+env.SecretRef.Name] == secret.Annotations["currentCustomSecretVersion"] {
-						klog.Info("customSecret version is equal to pod SecretVersion")
-					} else {
-						//copy CS to secret
-						secret.Annotations["currentCustomSecretVersion"] = pod.Annotations["cs-"+env.SecretRef.Name]
-						err := CopyCSToSecret(secret)
-						if err != nil {
-							if !strings.Contains(pod.Name, "configurator-controllerwebhook") {
-								return &v1.AdmissionResponse{
-									Result: &metav1.Status{
-										Message: err.Error(),
-									},
-								}
-							}
-						}
-					}
-				}
+		for _, name := range secrets {
+			if warning := whsvr.validateSecret(&pod, name); warning != "" {
+				warnings = append(warnings, warning)
 			}
 		}
 	}
 	return &v1.AdmissionResponse{
-		Allowed: true,
+		Allowed:  true,
+		Warnings: warnings,
 	}
 }
 
-func CopyCCMToCM(configmap *corev1.ConfigMap) error {
-	var cfg *rest.Config
-	var err error
-	cfg, err = rest.InClusterConfig()
-	//create clientset
-	clientSet, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		klog.Error("Error building kubernetes clientset: %s", err.Error(), time.Now().UTC())
-		return err
+//validateConfigMap returns a warning when the configMap holds another version than
+//the one pinned on the pod
+func (whsvr *WebhookServer) validateConfigMap(pod *corev1.Pod, name string) string {
+	pinned := pod.Annotations["ccm-"+name]
+	if pinned == "" {
+		return ""
 	}
-	configuratorClientSet, err := clientset.NewForConfig(cfg)
+	//reading configmapVersion from configmap
+	configMap, err := whsvr.Clients.GetConfigMap(pod.Namespace, name)
 	if err != nil {
-		klog.Error("Error building example clientset: %s", err.Error())
-		return err
+		klog.Infof("failed on getting configMap %s/%s: %v", pod.Namespace, name, err.Error())
+		return ""
 	}
-
-	ccm, err := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(configmap.Namespace).Get(context.TODO(), configmap.Name+"-"+configmap.Annotations["currentCustomConfigMapVersion"], metav1.GetOptions{})
-	if err != nil {
-		klog.Error("Error getting ccm: %s", err.Error())
-		return err
-	}
-	//copying content
-	configmap.Data = ccm.Spec.Data
-	configmap.BinaryData = ccm.Spec.BinaryData
-	configmap.Annotations["customConfigMap-name"] = ccm.Name
-	//Update configMap content based on configmap version
-	_, err = clientSet.CoreV1().ConfigMaps(configmap.Namespace).Update(context.TODO(), configmap, metav1.UpdateOptions{})
-	if err != nil {
-		return err
+	if !selection.ConfigMap(configMap) {
+		return ""
 	}
-	//getting current ccm
-	label := "name=config-multi-env-files,current=true"
-	listOption := metav1.ListOptions{LabelSelector: label}
-	currentccm, _ := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(configmap.Namespace).List(context.TODO(), listOption)
-	currentCCM := currentccm.Items[0]
-	//remove current label from currentCCM
-	delete(currentCCM.Labels, "current")
-	_, errs := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(configmap.Namespace).Update(context.TODO(), &currentCCM, metav1.UpdateOptions{})
-	if errs != nil {
-		return errs
+	version, err := whsvr.currentVersion(pod.Namespace, "ConfigMap", name, configMap.Annotations)
+	if err != nil || pinned == version {
+		return ""
 	}
-
-	//add currentCCM
-	ccm.Labels["current"] = "true"
-	_, errs = configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(configmap.Namespace).Update(context.TODO(), ccm, metav1.UpdateOptions{})
-	if errs != nil {
-		return errs
-	}
-	return nil
+	return fmt.Sprintf("pod pins version %s of configMap %s which holds version %s", pinned, name, version)
 }
 
-//copy customSecret to Secret
-func CopyCSToSecret(secret *corev1.Secret) error {
-	var cfg *rest.Config
-	var err error
-	cfg, err = rest.InClusterConfig()
-	//create clientset
-	clientSet, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		klog.Error("Error building kubernetes clientset: %s", err.Error(), time.Now().UTC())
-		return err
+//validateSecret returns a warning when the secret holds another version than the
+//one pinned on the pod
+func (whsvr *WebhookServer) validateSecret(pod *corev1.Pod, name string) string {
+	pinned := pod.Annotations["cs-"+name]
+	if pinned == "" {
+		return ""
 	}
-	configuratorClientSet, err := clientset.NewForConfig(cfg)
+	secret, err := whsvr.Clients.GetSecret(pod.Namespace, name)
 	if err != nil {
-		klog.Error("Error building example clientset: %s", err.Error())
-		return err
+		klog.Infof("failed on getting secret %s/%s: %v", pod.Namespace, name, err.Error())
+		return ""
 	}
-
-	cs, err := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(secret.Namespace).Get(context.TODO(), secret.Name+"-"+secret.Annotations["currentCustomSecretVersion"], metav1.GetOptions{})
-	if err != nil {
-		klog.Error("Error getting ccm: %s", err.Error())
-		return err
-	}
-	//copying content
-	secret.Data = cs.Spec.Data
-	cs.Spec.SecretAnnotations["customConfigMap-name"] = cs.Name
-	cs.Spec.SecretAnnotations["deployments"] = secret.Annotations["deployments"]
-	cs.Spec.SecretAnnotations["statefulsets"] = secret.Annotations["statefulsets"]
-	cs.Spec.SecretAnnotations["updateMethod"] = secret.Annotations["updateMethod"]
-	cs.Spec.SecretAnnotations["currentCustomSecretVersion"] = secret.Annotations["currentCustomSecretVersion"]
-	secret.Annotations = cs.Spec.SecretAnnotations
-	//Update secret content based on secret version
-	_, err = clientSet.CoreV1().Secrets(secret.Namespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
-	if err != nil {
-		return err
-	}
-	//getting current ccm
-	label := "name=config-multi-env-files,current=true"
-	listOption := metav1.ListOptions{LabelSelector: label}
-	currentcs, _ := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(secret.Namespace).List(context.TODO(), listOption)
-	currentCS := currentcs.Items[0]
-	//remove current label from currentCCM
-	delete(currentCS.Labels, "current")
-	_, errs := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(secret.Namespace).Update(context.TODO(), &currentCS, metav1.UpdateOptions{})
-	if errs != nil {
-		return errs
+	if !selection.Secret(secret) {
+		return ""
 	}
-
-	//add currentCCM
-	cs.Labels["current"] = "true"
-	delete(cs.Spec.SecretAnnotations, "currentCustomSecretVersion")
-	delete(cs.Spec.SecretAnnotations, "customSecret-name")
-	delete(cs.Spec.SecretAnnotations, "updateMethod")
-	delete(cs.Spec.SecretAnnotations, "deployments")
-	delete(cs.Spec.SecretAnnotations, "statefulsets")
-	_, errs = configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(secret.Namespace).Update(context.TODO(), cs, metav1.UpdateOptions{})
-	if errs != nil {
-		return errs
+	version, err := whsvr.currentVersion(pod.Namespace, "Secret", name, secret.Annotations)
+	if err != nil || pinned == version {
+		return ""
 	}
-	return nil
+	return fmt.Sprintf("pod pins version %s of secret %s which holds version %s", pinned, name, version)
 }
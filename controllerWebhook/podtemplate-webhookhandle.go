@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/gopaddle-io/configurator/pkg/podtemplate"
+	"github.com/gopaddle-io/configurator/pkg/selection"
+	"gomodules.xyz/jsonpatch/v2"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/klog/v2"
+)
+
+var (
+	runtimeScheme = runtime.NewScheme()
+	codecs        = serializer.NewCodecFactory(runtimeScheme)
+	deserializer  = codecs.UniversalDeserializer()
+)
+
+//templateAnnotationsPath is the json patch path of the pod template annotations
+//of deployments, statefulsets, daemonsets and jobs
+const templateAnnotationsPath = "/spec/template/metadata/annotations"
+
+//jobTemplateAnnotationsPath is the json patch path of the pod template annotations
+//of the jobTemplate of a cronjob
+const jobTemplateAnnotationsPath = "/spec/jobTemplate/spec/template/metadata/annotations"
+
+//serveMutate decodes the AdmissionReview of the request, runs mutate on it and
+//writes the AdmissionReview response. The handlers only read the configMaps,
+//secrets and ConfigIndexes to compute their patch, a dry-run request is answered
+//the same way as any other request without side effects.
+func serveMutate(w http.ResponseWriter, r *http.Request, mutate func(*v1.AdmissionReview) *v1.AdmissionResponse) {
+	var body []byte
+	if r.Body != nil {
+		if data, err := ioutil.ReadAll(r.Body); err == nil {
+			body = data
+		}
+	}
+	if len(body) == 0 {
+		klog.Error("empty body")
+		http.Error(w, "empty body", http.StatusBadRequest)
+		return
+	}
+	var admissionResponse *v1.AdmissionResponse
+	ar := v1.AdmissionReview{}
+	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
+		klog.Errorf("Can't decode body: %v", err)
+		admissionResponse = &v1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	} else {
+		admissionResponse = mutate(&ar)
+	}
+	admissionReview := v1.AdmissionReview{}
+	admissionReview.Kind = "AdmissionReview"
+	admissionReview.APIVersion = "admission.k8s.io/v1"
+	if admissionResponse != nil {
+		admissionReview.Response = admissionResponse
+		if ar.Request != nil {
+			admissionReview.Response.UID = ar.Request.UID
+		}
+	}
+	resp, err := json.Marshal(admissionReview)
+	if err != nil {
+		klog.Errorf("Can't encode response: %v", err)
+		http.Error(w, fmt.Sprintf("could not encode response: %v", err), http.StatusInternalServerError)
+	}
+	klog.V(4).Infof("Ready to write reponse %s", string(resp))
+	if _, err := w.Write(resp); err != nil {
+		klog.Errorf("Can't write response: %v", err)
+		http.Error(w, fmt.Sprintf("could not write response: %v", err), http.StatusInternalServerError)
+	}
+}
+
+//patchResponse creates the AdmissionResponse carrying the json patch of a workload
+func patchResponse(patchBytes []byte, err error) *v1.AdmissionResponse {
+	if err != nil {
+		glog.Infof("AdmissionResponse: create patch failed %v\n", err.Error())
+		return &v1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+
+	klog.V(4).Infof("AdmissionResponse: patch=%v", string(patchBytes))
+	return &v1.AdmissionResponse{
+		Allowed: true,
+		Patch:   patchBytes,
+		PatchType: func() *v1.PatchType {
+			pt := v1.PatchTypeJSONPatch
+			return &pt
+		}(),
+	}
+}
+
+//dryRun reports whether the request is a dry-run, like kubectl apply --dry-run=server
+//or kubectl diff
+func dryRun(req *v1.AdmissionRequest) bool {
+	return req.DryRun != nil && *req.DryRun
+}
+
+//createPodTemplatePatch patches the template annotations found at path with the
+//current version of every configMap and secret the pod template references.
+//The configMaps and secrets are only read, from the informer cache, their
+//consumers are looked up by the controller from its index of the pod templates.
+func (whsvr *WebhookServer) createPodTemplatePatch(namespace string, template *corev1.PodTemplateSpec, path string) ([]byte, error) {
+	annotations, configMapTargets, secretTargets, err := podtemplate.Annotations(namespace, template, whsvr.configMapVersion(namespace), whsvr.secretVersion(namespace))
+	if err != nil {
+		return nil, err
+	}
+	patch := podtemplate.Patch(template, path, annotations, configMapTargets, secretTargets)
+	if patch == nil {
+		//an empty patch rather than null, the template is already pinned
+		patch = []jsonpatch.JsonPatchOperation{}
+	}
+	return json.Marshal(patch)
+}
+
+//configMapVersion returns the current version of the configMaps of the namespace,
+//read from the informer cache
+func (whsvr *WebhookServer) configMapVersion(namespace string) podtemplate.VersionFunc {
+	return func(name string) (string, bool, error) {
+		configMap, err := whsvr.Clients.GetConfigMap(namespace, name)
+		if errors.IsNotFound(err) {
+			//an optional or not yet created configMap has no version to pin
+			klog.Infof("configMap %s/%s referenced by the pod template not found, not pinning it", namespace, name)
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if !selection.ConfigMap(configMap) {
+			return "", false, nil
+		}
+		version, err := whsvr.currentVersion(namespace, "ConfigMap", configMap.Name, configMap.Annotations)
+		return version, err == nil, err
+	}
+}
+
+//secretVersion returns the current version of the secrets of the namespace, read
+//from the informer cache
+func (whsvr *WebhookServer) secretVersion(namespace string) podtemplate.VersionFunc {
+	return func(name string) (string, bool, error) {
+		secret, err := whsvr.Clients.GetSecret(namespace, name)
+		if errors.IsNotFound(err) {
+			//an optional or not yet created secret has no version to pin
+			klog.Infof("secret %s/%s referenced by the pod template not found, not pinning it", namespace, name)
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		if !selection.Secret(secret) {
+			return "", false, nil
+		}
+		version, err := whsvr.currentVersion(namespace, "Secret", secret.Name, secret.Annotations)
+		return version, err == nil, err
+	}
+}
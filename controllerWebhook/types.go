@@ -1,19 +1,26 @@
 package main
 
-import "net/http"
-
-type patchOperation struct {
-	Op    string      `json:"op"`
-	Path  string      `json:"path"`
-	Value interface{} `json:"value,omitempty"`
-}
+import (
+	"net/http"
+	"time"
+)
 
 type WebhookServer struct {
 	Server *http.Server
+	//Clients serves the lookups of the admission handlers from its informers
+	Clients *Clients
+	//IndexBookkeeping reads the current versions from the ConfigIndexes the
+	//controller keeps instead of the annotations of the configMaps and secrets
+	IndexBookkeeping bool
 }
 
 // Webhook Server parameters
 type WhSvrParameters struct {
-	CertFile string // path to the x509 certificate for https
-	KeyFile  string // path to the x509 private key matching `CertFile`
+	CertFile           string        // path to the x509 certificate for https
+	KeyFile            string        // path to the x509 private key matching `CertFile`
+	WorkloadConfig     string        // path to the file describing the custom workload resources
+	SelectionConfig    string        // path to the file selecting the configMaps and secrets to version
+	InformerResync     time.Duration // resync period of the informers the admission requests are served from
+	ImmutableRevisions bool          // point the pod templates at the immutable revisions
+	Bookkeeping        string        // where the controller keeps the current versions, annotations or index
 }
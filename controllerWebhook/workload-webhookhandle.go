@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gopaddle-io/configurator/pkg/workload"
+	v1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+func (whsvr *WebhookServer) WorkloadController(w http.ResponseWriter, r *http.Request) {
+	serveMutate(w, r, whsvr.workloadMutate)
+}
+
+//workloadMutate it create the AdmisionResponse of a custom workload patch
+func (whsvr *WebhookServer) workloadMutate(ar *v1.AdmissionReview) *v1.AdmissionResponse {
+	req := ar.Request
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(req.Object.Raw); err != nil {
+		klog.Errorf("Could not unmarshal raw object: %v", err)
+		return &v1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
+	}
+
+	klog.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v (%v) UID=%v patchOperation=%v DryRun=%v UserInfo=%v",
+		req.Kind, req.Namespace, req.Name, obj.GetName(), req.UID, req.Operation, dryRun(req), req.UserInfo)
+
+	adapter, ok := workload.ForGroupVersionKind(schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind})
+	if !ok {
+		return patchResponse(nil, fmt.Errorf("no workload adapter configured for %s", req.Kind.String()))
+	}
+	//the namespace isn't always set on the object on create
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(req.Namespace)
+	}
+	return patchResponse(whsvr.createWorkloadPatch(adapter, obj))
+}
+
+//createWorkloadPatch it create a patch on the pod template of a custom workload
+func (whsvr *WebhookServer) createWorkloadPatch(adapter workload.Adapter, obj *unstructured.Unstructured) ([]byte, error) {
+	template, err := adapter.PodTemplate(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	return whsvr.createPodTemplatePatch(obj.GetNamespace(), template, adapter.AnnotationsPatchPath())
+}
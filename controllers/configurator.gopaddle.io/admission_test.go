@@ -0,0 +1,114 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configuratorgopaddleio
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gopaddle-io/configurator/webhooks"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("Dry run admission", func() {
+	var (
+		ctx       context.Context
+		namespace string
+		configMap *corev1.ConfigMap
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		namespace = newNamespace(ctx, "admission")
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app",
+				Namespace:   namespace,
+				Annotations: map[string]string{"currentCustomConfigMapVersion": "v2"},
+			},
+			Data: map[string]string{"key": "two"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+	})
+
+	//dryRunRequest returns a dry run admission request creating obj
+	dryRunRequest := func(kind metav1.GroupVersionKind, name string, obj interface{}) admission.Request {
+		raw, err := json.Marshal(obj)
+		Expect(err).NotTo(HaveOccurred())
+		dryRun := true
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			UID:       types.UID(name),
+			Kind:      kind,
+			Namespace: namespace,
+			Name:      name,
+			Operation: admissionv1.Create,
+			DryRun:    &dryRun,
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	//unchanged checks nothing was written to the configMap
+	unchanged := func() {
+		var current corev1.ConfigMap
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "app"}, &current)).To(Succeed())
+		Expect(current.ResourceVersion).To(Equal(configMap.ResourceVersion))
+	}
+
+	It("pins the workload without writing to the configMap", func() {
+		deployment := newDeployment(namespace, "web", "app", "")
+		deployment.Spec.Template.Annotations = nil
+		annotator := &webhooks.WorkloadAnnotator{Client: k8sClient}
+		resp := annotator.Handle(ctx, dryRunRequest(metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "web", deployment))
+		Expect(resp.Allowed).To(BeTrue())
+
+		pinned := false
+		for _, operation := range resp.Patches {
+			if operation.Operation == "add" && operation.Path == "/spec/template/metadata/annotations" {
+				Expect(operation.Value).To(HaveKeyWithValue("ccm-app", "v2"))
+				pinned = true
+			}
+		}
+		Expect(pinned).To(BeTrue())
+		unchanged()
+	})
+
+	It("warns about a pod pinning another version without restoring it", func() {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web",
+				Namespace: namespace,
+				Annotations: map[string]string{
+					"config-sync-controller": "configurator",
+					"ccm-app":                "v1",
+				},
+			},
+			Spec: newDeployment(namespace, "web", "app", "v1").Spec.Template.Spec,
+		}
+		validator := &webhooks.PodValidator{Client: k8sClient}
+		resp := validator.Handle(ctx, dryRunRequest(metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}, "web", pod))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Warnings).To(HaveLen(1))
+		unchanged()
+	})
+})
@@ -0,0 +1,252 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configuratorgopaddleio
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	corecontrollers "github.com/gopaddle-io/configurator/controllers/core"
+	"github.com/gopaddle-io/configurator/pkg/index"
+)
+
+// ConfigRollbackReconciler reconciles a ConfigRollback object
+type ConfigRollbackReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=configrollbacks,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=configrollbacks/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=configrollbacks/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+// A ConfigRollback restores the named revision into its ConfigMap or Secret
+// and rolls every workload referencing it, once for each generation of its spec.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.8.3/pkg/reconcile
+func (r *ConfigRollbackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var rollback configuratorgopaddleiov1alpha1.ConfigRollback
+	if err := r.Get(ctx, req.NamespacedName, &rollback); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	//rollback already done for this spec
+	if rollback.Status.ObservedGeneration == rollback.Generation &&
+		(rollback.Status.Phase == configuratorgopaddleiov1alpha1.ConfigRollbackCompleted || rollback.Status.Phase == configuratorgopaddleiov1alpha1.ConfigRollbackFailed) {
+		return ctrl.Result{}, nil
+	}
+
+	if rollback.Status.ObservedGeneration != rollback.Generation || rollback.Status.StartedAt == nil {
+		now := metav1.Now()
+		rollback.Status = configuratorgopaddleiov1alpha1.ConfigRollbackStatus{
+			Phase:              configuratorgopaddleiov1alpha1.ConfigRollbackInProgress,
+			StartedAt:          &now,
+			ObservedGeneration: rollback.Generation,
+		}
+		if err := r.Status().Update(ctx, &rollback); err != nil {
+			logger.Error(err, "Unable to update configRollback status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	var err error
+	switch rollback.Spec.Kind {
+	case "ConfigMap":
+		err = r.rollbackConfigMap(ctx, &rollback)
+	case "Secret":
+		err = r.rollbackSecret(ctx, &rollback)
+	default:
+		err = errors.NewBadRequest(fmt.Sprintf("unsupported kind %s, expected ConfigMap or Secret", rollback.Spec.Kind))
+	}
+
+	if er := r.finish(ctx, &rollback, err); er != nil {
+		logger.Error(er, "Unable to update configRollback status")
+		return ctrl.Result{}, er
+	}
+	if err != nil && !isTerminal(err) {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+//rollbackConfigMap restores the revision into the configMap and rolls its consumers
+func (r *ConfigRollbackReconciler) rollbackConfigMap(ctx context.Context, rollback *configuratorgopaddleiov1alpha1.ConfigRollback) error {
+	ccm, err := corecontrollers.GetCustomConfigMapByVersion(ctx, r.Client, rollback.Namespace, rollback.Spec.Name, rollback.Spec.Revision)
+	if errors.IsNotFound(err) {
+		//revision given by name
+		ccm = &configuratorgopaddleiov1alpha1.CustomConfigMap{}
+		err = r.Get(ctx, types.NamespacedName{Namespace: rollback.Namespace, Name: rollback.Spec.Revision}, ccm)
+		if err == nil && ccm.Spec.ConfigMapName != rollback.Spec.Name {
+			err = errors.NewBadRequest(fmt.Sprintf("customConfigMap %s is not a revision of configMap %s", ccm.Name, rollback.Spec.Name))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var configMap corev1.ConfigMap
+	if err := r.Get(ctx, types.NamespacedName{Namespace: rollback.Namespace, Name: rollback.Spec.Name}, &configMap); err != nil {
+		return err
+	}
+	version := ccm.Annotations["customConfigMapVersion"]
+	rollback.Status.RevisionName = ccm.Name
+	rollback.Status.Version = version
+	if rollback.Status.PreviousVersion == "" {
+		previous, err := corecontrollers.CurrentVersion(ctx, r.Client, &configMap)
+		if err != nil {
+			return err
+		}
+		rollback.Status.PreviousVersion = previous
+	}
+
+	if _, err := corecontrollers.RestoreCustomConfigMap(ctx, r.Client, r.EventRecorder, ccm); err != nil {
+		return err
+	}
+	consumers, err := rollConsumers(ctx, r.Client, rollback.Namespace, index.ConfigMapField, rollback.Spec.Name, "ccm-"+rollback.Spec.Name, version)
+	if consumers != nil {
+		rollback.Status.Consumers = consumers
+	}
+	return err
+}
+
+//rollbackSecret restores the revision into the secret and rolls its consumers
+func (r *ConfigRollbackReconciler) rollbackSecret(ctx context.Context, rollback *configuratorgopaddleiov1alpha1.ConfigRollback) error {
+	cs, err := corecontrollers.GetCustomSecretByVersion(ctx, r.Client, rollback.Namespace, rollback.Spec.Name, rollback.Spec.Revision)
+	if errors.IsNotFound(err) {
+		//revision given by name
+		cs = &configuratorgopaddleiov1alpha1.CustomSecret{}
+		err = r.Get(ctx, types.NamespacedName{Namespace: rollback.Namespace, Name: rollback.Spec.Revision}, cs)
+		if err == nil && cs.Spec.SecretName != rollback.Spec.Name {
+			err = errors.NewBadRequest(fmt.Sprintf("customSecret %s is not a revision of secret %s", cs.Name, rollback.Spec.Name))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: rollback.Namespace, Name: rollback.Spec.Name}, &secret); err != nil {
+		return err
+	}
+	version := cs.Annotations["customSecretVersion"]
+	rollback.Status.RevisionName = cs.Name
+	rollback.Status.Version = version
+	if rollback.Status.PreviousVersion == "" {
+		previous, err := corecontrollers.CurrentVersion(ctx, r.Client, &secret)
+		if err != nil {
+			return err
+		}
+		rollback.Status.PreviousVersion = previous
+	}
+
+	if _, err := corecontrollers.RestoreCustomSecret(ctx, r.Client, r.EventRecorder, cs); err != nil {
+		return err
+	}
+	consumers, err := rollConsumers(ctx, r.Client, rollback.Namespace, index.SecretField, rollback.Spec.Name, "cs-"+rollback.Spec.Name, version)
+	if consumers != nil {
+		rollback.Status.Consumers = consumers
+	}
+	return err
+}
+
+//finish records the outcome of the rollback in its status
+func (r *ConfigRollbackReconciler) finish(ctx context.Context, rollback *configuratorgopaddleiov1alpha1.ConfigRollback, err error) error {
+	now := metav1.Now()
+	switch {
+	case err == nil:
+		rollback.Status.Phase = configuratorgopaddleiov1alpha1.ConfigRollbackCompleted
+		rollback.Status.Message = ""
+		rollback.Status.CompletedAt = &now
+		meta.SetStatusCondition(&rollback.Status.Conditions, metav1.Condition{
+			Type:    "Complete",
+			Status:  metav1.ConditionTrue,
+			Reason:  "RollbackCompleted",
+			Message: fmt.Sprintf("%s %s restored to version %s", rollback.Spec.Kind, rollback.Spec.Name, rollback.Status.Version),
+		})
+		r.EventRecorder.Eventf(rollback, corev1.EventTypeNormal, "rollbackCompleted", "%s %s restored to version %s", rollback.Spec.Kind, rollback.Spec.Name, rollback.Status.Version)
+	case isTerminal(err):
+		rollback.Status.Phase = configuratorgopaddleiov1alpha1.ConfigRollbackFailed
+		rollback.Status.Message = err.Error()
+		rollback.Status.CompletedAt = &now
+		meta.SetStatusCondition(&rollback.Status.Conditions, metav1.Condition{
+			Type:    "Complete",
+			Status:  metav1.ConditionFalse,
+			Reason:  "RollbackFailed",
+			Message: err.Error(),
+		})
+		r.EventRecorder.Eventf(rollback, corev1.EventTypeWarning, "FailedRollback", "Error rolling back %s %s: %v", rollback.Spec.Kind, rollback.Spec.Name, err)
+	default:
+		//retried on the next reconcile
+		rollback.Status.Message = err.Error()
+	}
+	return r.Status().Update(ctx, rollback)
+}
+
+//consumerFailures returns an error when a consumer could not be rolled. The error
+//is terminal unless retryable, as when an update kept conflicting with another
+//writer: the rollback is then retried on the next reconcile.
+func consumerFailures(consumers []configuratorgopaddleiov1alpha1.ConsumerRollbackStatus, retryable bool) error {
+	failed := 0
+	for _, consumer := range consumers {
+		if !consumer.Updated {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	if retryable {
+		return fmt.Errorf("%d of %d consumers were not updated", failed, len(consumers))
+	}
+	return errors.NewBadRequest(fmt.Sprintf("%d of %d consumers were not updated", failed, len(consumers)))
+}
+
+//isTerminal reports whether retrying the rollback can't succeed
+func isTerminal(err error) bool {
+	return errors.IsNotFound(err) || errors.IsBadRequest(err)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ConfigRollbackReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&configuratorgopaddleiov1alpha1.ConfigRollback{}).
+		Complete(r)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configuratorgopaddleio
+
+import (
+	"context"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsV1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+//newNamespace creates a namespace for a single spec
+func newNamespace(ctx context.Context, prefix string) string {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: prefix + "-"}}
+	Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+	return ns.Name
+}
+
+//newDeployment returns a deployment whose pod template references the configMap
+//and pins version
+func newDeployment(namespace string, name string, configMap string, version string) *appsV1.Deployment {
+	labels := map[string]string{"app": name}
+	return &appsV1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsV1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+					Annotations: map[string]string{
+						"config-sync-controller": "configurator",
+						"ccm-" + configMap:       version,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  name,
+						Image: "nginx",
+						EnvFrom: []corev1.EnvFromSource{{
+							ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: configMap}},
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+var _ = Describe("ConfigRollback", func() {
+	var (
+		ctx        context.Context
+		namespace  string
+		reconciler *ConfigRollbackReconciler
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		namespace = newNamespace(ctx, "rollback")
+		reconciler = &ConfigRollbackReconciler{Client: testClient, Scheme: scheme.Scheme, EventRecorder: record.NewFakeRecorder(1024)}
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app",
+				Namespace:   namespace,
+				Annotations: map[string]string{"currentCustomConfigMapVersion": "v2"},
+			},
+			Data: map[string]string{"key": "two"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+		for version, labels := range map[string]map[string]string{
+			"v1": {"name": "app"},
+			"v2": {"name": "app", "current": "true", "latest": "true"},
+		} {
+			ccm := &configuratorgopaddleiov1alpha1.CustomConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "app-" + version,
+					Namespace:   namespace,
+					Labels:      labels,
+					Annotations: map[string]string{"customConfigMapVersion": version},
+				},
+				Spec: configuratorgopaddleiov1alpha1.CustomConfigMapSpec{
+					ConfigMapName: "app",
+					Data:          map[string]string{"key": version},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ccm)).To(Succeed())
+		}
+		Expect(k8sClient.Create(ctx, newDeployment(namespace, "web", "app", "v2"))).To(Succeed())
+		waitForConsumers(ctx, namespace, "app", 1)
+	})
+
+	rollback := func(revision string) *configuratorgopaddleiov1alpha1.ConfigRollback {
+		rollback := &configuratorgopaddleiov1alpha1.ConfigRollback{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-rollback", Namespace: namespace},
+			Spec: configuratorgopaddleiov1alpha1.ConfigRollbackSpec{
+				Kind:     "ConfigMap",
+				Name:     "app",
+				Revision: revision,
+			},
+		}
+		Expect(k8sClient.Create(ctx, rollback)).To(Succeed())
+		request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: rollback.Name}}
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, request.NamespacedName, rollback)).To(Succeed())
+		return rollback
+	}
+
+	It("restores the revision into the configMap and rolls its consumers", func() {
+		result := rollback("v1")
+		Expect(result.Status.Phase).To(Equal(configuratorgopaddleiov1alpha1.ConfigRollbackCompleted))
+		Expect(result.Status.PreviousVersion).To(Equal("v2"))
+		Expect(result.Status.Version).To(Equal("v1"))
+		Expect(result.Status.Consumers).To(ConsistOf(configuratorgopaddleiov1alpha1.ConsumerRollbackStatus{
+			ConsumerReference: configuratorgopaddleiov1alpha1.ConsumerReference{Kind: "Deployment", Name: "web"},
+			Updated:           true,
+		}))
+
+		var configMap corev1.ConfigMap
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "app"}, &configMap)).To(Succeed())
+		Expect(configMap.Data).To(Equal(map[string]string{"key": "v1"}))
+		Expect(configMap.Annotations).To(HaveKeyWithValue("currentCustomConfigMapVersion", "v1"))
+
+		var deployment appsV1.Deployment
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "web"}, &deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Annotations).To(HaveKeyWithValue("ccm-app", "v1"))
+	})
+
+	It("fails for a revision that was never recorded", func() {
+		result := rollback("v3")
+		Expect(result.Status.Phase).To(Equal(configuratorgopaddleiov1alpha1.ConfigRollbackFailed))
+
+		var configMap corev1.ConfigMap
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "app"}, &configMap)).To(Succeed())
+		Expect(configMap.Data).To(Equal(map[string]string{"key": "two"}))
+	})
+	It("retries the rollback when a consumer could not be rolled for a transient reason", func() {
+		consumers := []configuratorgopaddleiov1alpha1.ConsumerRollbackStatus{
+			{ConsumerReference: configuratorgopaddleiov1alpha1.ConsumerReference{Kind: "Deployment", Name: "web"}, Updated: true},
+			{ConsumerReference: configuratorgopaddleiov1alpha1.ConsumerReference{Kind: "Deployment", Name: "api"}},
+		}
+		Expect(consumerFailures(consumers[:1], false)).To(Succeed())
+		Expect(isTerminal(consumerFailures(consumers, false))).To(BeTrue())
+		Expect(isTerminal(consumerFailures(consumers, true))).To(BeFalse())
+	})
+})
@@ -0,0 +1,425 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configuratorgopaddleio
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	corecontrollers "github.com/gopaddle-io/configurator/controllers/core"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+)
+
+//rolloutPollInterval is how often a rollout checks whether the rolled workloads are available
+const rolloutPollInterval = 10 * time.Second
+
+// ConfigRolloutReconciler reconciles a ConfigRollout object
+type ConfigRolloutReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=configrollouts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=configrollouts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=configrollouts/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=configrollbacks,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=customconfigmaps/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=customsecrets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+// A ConfigRollout walks the workloads using its ConfigMap or Secret in order,
+// sets the version on their pod template and waits for each of them to become
+// available. A workload not available within the progress deadline halts the
+// rollout, and a newer version of the ConfigMap or Secret supersedes it.
+// With an auto rollback policy the workloads are watched for a window once
+// available, and a workload timing out, losing readiness or restarting too
+// often rolls the ConfigMap or Secret back to the previous version.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.8.3/pkg/reconcile
+func (r *ConfigRolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var rollout configuratorgopaddleiov1alpha1.ConfigRollout
+	if err := r.Get(ctx, req.NamespacedName, &rollout); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	//rollout already done for this spec
+	if rollout.Status.ObservedGeneration == rollout.Generation && rolloutFinished(rollout.Status.Phase) {
+		return ctrl.Result{}, nil
+	}
+
+	field := index.ConfigMapField
+	var config client.Object = &corev1.ConfigMap{}
+	if rollout.Spec.Kind == "Secret" {
+		field = index.SecretField
+		config = &corev1.Secret{}
+	}
+
+	if rollout.Status.ObservedGeneration != rollout.Generation || rollout.Status.StartedAt == nil {
+		consumers, err := corecontrollers.RolloutConsumers(ctx, r.Client, rollout.Namespace, field, rollout.Spec.Name)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		now := metav1.Now()
+		rollout.Status = configuratorgopaddleiov1alpha1.ConfigRolloutStatus{
+			Phase:              configuratorgopaddleiov1alpha1.ConfigRolloutProgressing,
+			StartedAt:          &now,
+			ObservedGeneration: rollout.Generation,
+		}
+		for _, consumer := range consumers {
+			rollout.Status.Workloads = append(rollout.Status.Workloads, configuratorgopaddleiov1alpha1.WorkloadRolloutStatus{
+				ConsumerReference: consumer,
+				Phase:             configuratorgopaddleiov1alpha1.WorkloadRolloutPending,
+			})
+		}
+		if err := r.Status().Update(ctx, &rollout); err != nil {
+			logger.Error(err, "Unable to update configRollout status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	//a newer version of the configMap or secret supersedes the rollout
+	err := r.Get(ctx, types.NamespacedName{Namespace: rollout.Namespace, Name: rollout.Spec.Name}, config)
+	if errors.IsNotFound(err) {
+		return ctrl.Result{}, r.finish(ctx, &rollout, configuratorgopaddleiov1alpha1.ConfigRolloutHalted, fmt.Sprintf("%s %s not found", rollout.Spec.Kind, rollout.Spec.Name))
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	current, err := corecontrollers.CurrentVersion(ctx, r.Client, config)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if current != "" && current != rollout.Spec.Version {
+		return ctrl.Result{}, r.finish(ctx, &rollout, configuratorgopaddleiov1alpha1.ConfigRolloutSuperseded, fmt.Sprintf("%s %s moved on to version %s", rollout.Spec.Kind, rollout.Spec.Name, current))
+	}
+
+	halted, err := r.progress(ctx, &rollout)
+	if halted == "" && err == nil && rollout.Spec.AutoRollback != nil {
+		halted, err = r.verify(ctx, &rollout)
+	}
+	if halted != "" {
+		if rollout.Spec.AutoRollback != nil {
+			return ctrl.Result{}, r.rollback(ctx, &rollout, config, halted)
+		}
+		return ctrl.Result{}, r.finish(ctx, &rollout, configuratorgopaddleiov1alpha1.ConfigRolloutHalted, halted)
+	}
+	if err != nil {
+		//retried on the next reconcile
+		rollout.Status.Message = err.Error()
+		if er := r.Status().Update(ctx, &rollout); er != nil {
+			logger.Error(er, "Unable to update configRollout status")
+		}
+		return ctrl.Result{}, err
+	}
+	for _, workload := range rollout.Status.Workloads {
+		if workload.Phase != configuratorgopaddleiov1alpha1.WorkloadRolloutAvailable && workload.Phase != configuratorgopaddleiov1alpha1.WorkloadRolloutSkipped {
+			rollout.Status.Message = ""
+			if err := r.Status().Update(ctx, &rollout); err != nil {
+				logger.Error(err, "Unable to update configRollout status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: rolloutPollInterval}, nil
+		}
+	}
+	//watch the available workloads for the window before completing
+	if rollout.Spec.AutoRollback != nil {
+		window := time.Duration(rollout.Spec.AutoRollback.WindowSeconds) * time.Second
+		var availableAt time.Time
+		for _, workload := range rollout.Status.Workloads {
+			if workload.AvailableAt != nil && workload.AvailableAt.Time.After(availableAt) {
+				availableAt = workload.AvailableAt.Time
+			}
+		}
+		if time.Since(availableAt) < window {
+			if rollout.Status.Phase != configuratorgopaddleiov1alpha1.ConfigRolloutVerifying {
+				r.EventRecorder.Eventf(&rollout, corev1.EventTypeNormal, "RolloutVerifying", "watching the workloads of version %s for %v", rollout.Spec.Version, window)
+			}
+			rollout.Status.Phase = configuratorgopaddleiov1alpha1.ConfigRolloutVerifying
+			rollout.Status.Message = ""
+			if err := r.Status().Update(ctx, &rollout); err != nil {
+				logger.Error(err, "Unable to update configRollout status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: rolloutPollInterval}, nil
+		}
+	}
+	return ctrl.Result{}, r.finish(ctx, &rollout, configuratorgopaddleiov1alpha1.ConfigRolloutCompleted, "")
+}
+
+//progress walks the workloads of the rollout in order. A pending workload gets the
+//version set on its pod template, a progressing workload is checked for
+//availability against the progress deadline. A sequential rollout stops at the
+//first workload that is not available yet. It returns why the rollout halted, if it did.
+func (r *ConfigRolloutReconciler) progress(ctx context.Context, rollout *configuratorgopaddleiov1alpha1.ConfigRollout) (string, error) {
+	key := "ccm-" + rollout.Spec.Name
+	if rollout.Spec.Kind == "Secret" {
+		key = "cs-" + rollout.Spec.Name
+	}
+	deadline := time.Duration(rollout.Spec.ProgressDeadlineSeconds) * time.Second
+	if deadline == 0 {
+		deadline = corecontrollers.ProgressDeadline
+	}
+	sequential := rollout.Spec.UpdateMethod == corecontrollers.UpdateMethodSequential
+
+	for i := range rollout.Status.Workloads {
+		workload := &rollout.Status.Workloads[i]
+		now := metav1.Now()
+		switch workload.Phase {
+		case configuratorgopaddleiov1alpha1.WorkloadRolloutPending:
+			obj, err := corecontrollers.RollConsumer(ctx, r.Client, rollout.Namespace, workload.ConsumerReference, key, rollout.Spec.Version)
+			if errors.IsNotFound(err) {
+				workload.Phase = configuratorgopaddleiov1alpha1.WorkloadRolloutSkipped
+				workload.Message = "workload deleted"
+				continue
+			}
+			if err != nil {
+				workload.Message = err.Error()
+				r.EventRecorder.Eventf(rollout, corev1.EventTypeWarning, "FailedRollout", "Error rolling %v %v to version %v: %v", workload.Kind, workload.Name, rollout.Spec.Version, err)
+				return "", err
+			}
+			workload.Phase = configuratorgopaddleiov1alpha1.WorkloadRolloutProgressing
+			workload.Generation = obj.GetGeneration()
+			workload.StartedAt = &now
+			workload.Message = ""
+			r.EventRecorder.Eventf(rollout, corev1.EventTypeNormal, "RolledOut", "%v %v rolled to version %v", workload.Kind, workload.Name, rollout.Spec.Version)
+			if sequential {
+				return "", nil
+			}
+		case configuratorgopaddleiov1alpha1.WorkloadRolloutProgressing:
+			obj, err := corecontrollers.GetConsumer(ctx, r.Client, rollout.Namespace, workload.ConsumerReference)
+			if errors.IsNotFound(err) {
+				workload.Phase = configuratorgopaddleiov1alpha1.WorkloadRolloutSkipped
+				workload.Message = "workload deleted"
+				continue
+			}
+			if err != nil {
+				return "", err
+			}
+			ready, err := corecontrollers.ConsumerReady(obj)
+			if err != nil {
+				workload.Message = err.Error()
+				r.EventRecorder.Eventf(rollout, corev1.EventTypeWarning, "FailedRollout", "Error checking the readiness of %v %v: %v", workload.Kind, workload.Name, err)
+				return "", err
+			}
+			if obj.GetGeneration() >= workload.Generation && ready {
+				workload.Phase = configuratorgopaddleiov1alpha1.WorkloadRolloutAvailable
+				workload.AvailableAt = &now
+				workload.Message = ""
+				r.EventRecorder.Eventf(rollout, corev1.EventTypeNormal, "WorkloadAvailable", "%v %v available with version %v", workload.Kind, workload.Name, rollout.Spec.Version)
+				continue
+			}
+			if workload.StartedAt != nil && now.Sub(workload.StartedAt.Time) > deadline {
+				workload.Phase = configuratorgopaddleiov1alpha1.WorkloadRolloutTimedOut
+				workload.Message = fmt.Sprintf("not available within %v", deadline)
+				return fmt.Sprintf("%s %s not available within %v", workload.Kind, workload.Name, deadline), nil
+			}
+			if sequential {
+				return "", nil
+			}
+		}
+	}
+	return "", nil
+}
+
+//verify checks the health of the rolled workloads against the auto rollback policy
+//of the rollout. It returns why the rollout fails, if a workload restarted more
+//often than allowed or lost its readiness once available.
+func (r *ConfigRolloutReconciler) verify(ctx context.Context, rollout *configuratorgopaddleiov1alpha1.ConfigRollout) (string, error) {
+	key := "ccm-" + rollout.Spec.Name
+	if rollout.Spec.Kind == "Secret" {
+		key = "cs-" + rollout.Spec.Name
+	}
+	for i := range rollout.Status.Workloads {
+		workload := &rollout.Status.Workloads[i]
+		if workload.Phase != configuratorgopaddleiov1alpha1.WorkloadRolloutProgressing && workload.Phase != configuratorgopaddleiov1alpha1.WorkloadRolloutAvailable {
+			continue
+		}
+		obj, err := corecontrollers.GetConsumer(ctx, r.Client, rollout.Namespace, workload.ConsumerReference)
+		if errors.IsNotFound(err) {
+			workload.Phase = configuratorgopaddleiov1alpha1.WorkloadRolloutSkipped
+			workload.Message = "workload deleted"
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		restarts, err := corecontrollers.ConsumerRestarts(ctx, r.Client, obj, key, rollout.Spec.Version)
+		if err != nil {
+			return "", err
+		}
+		workload.Restarts = restarts
+		if restarts > rollout.Spec.AutoRollback.MaxRestarts {
+			workload.Message = fmt.Sprintf("containers restarted %d times", restarts)
+			return fmt.Sprintf("%s %s containers restarted %d times with version %s, more than %d", workload.Kind, workload.Name, restarts, rollout.Spec.Version, rollout.Spec.AutoRollback.MaxRestarts), nil
+		}
+		if workload.Phase != configuratorgopaddleiov1alpha1.WorkloadRolloutAvailable {
+			continue
+		}
+		ready, err := corecontrollers.ConsumerReady(obj)
+		if err != nil {
+			return "", err
+		}
+		if !ready {
+			workload.Message = "not ready anymore"
+			return fmt.Sprintf("%s %s not ready anymore with version %s", workload.Kind, workload.Name, rollout.Spec.Version), nil
+		}
+	}
+	return "", nil
+}
+
+//rollback rolls the configMap or secret of a failed rollout back to the previous
+//version through a ConfigRollback, and records why on the failed revision
+func (r *ConfigRolloutReconciler) rollback(ctx context.Context, rollout *configuratorgopaddleiov1alpha1.ConfigRollout, config client.Object, reason string) error {
+	if rollout.Spec.PreviousVersion == "" {
+		return r.finish(ctx, rollout, configuratorgopaddleiov1alpha1.ConfigRolloutHalted, reason+", no previous version to roll back to")
+	}
+
+	//a rollout of a version rolled out before is recreated under the same name, the
+	//uid keeps the rollback of the previous one from being taken for this one
+	rollback := &configuratorgopaddleiov1alpha1.ConfigRollback{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rollout.Name + "-rollback-" + string(rollout.UID)[:8],
+			Namespace: rollout.Namespace,
+			Labels:    map[string]string{"name": rollout.Spec.Name},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(rollout, configuratorgopaddleiov1alpha1.GroupVersion.WithKind("ConfigRollout")),
+			},
+		},
+		Spec: configuratorgopaddleiov1alpha1.ConfigRollbackSpec{
+			Kind:     rollout.Spec.Kind,
+			Name:     rollout.Spec.Name,
+			Revision: rollout.Spec.PreviousVersion,
+		},
+	}
+	if err := r.Create(ctx, rollback); err != nil && !errors.IsAlreadyExists(err) {
+		r.EventRecorder.Eventf(rollout, corev1.EventTypeWarning, "FailedAutoRollback", "Error rolling back to version %s: %v", rollout.Spec.PreviousVersion, err)
+		return err
+	}
+
+	condition := metav1.Condition{
+		Type:    "RolledBack",
+		Status:  metav1.ConditionTrue,
+		Reason:  "AutoRollback",
+		Message: reason,
+	}
+	var err error
+	if rollout.Spec.Kind == "Secret" {
+		var cs *configuratorgopaddleiov1alpha1.CustomSecret
+		cs, err = corecontrollers.GetCustomSecretByVersion(ctx, r.Client, rollout.Namespace, rollout.Spec.Name, rollout.Spec.Version)
+		if err == nil {
+			meta.SetStatusCondition(&cs.Status.Conditions, condition)
+			err = r.Status().Update(ctx, cs)
+		}
+	} else {
+		var ccm *configuratorgopaddleiov1alpha1.CustomConfigMap
+		ccm, err = corecontrollers.GetCustomConfigMapByVersion(ctx, r.Client, rollout.Namespace, rollout.Spec.Name, rollout.Spec.Version)
+		if err == nil {
+			meta.SetStatusCondition(&ccm.Status.Conditions, condition)
+			err = r.Status().Update(ctx, ccm)
+		}
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	r.EventRecorder.Eventf(config, corev1.EventTypeWarning, "AutoRollback", "version %s rolled back to %s: %s", rollout.Spec.Version, rollout.Spec.PreviousVersion, reason)
+	rollout.Status.RollbackName = rollback.Name
+	return r.finish(ctx, rollout, configuratorgopaddleiov1alpha1.ConfigRolloutRolledBack, reason)
+}
+
+//finish records the outcome of the rollout in its status
+func (r *ConfigRolloutReconciler) finish(ctx context.Context, rollout *configuratorgopaddleiov1alpha1.ConfigRollout, phase configuratorgopaddleiov1alpha1.ConfigRolloutPhase, message string) error {
+	now := metav1.Now()
+	rollout.Status.Phase = phase
+	rollout.Status.Message = message
+	rollout.Status.CompletedAt = &now
+	switch phase {
+	case configuratorgopaddleiov1alpha1.ConfigRolloutCompleted:
+		meta.SetStatusCondition(&rollout.Status.Conditions, metav1.Condition{
+			Type:    "Complete",
+			Status:  metav1.ConditionTrue,
+			Reason:  "RolloutCompleted",
+			Message: fmt.Sprintf("%d workloads available with version %s", len(rollout.Status.Workloads), rollout.Spec.Version),
+		})
+		r.EventRecorder.Eventf(rollout, corev1.EventTypeNormal, "RolloutCompleted", "%s %s rolled out version %s", rollout.Spec.Kind, rollout.Spec.Name, rollout.Spec.Version)
+	case configuratorgopaddleiov1alpha1.ConfigRolloutSuperseded:
+		meta.SetStatusCondition(&rollout.Status.Conditions, metav1.Condition{
+			Type:    "Complete",
+			Status:  metav1.ConditionFalse,
+			Reason:  "RolloutSuperseded",
+			Message: message,
+		})
+		r.EventRecorder.Eventf(rollout, corev1.EventTypeNormal, "RolloutSuperseded", "rollout of version %s superseded: %s", rollout.Spec.Version, message)
+	case configuratorgopaddleiov1alpha1.ConfigRolloutRolledBack:
+		meta.SetStatusCondition(&rollout.Status.Conditions, metav1.Condition{
+			Type:    "Complete",
+			Status:  metav1.ConditionFalse,
+			Reason:  "RolloutRolledBack",
+			Message: message,
+		})
+		r.EventRecorder.Eventf(rollout, corev1.EventTypeWarning, "RolloutRolledBack", "rollout of version %s rolled back to %s by %s: %s", rollout.Spec.Version, rollout.Spec.PreviousVersion, rollout.Status.RollbackName, message)
+		metrics.Rollout(rollout.Namespace, rollout.Spec.Kind, metrics.RolloutFailed)
+	default:
+		meta.SetStatusCondition(&rollout.Status.Conditions, metav1.Condition{
+			Type:    "Complete",
+			Status:  metav1.ConditionFalse,
+			Reason:  "RolloutHalted",
+			Message: message,
+		})
+		r.EventRecorder.Eventf(rollout, corev1.EventTypeWarning, "RolloutHalted", "rollout of version %s halted: %s", rollout.Spec.Version, message)
+		metrics.Rollout(rollout.Namespace, rollout.Spec.Kind, metrics.RolloutFailed)
+	}
+	return r.Status().Update(ctx, rollout)
+}
+
+//rolloutFinished reports whether the rollout completed, halted, was superseded or was rolled back
+func rolloutFinished(phase configuratorgopaddleiov1alpha1.ConfigRolloutPhase) bool {
+	return phase == configuratorgopaddleiov1alpha1.ConfigRolloutCompleted || phase == configuratorgopaddleiov1alpha1.ConfigRolloutHalted ||
+		phase == configuratorgopaddleiov1alpha1.ConfigRolloutSuperseded || phase == configuratorgopaddleiov1alpha1.ConfigRolloutRolledBack
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ConfigRolloutReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&configuratorgopaddleiov1alpha1.ConfigRollout{}).
+		Complete(r)
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configuratorgopaddleio
+
+import (
+	"context"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	corecontrollers "github.com/gopaddle-io/configurator/controllers/core"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsV1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+//waitForConsumers waits for the index of the manager cache to list count
+//workloads referencing the configMap
+func waitForConsumers(ctx context.Context, namespace string, configMap string, count int) {
+	Eventually(func() ([]configuratorgopaddleiov1alpha1.ConsumerReference, error) {
+		return index.Consumers(ctx, testClient, namespace, index.ConfigMapField, configMap)
+	}).Should(HaveLen(count))
+}
+
+var _ = Describe("ConfigRollout", func() {
+	var (
+		ctx        context.Context
+		namespace  string
+		reconciler *ConfigRolloutReconciler
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		namespace = newNamespace(ctx, "rollout")
+		reconciler = &ConfigRolloutReconciler{Client: testClient, Scheme: scheme.Scheme, EventRecorder: record.NewFakeRecorder(1024)}
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app",
+				Namespace:   namespace,
+				Annotations: map[string]string{"currentCustomConfigMapVersion": "v2"},
+			},
+			Data: map[string]string{"key": "two"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+		Expect(k8sClient.Create(ctx, newDeployment(namespace, "api", "app", "v1"))).To(Succeed())
+		Expect(k8sClient.Create(ctx, newDeployment(namespace, "web", "app", "v1"))).To(Succeed())
+		waitForConsumers(ctx, namespace, "app", 2)
+	})
+
+	startRollout := func(method string, autoRollback *configuratorgopaddleiov1alpha1.AutoRollbackPolicy) *configuratorgopaddleiov1alpha1.ConfigRollout {
+		rollout := &configuratorgopaddleiov1alpha1.ConfigRollout{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-v2", Namespace: namespace},
+			Spec: configuratorgopaddleiov1alpha1.ConfigRolloutSpec{
+				Kind:            "ConfigMap",
+				Name:            "app",
+				Version:         "v2",
+				PreviousVersion: "v1",
+				UpdateMethod:    method,
+				AutoRollback:    autoRollback,
+			},
+		}
+		Expect(k8sClient.Create(ctx, rollout)).To(Succeed())
+		return rollout
+	}
+
+	reconcile := func() *configuratorgopaddleiov1alpha1.ConfigRollout {
+		request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "app-v2"}}
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		rollout := &configuratorgopaddleiov1alpha1.ConfigRollout{}
+		Expect(k8sClient.Get(ctx, request.NamespacedName, rollout)).To(Succeed())
+		return rollout
+	}
+
+	pinned := func(name string) string {
+		var deployment appsV1.Deployment
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &deployment)).To(Succeed())
+		return deployment.Spec.Template.Annotations["ccm-app"]
+	}
+
+	//markAvailable reports the deployment rolled out, the way the deployment controller would
+	markAvailable := func(name string) {
+		var deployment appsV1.Deployment
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &deployment)).To(Succeed())
+		deployment.Status = appsV1.DeploymentStatus{
+			ObservedGeneration: deployment.Generation,
+			Replicas:           1,
+			UpdatedReplicas:    1,
+			AvailableReplicas:  1,
+		}
+		Expect(k8sClient.Status().Update(ctx, &deployment)).To(Succeed())
+	}
+
+	It("rolls every workload at once with rollAll and completes once they are available", func() {
+		startRollout(corecontrollers.UpdateMethodRollAll, nil)
+		rollout := reconcile()
+		Expect(rollout.Status.Phase).To(Equal(configuratorgopaddleiov1alpha1.ConfigRolloutProgressing))
+		Expect(rollout.Status.Workloads).To(HaveLen(2))
+		for _, workload := range rollout.Status.Workloads {
+			Expect(workload.Phase).To(Equal(configuratorgopaddleiov1alpha1.WorkloadRolloutProgressing))
+		}
+		Expect(pinned("api")).To(Equal("v2"))
+		Expect(pinned("web")).To(Equal("v2"))
+
+		markAvailable("api")
+		markAvailable("web")
+		rollout = reconcile()
+		Expect(rollout.Status.Phase).To(Equal(configuratorgopaddleiov1alpha1.ConfigRolloutCompleted))
+	})
+
+	It("rolls one workload at a time with sequential", func() {
+		startRollout(corecontrollers.UpdateMethodSequential, nil)
+		rollout := reconcile()
+		Expect(rollout.Status.Workloads).To(HaveLen(2))
+		Expect(rollout.Status.Workloads[0].Name).To(Equal("api"))
+		Expect(rollout.Status.Workloads[0].Phase).To(Equal(configuratorgopaddleiov1alpha1.WorkloadRolloutProgressing))
+		Expect(rollout.Status.Workloads[1].Phase).To(Equal(configuratorgopaddleiov1alpha1.WorkloadRolloutPending))
+		Expect(pinned("api")).To(Equal("v2"))
+		Expect(pinned("web")).To(Equal("v1"))
+
+		markAvailable("api")
+		rollout = reconcile()
+		Expect(rollout.Status.Workloads[0].Phase).To(Equal(configuratorgopaddleiov1alpha1.WorkloadRolloutAvailable))
+		Expect(rollout.Status.Workloads[1].Phase).To(Equal(configuratorgopaddleiov1alpha1.WorkloadRolloutProgressing))
+		Expect(pinned("web")).To(Equal("v2"))
+
+		markAvailable("web")
+		rollout = reconcile()
+		Expect(rollout.Status.Phase).To(Equal(configuratorgopaddleiov1alpha1.ConfigRolloutCompleted))
+	})
+
+	It("halts when a newer version supersedes the rollout", func() {
+		startRollout(corecontrollers.UpdateMethodRollAll, nil)
+		var configMap corev1.ConfigMap
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "app"}, &configMap)).To(Succeed())
+		configMap.Annotations["currentCustomConfigMapVersion"] = "v3"
+		Expect(k8sClient.Update(ctx, &configMap)).To(Succeed())
+
+		rollout := reconcile()
+		Expect(rollout.Status.Phase).To(Equal(configuratorgopaddleiov1alpha1.ConfigRolloutSuperseded))
+		Expect(pinned("api")).To(Equal("v1"))
+	})
+
+	It("rolls back to the previous version when a workload loses its readiness", func() {
+		startRollout(corecontrollers.UpdateMethodRollAll, &configuratorgopaddleiov1alpha1.AutoRollbackPolicy{MaxRestarts: 3, WindowSeconds: 300})
+		reconcile()
+		markAvailable("api")
+		markAvailable("web")
+		rollout := reconcile()
+		Expect(rollout.Status.Phase).To(Equal(configuratorgopaddleiov1alpha1.ConfigRolloutVerifying))
+
+		var deployment appsV1.Deployment
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "api"}, &deployment)).To(Succeed())
+		deployment.Status.AvailableReplicas = 0
+		Expect(k8sClient.Status().Update(ctx, &deployment)).To(Succeed())
+
+		rollout = reconcile()
+		Expect(rollout.Status.Phase).To(Equal(configuratorgopaddleiov1alpha1.ConfigRolloutRolledBack))
+		var rollback configuratorgopaddleiov1alpha1.ConfigRollback
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: rollout.Status.RollbackName}, &rollback)).To(Succeed())
+		Expect(rollback.Spec).To(Equal(configuratorgopaddleiov1alpha1.ConfigRollbackSpec{Kind: "ConfigMap", Name: "app", Revision: "v1"}))
+		Expect(metav1.IsControlledBy(&rollback, rollout)).To(BeTrue())
+	})
+})
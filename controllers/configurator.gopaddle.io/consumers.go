@@ -0,0 +1,143 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configuratorgopaddleio
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	corecontrollers "github.com/gopaddle-io/configurator/controllers/core"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	workloadadapter "github.com/gopaddle-io/configurator/pkg/workload"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	batchV1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+//listConsumers returns the workloads in the namespace referencing the configMap
+//or secret name in the index field whose pod template annotation key
+//(ccm-<configMap> or cs-<secret>) is set to version
+func listConsumers(ctx context.Context, c client.Client, namespace string, field string, name string, key string, version string) ([]configuratorgopaddleiov1alpha1.ConsumerReference, error) {
+	consumers := []configuratorgopaddleiov1alpha1.ConsumerReference{}
+	if version == "" {
+		return consumers, nil
+	}
+
+	objs, err := index.ListConsumers(ctx, c, namespace, field, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		//jobs are pinned to the version they were created with
+		if job, ok := obj.(*batchV1.Job); ok && jobFinished(job) {
+			continue
+		}
+		if index.PodTemplate(obj).Annotations[key] == version {
+			consumers = append(consumers, index.Reference(obj))
+		}
+	}
+
+	sort.Slice(consumers, func(i, j int) bool {
+		if consumers[i].Kind != consumers[j].Kind {
+			return consumers[i].Kind < consumers[j].Kind
+		}
+		return consumers[i].Name < consumers[j].Name
+	})
+	return consumers, nil
+}
+
+//revisionRequests maps a workload to the revisions of every configMap or
+//secret referenced by its pod template annotations with the given prefix
+func revisionRequests(ctx context.Context, c client.Client, obj client.Object, prefix string, list client.ObjectList) []reconcile.Request {
+	var annotations map[string]string
+	switch workload := obj.(type) {
+	case *appsV1.Deployment:
+		annotations = workload.Spec.Template.Annotations
+	case *appsV1.StatefulSet:
+		annotations = workload.Spec.Template.Annotations
+	case *appsV1.DaemonSet:
+		annotations = workload.Spec.Template.Annotations
+	case *batchV1beta1.CronJob:
+		annotations = workload.Spec.JobTemplate.Spec.Template.Annotations
+	case *unstructured.Unstructured:
+		if adapter, ok := workloadadapter.ForGroupVersionKind(workload.GroupVersionKind()); ok {
+			annotations = adapter.TemplateAnnotations(workload.Object)
+		}
+	}
+
+	requests := []reconcile.Request{}
+	for key := range annotations {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if err := c.List(ctx, list, client.MatchingLabels{"name": strings.TrimPrefix(key, prefix)}, client.InNamespace(obj.GetNamespace())); err != nil {
+			klog.Errorf("Failed on listing revisions for '%s/%s': %v", obj.GetNamespace(), obj.GetName(), err.Error())
+			continue
+		}
+		switch revisions := list.(type) {
+		case *configuratorgopaddleiov1alpha1.CustomConfigMapList:
+			for _, ccm := range revisions.Items {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: ccm.Namespace, Name: ccm.Name}})
+			}
+		case *configuratorgopaddleiov1alpha1.CustomSecretList:
+			for _, cs := range revisions.Items {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: cs.Namespace, Name: cs.Name}})
+			}
+		}
+	}
+	return requests
+}
+
+//rollConsumers sets version on every workload in the namespace referencing the
+//configMap or secret name in the index field whose pod template carries the
+//annotation key, whatever version it currently runs. Jobs are left out as their
+//pod template is immutable. It returns the outcome for every workload, and an
+//error when one of them could not be rolled.
+func rollConsumers(ctx context.Context, c client.Client, namespace string, field string, name string, key string, version string) ([]configuratorgopaddleiov1alpha1.ConsumerRollbackStatus, error) {
+	results := []configuratorgopaddleiov1alpha1.ConsumerRollbackStatus{}
+	retryable := false
+	objs, err := index.ListConsumers(ctx, c, namespace, field, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if _, ok := obj.(*batchV1.Job); ok {
+			continue
+		}
+		if _, ok := index.PodTemplate(obj).Annotations[key]; !ok {
+			continue
+		}
+		consumer := index.Reference(obj)
+		result := configuratorgopaddleiov1alpha1.ConsumerRollbackStatus{ConsumerReference: consumer, Updated: true}
+		if _, err := corecontrollers.RollConsumer(ctx, c, namespace, consumer, key, version); err != nil {
+			klog.Errorf("Failed on updating %s '%s' Error: %s", consumer.Kind, consumer.Name, err.Error())
+			result.Updated = false
+			result.Message = err.Error()
+			retryable = retryable || !isTerminal(err) && !errors.IsInvalid(err)
+		}
+		results = append(results, result)
+	}
+	return results, consumerFailures(results, retryable)
+}
@@ -19,44 +19,156 @@ package configuratorgopaddleio
 import (
 	"context"
 
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	corecontrollers "github.com/gopaddle-io/configurator/controllers/core"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/workload"
 )
 
 // CustomConfigMapReconciler reconciles a CustomConfigMap object
 type CustomConfigMapReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=customconfigmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=customconfigmaps/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=customconfigmaps/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the CustomConfigMap object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// It keeps the status of a CustomConfigMap revision in sync with the
+// workloads running it and with the current label set by the ConfigMapReconciler.
+// CustomConfigMaps authored directly by users are recorded as staged revisions
+// and applied to their ConfigMap once labelled desired=true.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.8.3/pkg/reconcile
 func (r *CustomConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	logger := log.FromContext(ctx)
 
-	// your logic here
+	var ccm configuratorgopaddleiov1alpha1.CustomConfigMap
+	if err := r.Get(ctx, req.NamespacedName, &ccm); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
 
+	//revision authored directly instead of recorded from a configMap edit
+	if ccm.Annotations["customConfigMapVersion"] == "" {
+		duplicate, err := corecontrollers.AdoptCustomConfigMap(ctx, r.Client, r.EventRecorder, &ccm)
+		if err != nil {
+			logger.Error(err, "Unable to adopt customConfigMap")
+			return ctrl.Result{}, err
+		}
+		if duplicate {
+			//folded into the revision already holding its content
+			return ctrl.Result{}, nil
+		}
+	}
+	if err := corecontrollers.MaterializeCustomConfigMap(ctx, r.Client, r.EventRecorder, &ccm); err != nil {
+		logger.Error(err, "Unable to materialize customConfigMap")
+		return ctrl.Result{}, err
+	}
+	if ccm.Labels["desired"] == "true" {
+		if err := corecontrollers.ApplyCustomConfigMap(ctx, r.Client, r.EventRecorder, &ccm); err != nil {
+			logger.Error(err, "Unable to apply customConfigMap")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.updateStatus(ctx, &ccm); err != nil {
+		logger.Error(err, "Unable to update customConfigMap status")
+		return ctrl.Result{}, err
+	}
 	return ctrl.Result{}, nil
 }
 
+//updateStatus refreshes the consumers and the current state of the revision
+func (r *CustomConfigMapReconciler) updateStatus(ctx context.Context, ccm *configuratorgopaddleiov1alpha1.CustomConfigMap) error {
+	status := ccm.Status.DeepCopy()
+
+	consumers, err := listConsumers(ctx, r.Client, ccm.Namespace, index.ConfigMapField, ccm.Spec.ConfigMapName, "ccm-"+ccm.Spec.ConfigMapName, ccm.Annotations["customConfigMapVersion"])
+	if err != nil {
+		return err
+	}
+	status.Consumers = consumers
+	//a revision is superseded only once it has been current, the ones never applied
+	//to the configmap are staged
+	wasCurrent := ccm.Status.Current || ccm.Status.SupersededAt != nil
+	status.Current = ccm.Labels["current"] == "true"
+	if status.CreatedAt == nil {
+		createdAt := ccm.CreationTimestamp
+		status.CreatedAt = &createdAt
+	}
+	if status.Current {
+		status.SupersededAt = nil
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    "Current",
+			Status:  metav1.ConditionTrue,
+			Reason:  "CurrentRevision",
+			Message: "revision holds the content of configmap " + ccm.Spec.ConfigMapName,
+		})
+	} else if !wasCurrent {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    "Current",
+			Status:  metav1.ConditionFalse,
+			Reason:  "Staged",
+			Message: "revision has not been applied to configmap " + ccm.Spec.ConfigMapName,
+		})
+	} else {
+		if status.SupersededAt == nil {
+			now := metav1.Now()
+			status.SupersededAt = &now
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    "Current",
+			Status:  metav1.ConditionFalse,
+			Reason:  "Superseded",
+			Message: "revision was replaced by a newer revision of configmap " + ccm.Spec.ConfigMapName,
+		})
+	}
+
+	if equality.Semantic.DeepEqual(&ccm.Status, status) {
+		return nil
+	}
+	ccm.Status = *status
+	return r.Status().Update(ctx, ccm)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *CustomConfigMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	//re-evaluate the consumers of every revision referenced by a workload
+	workloadRevisions := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		return revisionRequests(context.Background(), mgr.GetClient(), obj, "ccm-", &configuratorgopaddleiov1alpha1.CustomConfigMapList{})
+	})
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&configuratorgopaddleiov1alpha1.CustomConfigMap{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &appsV1.Deployment{}}, workloadRevisions).
+		Watches(&source.Kind{Type: &appsV1.StatefulSet{}}, workloadRevisions).
+		Watches(&source.Kind{Type: &appsV1.DaemonSet{}}, workloadRevisions).
+		Watches(&source.Kind{Type: &batchV1beta1.CronJob{}}, workloadRevisions)
+	for _, adapter := range workload.Adapters() {
+		builder = builder.Watches(&source.Kind{Type: adapter.NewObject()}, workloadRevisions)
+	}
+	return builder.Complete(r)
 }
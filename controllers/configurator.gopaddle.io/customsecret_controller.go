@@ -19,44 +19,156 @@ package configuratorgopaddleio
 import (
 	"context"
 
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	corecontrollers "github.com/gopaddle-io/configurator/controllers/core"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/workload"
 )
 
 // CustomSecretReconciler reconciles a CustomSecret object
 type CustomSecretReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=customsecrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=customsecrets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=customsecrets/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the CustomSecret object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// It keeps the status of a CustomSecret revision in sync with the
+// workloads running it and with the current label set by the SecretReconciler.
+// CustomSecrets authored directly by users are recorded as staged revisions
+// and applied to their Secret once labelled desired=true.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.8.3/pkg/reconcile
 func (r *CustomSecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	logger := log.FromContext(ctx)
 
-	// your logic here
+	var cs configuratorgopaddleiov1alpha1.CustomSecret
+	if err := r.Get(ctx, req.NamespacedName, &cs); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
 
+	//revision authored directly instead of recorded from a secret edit
+	if cs.Annotations["customSecretVersion"] == "" {
+		duplicate, err := corecontrollers.AdoptCustomSecret(ctx, r.Client, r.EventRecorder, &cs)
+		if err != nil {
+			logger.Error(err, "Unable to adopt customSecret")
+			return ctrl.Result{}, err
+		}
+		if duplicate {
+			//folded into the revision already holding its content
+			return ctrl.Result{}, nil
+		}
+	}
+	if err := corecontrollers.MaterializeCustomSecret(ctx, r.Client, r.EventRecorder, &cs); err != nil {
+		logger.Error(err, "Unable to materialize customSecret")
+		return ctrl.Result{}, err
+	}
+	if cs.Labels["desired"] == "true" {
+		if err := corecontrollers.ApplyCustomSecret(ctx, r.Client, r.EventRecorder, &cs); err != nil {
+			logger.Error(err, "Unable to apply customSecret")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.updateStatus(ctx, &cs); err != nil {
+		logger.Error(err, "Unable to update customSecret status")
+		return ctrl.Result{}, err
+	}
 	return ctrl.Result{}, nil
 }
 
+//updateStatus refreshes the consumers and the current state of the revision
+func (r *CustomSecretReconciler) updateStatus(ctx context.Context, cs *configuratorgopaddleiov1alpha1.CustomSecret) error {
+	status := cs.Status.DeepCopy()
+
+	consumers, err := listConsumers(ctx, r.Client, cs.Namespace, index.SecretField, cs.Spec.SecretName, "cs-"+cs.Spec.SecretName, cs.Annotations["customSecretVersion"])
+	if err != nil {
+		return err
+	}
+	status.Consumers = consumers
+	//a revision is superseded only once it has been current, the ones never applied
+	//to the secret are staged
+	wasCurrent := cs.Status.Current || cs.Status.SupersededAt != nil
+	status.Current = cs.Labels["current"] == "true"
+	if status.CreatedAt == nil {
+		createdAt := cs.CreationTimestamp
+		status.CreatedAt = &createdAt
+	}
+	if status.Current {
+		status.SupersededAt = nil
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    "Current",
+			Status:  metav1.ConditionTrue,
+			Reason:  "CurrentRevision",
+			Message: "revision holds the content of secret " + cs.Spec.SecretName,
+		})
+	} else if !wasCurrent {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    "Current",
+			Status:  metav1.ConditionFalse,
+			Reason:  "Staged",
+			Message: "revision has not been applied to secret " + cs.Spec.SecretName,
+		})
+	} else {
+		if status.SupersededAt == nil {
+			now := metav1.Now()
+			status.SupersededAt = &now
+		}
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    "Current",
+			Status:  metav1.ConditionFalse,
+			Reason:  "Superseded",
+			Message: "revision was replaced by a newer revision of secret " + cs.Spec.SecretName,
+		})
+	}
+
+	if equality.Semantic.DeepEqual(&cs.Status, status) {
+		return nil
+	}
+	cs.Status = *status
+	return r.Status().Update(ctx, cs)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *CustomSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	//re-evaluate the consumers of every revision referenced by a workload
+	workloadRevisions := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		return revisionRequests(context.Background(), mgr.GetClient(), obj, "cs-", &configuratorgopaddleiov1alpha1.CustomSecretList{})
+	})
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&configuratorgopaddleiov1alpha1.CustomSecret{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &appsV1.Deployment{}}, workloadRevisions).
+		Watches(&source.Kind{Type: &appsV1.StatefulSet{}}, workloadRevisions).
+		Watches(&source.Kind{Type: &appsV1.DaemonSet{}}, workloadRevisions).
+		Watches(&source.Kind{Type: &batchV1beta1.CronJob{}}, workloadRevisions)
+	for _, adapter := range workload.Adapters() {
+		builder = builder.Watches(&source.Kind{Type: adapter.NewObject()}, workloadRevisions)
+	}
+	return builder.Complete(r)
 }
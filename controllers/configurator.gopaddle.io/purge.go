@@ -4,237 +4,288 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
-	client "github.com/gopaddle-io/configurator/pkg/client/clientset/versioned"
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+	"github.com/gopaddle-io/configurator/pkg/selection"
+	"github.com/gopaddle-io/configurator/pkg/workload"
 	"github.com/robfig/cron"
 	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-type CornJob struct {
-	Cron *cron.Cron
+//DefaultPurgeSchedule is how often unused revisions are purged when no schedule is given
+const DefaultPurgeSchedule = "@every 15m"
+
+//+kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=customconfigmaps,verbs=get;list;watch;delete
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=customsecrets,verbs=get;list;watch;delete
+
+//PurgeResult is the outcome of a purge run
+type PurgeResult struct {
+	StartedAt        time.Time `json:"startedAt,omitempty"`
+	CompletedAt      time.Time `json:"completedAt,omitempty"`
+	PurgedConfigMaps []string  `json:"purgedConfigMaps,omitempty"`
+	PurgedSecrets    []string  `json:"purgedSecrets,omitempty"`
+	//WouldPrune lists the revisions dry run retention policies would have pruned
+	WouldPrune []string `json:"wouldPrune,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
 }
 
-//trigger purge every 5 mins
-func PurgeJob() {
-	cron := CornJob{Cron: cron.New()}
-	go func() {
-		cron.Cron.AddFunc("@every 15m", func() {
-			PurgeCCMAndCS()
-		})
-		cron.Cron.Start()
-	}()
+//Purger removes the customConfigMaps and customSecrets no workload uses anymore,
+//neither in its pod template nor in the previous pod templates kept in its
+//revision history. It runs as a manager runnable on the leader only, and reads
+//the workloads and their revision history from the informer cache.
+type Purger struct {
+	client.Client
+	//Schedule is the cron schedule of the purge, DefaultPurgeSchedule when empty
+	Schedule string
+	//PolicyNamespace holds the RevisionRetentionPolicy applying to the namespaces without one
+	PolicyNamespace string
+	EventRecorder   record.EventRecorder
+
+	mu      sync.Mutex
+	lastRun PurgeResult
 }
 
-//it remove unused customConfigMap and customSecret
-func PurgeCCMAndCS() {
-	var cfg *rest.Config
-	var err error
-	cfg, err = rest.InClusterConfig()
-	//create clientset
-	clientSet, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		klog.Error("Error building kubernetes clientset: %s", err.Error(), time.Now().UTC())
-	}
+//NeedLeaderElection runs the purge on the leader only, so that replicas do not
+//race each other on deletes
+func (p *Purger) NeedLeaderElection() bool {
+	return true
+}
 
-	configuratorClientSet, err := client.NewForConfig(cfg)
-	if err != nil {
-		klog.Error("Error building example clientset: %s", err.Error())
+//Start purges on the schedule until the manager stops
+func (p *Purger) Start(ctx context.Context) error {
+	schedule := p.Schedule
+	if schedule == "" {
+		schedule = DefaultPurgeSchedule
 	}
-
-	//list all namespace
-	nsList, err := clientSet.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		klog.Errorf("Failed on listing Namespace: %v", err.Error())
+	c := cron.New()
+	if err := c.AddFunc(schedule, func() { p.Purge(ctx) }); err != nil {
+		return fmt.Errorf("invalid purge schedule %q: %v", schedule, err)
 	}
-	for _, ns := range nsList.Items {
-		//list all customConfigMap
-		ccmList, errs := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(ns.Name).List(context.TODO(), metav1.ListOptions{})
-		if errs != nil {
-			klog.Errorf("failed on listing customConfigMap: %v", errs.Error())
-		}
-
-		//check ccm is used
-		for _, ccm := range ccmList.Items {
-			configVersion := ccm.Annotations["customConfigMapVersion"]
-			configMapName := ccm.Spec.ConfigMapName
-			checkConfig := false
+	c.Start()
+	<-ctx.Done()
+	c.Stop()
+	return nil
+}
 
-			//get all deployment in the namespace
-			deploymentList, deperr := clientSet.AppsV1().Deployments(ns.Name).List(context.TODO(), metav1.ListOptions{})
-			if deperr != nil {
-				klog.Errorf("failed on getting deployment list ", deperr.Error())
-			}
-			for _, deploy := range deploymentList.Items {
-				selector, err := metav1.LabelSelectorAsSelector(deploy.Spec.Selector)
-				if err != nil {
-					klog.Infof("Failed get selector from deployment %v", err, time.Now().UTC())
-				}
-				options := metav1.ListOptions{LabelSelector: selector.String()}
-				allRSs, err := clientSet.AppsV1().ReplicaSets(ns.Name).List(context.TODO(), options)
-				if err != nil {
-					klog.Errorf(fmt.Sprintf("Failed on getting deployment list based on label for customConfigMap '%s'", ccm.Name), err, time.Now().UTC())
-				}
-				//list all revision for this deployment
-				for _, rs := range allRSs.Items {
-					templateAnnotation := rs.Spec.Template.Annotations
-					for key, value := range templateAnnotation {
-						if key == "ccm-"+configMapName && value == configVersion {
-							checkConfig = true
-						}
-					}
-				}
-			}
+//LastRun returns the outcome of the last purge run
+func (p *Purger) LastRun() PurgeResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastRun
+}
 
-			//get all statefulset in the namespace
-			stsList, stsErr := clientSet.AppsV1().StatefulSets(ns.Name).List(context.TODO(), metav1.ListOptions{})
-			if stsErr != nil {
-				klog.Errorf("Failed on getting statefulSet ", stsErr.Error())
-			}
+//ServeHTTP serves the outcome of the last purge run as json
+func (p *Purger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p.LastRun()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
 
-			for _, sts := range stsList.Items {
-				selector, err := metav1.LabelSelectorAsSelector(sts.Spec.Selector)
-				if err != nil {
-					klog.Infof("Failed get selector from deployment %v", err, time.Now().UTC())
-				}
-				options := metav1.ListOptions{LabelSelector: selector.String()}
-				allRevisions, err := clientSet.AppsV1().ControllerRevisions(ns.Name).List(context.TODO(), options)
-				if err != nil {
-					klog.Infof(fmt.Sprintf("Failed on getting sts list based on label  for customConfigMap'%s'", ccm.Name), err, time.Now().UTC())
-				}
-
-				for _, rs := range allRevisions.Items {
-					stsRevision := appsV1.StatefulSet{}
-					er := json.Unmarshal(rs.Data.Raw, &stsRevision)
-					if er != nil {
-						klog.Infof("failed on unmarshal", er.Error(), time.Now().UTC())
-					}
-					templateAnnotation := stsRevision.Spec.Template.Annotations
-					for key, value := range templateAnnotation {
-						if key == "ccm-"+configMapName && value == configVersion {
-							checkConfig = true
-						}
-					}
-				}
+//Purge removes the unused customConfigMaps and customSecrets once. Only the
+//revisions of a configMap or secret still referenced by a workload are purged,
+//the current revision is never purged and staged revisions are kept until they
+//are applied. The revision history is
+//then pruned the way the RevisionRetentionPolicies ask for.
+func (p *Purger) Purge(ctx context.Context) PurgeResult {
+	result := PurgeResult{StartedAt: time.Now().UTC()}
+	histories := make(map[string]*revisionHistory)
+	history := func(namespace string) (*revisionHistory, error) {
+		if histories[namespace] == nil {
+			h, err := p.listRevisionHistory(ctx, namespace)
+			if err != nil {
+				return nil, err
 			}
+			histories[namespace] = h
+		}
+		return histories[namespace], nil
+	}
 
-			//get configmap
-			configmap, conferr := clientSet.CoreV1().ConfigMaps(ns.Name).Get(context.TODO(), configMapName, metav1.GetOptions{})
-			if conferr != nil {
-				klog.Errorf(fmt.Sprintf("Failed on getting configmap '%s'", configMapName), "Error", conferr.Error(), time.Now().UTC())
-			}
-			if len(configmap.Annotations) != 0 {
-				if configmap.Annotations["deployments"] != "" || configmap.Annotations["statefulsets"] != "" {
-					if !checkConfig {
-						//purge ccm
-						err := configuratorClientSet.ConfiguratorV1alpha1().CustomConfigMaps(ns.Name).Delete(context.TODO(), ccm.Name, metav1.DeleteOptions{})
-						if err != nil {
-							klog.Errorf(fmt.Sprintf("Failed on parge customConfigMap '%s'", ccm.Name), "Error", err.Error(), time.Now().UTC())
-						} else {
-							klog.Infof(fmt.Sprintf("customConfigMap purged successfully '%s'", ccm.Name), time.Now().UTC())
-						}
-					}
-				}
-			}
+	var ccmList configuratorgopaddleiov1alpha1.CustomConfigMapList
+	if err := p.List(ctx, &ccmList); err != nil {
+		klog.Errorf("failed on listing customConfigMap: %v", err.Error())
+		result.Errors = append(result.Errors, err.Error())
+	}
+	for i := range ccmList.Items {
+		ccm := &ccmList.Items[i]
+		//staged revisions are kept until they are applied
+		if ccm.Labels["desired"] != "" || !selection.Namespace(ccm.Namespace) {
+			continue
+		}
+		purged := false
+		h, err := history(ccm.Namespace)
+		if err == nil {
+			purged, err = p.purgeRevision(ctx, ccm, index.ConfigMapField, ccm.Spec.ConfigMapName, "ccm-"+ccm.Spec.ConfigMapName, ccm.Annotations["customConfigMapVersion"], h)
+		}
+		if err != nil {
+			klog.Errorf("Failed on purge customConfigMap '%s/%s': %v", ccm.Namespace, ccm.Name, err.Error())
+			result.Errors = append(result.Errors, err.Error())
+		} else if purged {
+			klog.Infof("customConfigMap purged successfully '%s/%s'", ccm.Namespace, ccm.Name)
+			result.PurgedConfigMaps = append(result.PurgedConfigMaps, ccm.Namespace+"/"+ccm.Name)
+			metrics.RevisionPurged(ccm.Namespace, "CustomConfigMap")
 		}
+	}
 
-		//list all customSecret
-		csList, csErr := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(ns.Name).List(context.TODO(), metav1.ListOptions{})
-		if csErr != nil {
-			klog.Errorf("failed on listing customConfigMap: %v", csErr.Error())
+	var csList configuratorgopaddleiov1alpha1.CustomSecretList
+	if err := p.List(ctx, &csList); err != nil {
+		klog.Errorf("failed on listing customSecret: %v", err.Error())
+		result.Errors = append(result.Errors, err.Error())
+	}
+	for i := range csList.Items {
+		cs := &csList.Items[i]
+		//staged revisions are kept until they are applied
+		if cs.Labels["desired"] != "" || !selection.Namespace(cs.Namespace) {
+			continue
+		}
+		purged := false
+		h, err := history(cs.Namespace)
+		if err == nil {
+			purged, err = p.purgeRevision(ctx, cs, index.SecretField, cs.Spec.SecretName, "cs-"+cs.Spec.SecretName, cs.Annotations["customSecretVersion"], h)
+		}
+		if err != nil {
+			klog.Errorf("Failed on purge customSecret '%s/%s': %v", cs.Namespace, cs.Name, err.Error())
+			result.Errors = append(result.Errors, err.Error())
+		} else if purged {
+			klog.Infof("customSecret purged successfully '%s/%s'", cs.Namespace, cs.Name)
+			result.PurgedSecrets = append(result.PurgedSecrets, cs.Namespace+"/"+cs.Name)
+			metrics.RevisionPurged(cs.Namespace, "CustomSecret")
 		}
+	}
 
-		//check cs is used
+	//prune the revision history the retention policies do not keep
+	p.retain(ctx, &result, history)
 
-		for _, cs := range csList.Items {
-			secretVersion := cs.Annotations["customSecretVersion"]
-			secretName := cs.Spec.SecretName
-			checkSecret := false
+	result.CompletedAt = time.Now().UTC()
+	p.mu.Lock()
+	p.lastRun = result
+	p.mu.Unlock()
+	return result
+}
 
-			//get all deployment in the namespace
-			deploymentList, deperr := clientSet.AppsV1().Deployments(ns.Name).List(context.TODO(), metav1.ListOptions{})
-			if deperr != nil {
-				klog.Errorf("failed on getting deployment list ", deperr.Error())
-			}
+//purgeRevision deletes the revision when the workloads referencing the configMap
+//or secret name in the index field neither set key to version on their pod
+//template nor in their revision history. It reports whether the revision was purged.
+func (p *Purger) purgeRevision(ctx context.Context, revision client.Object, field string, name string, key string, version string, history *revisionHistory) (bool, error) {
+	//the configMap or secret holds the current revision, a restart or scale up of a
+	//workload not pinned to it yet rolls onto it
+	if revision.GetLabels()["current"] == "true" {
+		return false, nil
+	}
+	used, consumed, err := p.inUse(ctx, revision.GetNamespace(), field, name, key, version, history)
+	//only revisions of a configMap or secret referenced by a workload are purged
+	if err != nil || used || !consumed {
+		return false, err
+	}
+	if err := p.Delete(ctx, revision); err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
 
-			for _, deploy := range deploymentList.Items {
-				selector, err := metav1.LabelSelectorAsSelector(deploy.Spec.Selector)
-				if err != nil {
-					klog.Infof("Failed get selector from deployment %v", err, time.Now().UTC())
-				}
-				options := metav1.ListOptions{LabelSelector: selector.String()}
-				allRSs, err := clientSet.AppsV1().ReplicaSets(ns.Name).List(context.TODO(), options)
-				if err != nil {
-					klog.Errorf(fmt.Sprintf("Failed on getting deployment list based on label for customConfigMap '%s'", cs.Name), err, time.Now().UTC())
-				}
-				//list all revision for this deployment
-				for _, rs := range allRSs.Items {
-					templateAnnotation := rs.Spec.Template.Annotations
-					for key, value := range templateAnnotation {
-						if key == "cs-"+secretName && value == secretVersion {
-							checkSecret = true
-						}
-					}
-				}
-			}
+//inUse reports whether a workload referencing the configMap or secret name in the
+//index field sets key to version on its pod template or in its revision history,
+//and whether any workload references the configMap or secret at all
+func (p *Purger) inUse(ctx context.Context, namespace string, field string, name string, key string, version string, history *revisionHistory) (used bool, consumed bool, err error) {
+	consumers, err := index.ListConsumers(ctx, p.Client, namespace, field, name)
+	if err != nil || len(consumers) == 0 {
+		return false, false, err
+	}
+	owners := make(map[types.UID]client.Object)
+	for _, obj := range consumers {
+		//a running job keeps the version it was pinned to
+		if job, ok := obj.(*batchV1.Job); ok && jobFinished(job) {
+			continue
+		}
+		if index.PodTemplate(obj).Annotations[key] == version {
+			return true, true, nil
+		}
+		owners[obj.GetUID()] = obj
+	}
+	return history.uses(owners, key, version), true, nil
+}
 
-			//get all statefulset in the namespace
-			stsList, stsErr := clientSet.AppsV1().StatefulSets(ns.Name).List(context.TODO(), metav1.ListOptions{})
-			if stsErr != nil {
-				klog.Errorf("Failed on getting statefulSet ", stsErr.Error())
-			}
+//revisionHistory holds the replicaSets and controllerRevisions of a namespace,
+//where the previous pod templates of the workloads are kept
+type revisionHistory struct {
+	replicaSets []appsV1.ReplicaSet
+	revisions   []appsV1.ControllerRevision
+}
 
-			for _, sts := range stsList.Items {
-				selector, err := metav1.LabelSelectorAsSelector(sts.Spec.Selector)
-				if err != nil {
-					klog.Infof("Failed get selector from deployment %v", err, time.Now().UTC())
-				}
-				options := metav1.ListOptions{LabelSelector: selector.String()}
-				allRevisions, err := clientSet.AppsV1().ControllerRevisions(ns.Name).List(context.TODO(), options)
-				if err != nil {
-					klog.Infof(fmt.Sprintf("Failed on getting sts list based on label  for customConfigMap'%s'", cs.Name), err, time.Now().UTC())
-				}
-
-				for _, rs := range allRevisions.Items {
-					stsRevision := appsV1.StatefulSet{}
-					er := json.Unmarshal(rs.Data.Raw, &stsRevision)
-					if er != nil {
-						klog.Infof("failed on unmarshal", er.Error(), time.Now().UTC())
-					}
-					templateAnnotation := stsRevision.Spec.Template.Annotations
-					for key, value := range templateAnnotation {
-						if key == "cs-"+secretName && value == secretVersion {
-							checkSecret = true
-						}
-					}
-				}
-			}
+func (p *Purger) listRevisionHistory(ctx context.Context, namespace string) (*revisionHistory, error) {
+	var replicaSets appsV1.ReplicaSetList
+	if err := p.List(ctx, &replicaSets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	var revisions appsV1.ControllerRevisionList
+	if err := p.List(ctx, &revisions, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	return &revisionHistory{replicaSets: replicaSets.Items, revisions: revisions.Items}, nil
+}
 
-			//get secret
-			secret, secretErr := clientSet.CoreV1().Secrets(ns.Name).Get(context.TODO(), secretName, metav1.GetOptions{})
-			if secretErr != nil {
-				klog.Errorf(fmt.Sprintf("Failed on getting secret '%s'", secretName), "Error", secretErr.Error(), time.Now().UTC())
-			}
-			if len(secret.Annotations) != 0 {
-				if secret.Annotations["deployments"] != "" || secret.Annotations["statefulsets"] != "" {
-					if !checkSecret {
-						//purge ccm
-						err := configuratorClientSet.ConfiguratorV1alpha1().CustomSecrets(ns.Name).Delete(context.TODO(), cs.Name, metav1.DeleteOptions{})
-						if err != nil {
-							klog.Errorf(fmt.Sprintf("Failed on parge customSecret '%s'", cs.Name), "Error", err.Error(), time.Now().UTC())
-						} else {
-							klog.Infof(fmt.Sprintf("customSecret purged successfully '%s'", cs.Name), time.Now().UTC())
-						}
-					}
-				}
+//uses reports whether a previous pod template of one of the owners sets key to version
+func (h *revisionHistory) uses(owners map[types.UID]client.Object, key string, version string) bool {
+	for _, rs := range h.replicaSets {
+		if ownerOf(rs.OwnerReferences, owners) != nil && rs.Spec.Template.Annotations[key] == version {
+			return true
+		}
+	}
+	for _, revision := range h.revisions {
+		owner := ownerOf(revision.OwnerReferences, owners)
+		if owner == nil {
+			continue
+		}
+		data := make(map[string]interface{})
+		if err := json.Unmarshal(revision.Data.Raw, &data); err != nil {
+			klog.Infof("failed on unmarshal controllerRevision %s: %v", revision.Name, err.Error())
+			continue
+		}
+		annotations, _, _ := unstructured.NestedStringMap(data, "spec", "template", "metadata", "annotations")
+		if u, ok := owner.(*unstructured.Unstructured); ok {
+			if adapter, found := workload.ForGroupVersionKind(u.GroupVersionKind()); found {
+				annotations = adapter.TemplateAnnotations(data)
 			}
-
 		}
+		if annotations[key] == version {
+			return true
+		}
+	}
+	return false
+}
 
+//ownerOf returns the owner one of the owner references points to
+func ownerOf(references []metav1.OwnerReference, owners map[types.UID]client.Object) client.Object {
+	for _, reference := range references {
+		if owner, ok := owners[reference.UID]; ok {
+			return owner
+		}
 	}
+	return nil
+}
 
+//jobFinished reports whether the job completed or failed
+func jobFinished(job *batchV1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchV1.JobComplete || condition.Type == batchV1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
 }
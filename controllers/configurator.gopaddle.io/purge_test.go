@@ -0,0 +1,189 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configuratorgopaddleio
+
+import (
+	"context"
+	"strings"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsV1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Purge", func() {
+	var (
+		ctx       context.Context
+		namespace string
+		purger    *Purger
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		namespace = newNamespace(ctx, "purge")
+		purger = &Purger{Client: testClient, EventRecorder: record.NewFakeRecorder(1024)}
+	})
+
+	//newRevision creates a revision of the configMap holding version
+	newRevision := func(configMap string, version string, labels map[string]string, data map[string]string) {
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels["name"] = configMap
+		ccm := &configuratorgopaddleiov1alpha1.CustomConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        configMap + "-" + version,
+				Namespace:   namespace,
+				Labels:      labels,
+				Annotations: map[string]string{"customConfigMapVersion": version},
+			},
+			Spec: configuratorgopaddleiov1alpha1.CustomConfigMapSpec{
+				ConfigMapName: configMap,
+				Data:          data,
+			},
+		}
+		Expect(k8sClient.Create(ctx, ccm)).To(Succeed())
+	}
+
+	exists := func(name string) bool {
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &configuratorgopaddleiov1alpha1.CustomConfigMap{})
+		if errors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	It("purges the revisions no workload uses anymore", func() {
+		newRevision("app", "old", nil, map[string]string{"key": "old"})
+		newRevision("app", "current", map[string]string{"current": "true"}, map[string]string{"key": "current"})
+		newRevision("app", "staged", map[string]string{"desired": "false"}, map[string]string{"key": "staged"})
+		newRevision("app", "pinned", nil, map[string]string{"key": "pinned"})
+		newRevision("app", "history", nil, map[string]string{"key": "history"})
+
+		deployment := newDeployment(namespace, "web", "app", "pinned")
+		Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+		//the previous pod template of the deployment pins the history version
+		template := *deployment.Spec.Template.DeepCopy()
+		template.Annotations["ccm-app"] = "history"
+		replicaSet := &appsV1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "web-history",
+				Namespace:       namespace,
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment, appsV1.SchemeGroupVersion.WithKind("Deployment"))},
+			},
+			Spec: appsV1.ReplicaSetSpec{Selector: deployment.Spec.Selector, Template: template},
+		}
+		Expect(k8sClient.Create(ctx, replicaSet)).To(Succeed())
+		waitForConsumers(ctx, namespace, "app", 1)
+
+		result := purger.Purge(ctx)
+		Expect(result.PurgedConfigMaps).To(ContainElement(namespace + "/app-old"))
+		Expect(exists("app-old")).To(BeFalse())
+		Expect(exists("app-current")).To(BeTrue())
+		Expect(exists("app-staged")).To(BeTrue())
+		Expect(exists("app-pinned")).To(BeTrue())
+		Expect(exists("app-history")).To(BeTrue())
+		Expect(purger.LastRun().PurgedConfigMaps).To(Equal(result.PurgedConfigMaps))
+	})
+
+	It("keeps the revisions of a configMap no workload references", func() {
+		newRevision("app", "old", nil, map[string]string{"key": "old"})
+		newRevision("app", "current", map[string]string{"current": "true"}, map[string]string{"key": "current"})
+
+		purger.Purge(ctx)
+		Expect(exists("app-old")).To(BeTrue())
+		Expect(exists("app-current")).To(BeTrue())
+	})
+
+	It("reports the revisions a dry run retention policy would prune", func() {
+		newRevision("app", "old", nil, map[string]string{"key": strings.Repeat("x", 20)})
+		newRevision("app", "current", map[string]string{"current": "true"}, map[string]string{"key": "x"})
+		maxBytes := int64(10)
+		policy := &configuratorgopaddleiov1alpha1.RevisionRetentionPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: DefaultRetentionPolicy, Namespace: namespace},
+			Spec: configuratorgopaddleiov1alpha1.RevisionRetentionPolicySpec{
+				MaxBytes: &maxBytes,
+				DryRun:   true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+		result := purger.Purge(ctx)
+		Expect(result.WouldPrune).To(ContainElement(namespace + "/app-old"))
+		Expect(result.WouldPrune).NotTo(ContainElement(namespace + "/app-current"))
+		Expect(exists("app-old")).To(BeTrue())
+
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: DefaultRetentionPolicy}, policy)).To(Succeed())
+		Expect(policy.Status.Pruned).To(ConsistOf(configuratorgopaddleiov1alpha1.PrunedRevision{
+			Kind:      "CustomConfigMap",
+			Namespace: namespace,
+			Name:      "app-old",
+			Reason:    "maxBytes",
+		}))
+		condition := meta.FindStatusCondition(policy.Status.Conditions, "Enforced")
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal("DryRun"))
+	})
+
+	It("never prunes the current revision to honour keepRevisions", func() {
+		newRevision("app", "old", nil, map[string]string{"key": "old"})
+		newRevision("app", "current", map[string]string{"current": "true"}, map[string]string{"key": "current"})
+		keepRevisions := int32(1)
+		policy := &configuratorgopaddleiov1alpha1.RevisionRetentionPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: DefaultRetentionPolicy, Namespace: namespace},
+			Spec:       configuratorgopaddleiov1alpha1.RevisionRetentionPolicySpec{KeepRevisions: &keepRevisions},
+		}
+		Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+		purger.Purge(ctx)
+		Expect(exists("app-current")).To(BeTrue())
+	})
+	It("counts the stringData of a customSecret against maxBytes", func() {
+		cs := &configuratorgopaddleiov1alpha1.CustomSecret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "creds-old",
+				Namespace:   namespace,
+				Labels:      map[string]string{"name": "creds"},
+				Annotations: map[string]string{"customSecretVersion": "old"},
+			},
+			Spec: configuratorgopaddleiov1alpha1.CustomSecretSpec{
+				SecretName: "creds",
+				StringData: map[string]string{"password": strings.Repeat("x", 20)},
+			},
+		}
+		Expect(k8sClient.Create(ctx, cs)).To(Succeed())
+		maxBytes := int64(10)
+		policy := &configuratorgopaddleiov1alpha1.RevisionRetentionPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: DefaultRetentionPolicy, Namespace: namespace},
+			Spec: configuratorgopaddleiov1alpha1.RevisionRetentionPolicySpec{
+				MaxBytes: &maxBytes,
+				DryRun:   true,
+			},
+		}
+		Expect(k8sClient.Create(ctx, policy)).To(Succeed())
+
+		result := purger.Purge(ctx)
+		Expect(result.WouldPrune).To(ContainElement(namespace + "/creds-old"))
+	})
+})
@@ -0,0 +1,250 @@
+package configuratorgopaddleio
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+	"github.com/gopaddle-io/configurator/pkg/selection"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//DefaultRetentionPolicy is the name of the RevisionRetentionPolicy applying to a namespace
+const DefaultRetentionPolicy = "default"
+
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=revisionretentionpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=revisionretentionpolicies/status,verbs=get;update;patch
+
+//retainedRevision is a customConfigMap or customSecret in the revision history of
+//a configMap or secret
+type retainedRevision struct {
+	obj     client.Object
+	kind    string
+	field   string
+	name    string
+	key     string
+	version string
+	size    int64
+}
+
+//retain prunes the revision history of every configMap and secret the way the
+//RevisionRetentionPolicy of its namespace, or else the one in PolicyNamespace,
+//asks for. The newest revisions are kept first, and revisions labelled current
+//or desired, or still referenced by a workload or its revision history, are
+//never pruned.
+func (p *Purger) retain(ctx context.Context, result *PurgeResult, history func(string) (*revisionHistory, error)) {
+	var policyList configuratorgopaddleiov1alpha1.RevisionRetentionPolicyList
+	if err := p.List(ctx, &policyList); err != nil {
+		klog.Errorf("failed on listing revisionRetentionPolicy: %v", err.Error())
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+	policies := make(map[string]*configuratorgopaddleiov1alpha1.RevisionRetentionPolicy)
+	for i := range policyList.Items {
+		if policyList.Items[i].Name == DefaultRetentionPolicy {
+			policies[policyList.Items[i].Namespace] = &policyList.Items[i]
+		}
+	}
+	if len(policies) == 0 {
+		return
+	}
+	policyFor := func(namespace string) *configuratorgopaddleiov1alpha1.RevisionRetentionPolicy {
+		if policy, ok := policies[namespace]; ok {
+			return policy
+		}
+		return policies[p.PolicyNamespace]
+	}
+
+	purged := make(map[string]bool)
+	for _, name := range append(result.PurgedConfigMaps, result.PurgedSecrets...) {
+		purged[name] = true
+	}
+	histories, err := p.listRetainedRevisions(ctx)
+	if err != nil {
+		klog.Errorf("failed on listing revisions for retention: %v", err.Error())
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+
+	pruned := make(map[*configuratorgopaddleiov1alpha1.RevisionRetentionPolicy][]configuratorgopaddleiov1alpha1.PrunedRevision)
+	for _, revisions := range histories {
+		namespace := revisions[0].obj.GetNamespace()
+		policy := policyFor(namespace)
+		if policy == nil || !selection.Namespace(namespace) {
+			continue
+		}
+		h, err := history(namespace)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		kept := int32(0)
+		bytes := int64(0)
+		for _, revision := range revisions {
+			if purged[namespace+"/"+revision.obj.GetName()] {
+				continue
+			}
+			reason := ""
+			protected, err := p.retained(ctx, revision, h)
+			if err != nil {
+				result.Errors = append(result.Errors, err.Error())
+				protected = true
+			}
+			if !protected {
+				reason = policyReason(&policy.Spec, kept, bytes, revision)
+			}
+			if reason == "" {
+				kept++
+				bytes += revision.size
+				continue
+			}
+
+			prunedRevision := configuratorgopaddleiov1alpha1.PrunedRevision{Kind: revision.kind, Namespace: namespace, Name: revision.obj.GetName(), Reason: reason}
+			if policy.Spec.DryRun {
+				result.WouldPrune = append(result.WouldPrune, namespace+"/"+revision.obj.GetName())
+				pruned[policy] = append(pruned[policy], prunedRevision)
+				continue
+			}
+			if err := p.Delete(ctx, revision.obj); err != nil && !errors.IsNotFound(err) {
+				klog.Errorf("Failed on pruning %s '%s/%s': %v", revision.kind, namespace, revision.obj.GetName(), err.Error())
+				result.Errors = append(result.Errors, err.Error())
+				continue
+			}
+			klog.Infof("%s pruned by retention policy '%s/%s': %s", revision.kind, namespace, revision.obj.GetName(), reason)
+			metrics.RevisionPurged(namespace, revision.kind)
+			if revision.kind == "CustomSecret" {
+				result.PurgedSecrets = append(result.PurgedSecrets, namespace+"/"+revision.obj.GetName())
+			} else {
+				result.PurgedConfigMaps = append(result.PurgedConfigMaps, namespace+"/"+revision.obj.GetName())
+			}
+			pruned[policy] = append(pruned[policy], prunedRevision)
+		}
+	}
+
+	for _, policy := range policies {
+		if err := p.recordRetention(ctx, policy, pruned[policy]); err != nil {
+			klog.Errorf("Failed on updating revisionRetentionPolicy '%s/%s': %v", policy.Namespace, policy.Name, err.Error())
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+}
+
+//policyReason returns why the policy prunes the revision once kept newer revisions
+//of bytes in total are kept, or an empty string when it keeps the revision
+func policyReason(policy *configuratorgopaddleiov1alpha1.RevisionRetentionPolicySpec, kept int32, bytes int64, revision retainedRevision) string {
+	if policy.KeepRevisions != nil && kept >= *policy.KeepRevisions {
+		return "keepRevisions"
+	}
+	if policy.MaxAge != nil && time.Since(revision.obj.GetCreationTimestamp().Time) > policy.MaxAge.Duration {
+		return "maxAge"
+	}
+	if policy.MaxBytes != nil && bytes+revision.size > *policy.MaxBytes {
+		return "maxBytes"
+	}
+	return ""
+}
+
+//retained reports whether the revision is never pruned: labelled current or
+//desired, or referenced by a workload or its revision history
+func (p *Purger) retained(ctx context.Context, revision retainedRevision, history *revisionHistory) (bool, error) {
+	labels := revision.obj.GetLabels()
+	if labels["current"] == "true" || labels["desired"] != "" {
+		return true, nil
+	}
+	used, _, err := p.inUse(ctx, revision.obj.GetNamespace(), revision.field, revision.name, revision.key, revision.version, history)
+	return used, err
+}
+
+//listRetainedRevisions returns the revision history of every configMap and secret,
+//newest first
+func (p *Purger) listRetainedRevisions(ctx context.Context) (map[string][]retainedRevision, error) {
+	histories := make(map[string][]retainedRevision)
+	var ccmList configuratorgopaddleiov1alpha1.CustomConfigMapList
+	if err := p.List(ctx, &ccmList); err != nil {
+		return nil, err
+	}
+	for i := range ccmList.Items {
+		ccm := &ccmList.Items[i]
+		size := int64(0)
+		for _, v := range ccm.Spec.Data {
+			size += int64(len(v))
+		}
+		for _, v := range ccm.Spec.BinaryData {
+			size += int64(len(v))
+		}
+		key := "ConfigMap/" + ccm.Namespace + "/" + ccm.Spec.ConfigMapName
+		histories[key] = append(histories[key], retainedRevision{
+			obj:     ccm,
+			kind:    "CustomConfigMap",
+			field:   index.ConfigMapField,
+			name:    ccm.Spec.ConfigMapName,
+			key:     "ccm-" + ccm.Spec.ConfigMapName,
+			version: ccm.Annotations["customConfigMapVersion"],
+			size:    size,
+		})
+	}
+
+	var csList configuratorgopaddleiov1alpha1.CustomSecretList
+	if err := p.List(ctx, &csList); err != nil {
+		return nil, err
+	}
+	for i := range csList.Items {
+		cs := &csList.Items[i]
+		size := int64(0)
+		for _, v := range cs.Spec.Data {
+			size += int64(len(v))
+		}
+		for _, v := range cs.Spec.StringData {
+			size += int64(len(v))
+		}
+		key := "Secret/" + cs.Namespace + "/" + cs.Spec.SecretName
+		histories[key] = append(histories[key], retainedRevision{
+			obj:     cs,
+			kind:    "CustomSecret",
+			field:   index.SecretField,
+			name:    cs.Spec.SecretName,
+			key:     "cs-" + cs.Spec.SecretName,
+			version: cs.Annotations["customSecretVersion"],
+			size:    size,
+		})
+	}
+
+	for _, revisions := range histories {
+		sort.SliceStable(revisions, func(i, j int) bool {
+			return revisions[j].obj.GetCreationTimestamp().Time.Before(revisions[i].obj.GetCreationTimestamp().Time)
+		})
+	}
+	return histories, nil
+}
+
+//recordRetention records the revisions pruned by the last run in the status of the policy
+func (p *Purger) recordRetention(ctx context.Context, policy *configuratorgopaddleiov1alpha1.RevisionRetentionPolicy, pruned []configuratorgopaddleiov1alpha1.PrunedRevision) error {
+	now := metav1.Now()
+	policy.Status.LastRunTime = &now
+	policy.Status.Pruned = pruned
+	policy.Status.ObservedGeneration = policy.Generation
+	condition := metav1.Condition{
+		Type:    "Enforced",
+		Status:  metav1.ConditionTrue,
+		Reason:  "RevisionsPruned",
+		Message: fmt.Sprintf("%d revisions pruned", len(pruned)),
+	}
+	if policy.Spec.DryRun {
+		condition.Reason = "DryRun"
+		condition.Message = fmt.Sprintf("%d revisions would be pruned", len(pruned))
+	}
+	meta.SetStatusCondition(&policy.Status.Conditions, condition)
+	if len(pruned) != 0 && p.EventRecorder != nil {
+		p.EventRecorder.Eventf(policy, corev1.EventTypeNormal, condition.Reason, condition.Message)
+	}
+	return p.Status().Update(ctx, policy)
+}
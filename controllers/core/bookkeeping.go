@@ -0,0 +1,265 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/selection"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+//where the revision bookkeeping of configMaps and secrets is kept
+const (
+	//BookkeepingAnnotations keeps the current version, the current revision and the
+	//updateMethod in annotations of the configMap or secret
+	BookkeepingAnnotations = "annotations"
+	//BookkeepingIndex keeps them in a ConfigIndex, the configMap or secret is only
+	//written when a revision is restored
+	BookkeepingIndex = "index"
+)
+
+var bookkeeping = BookkeepingAnnotations
+
+//SetBookkeeping selects where the revision bookkeeping is kept, annotations or index
+func SetBookkeeping(mode string) error {
+	switch mode {
+	case BookkeepingAnnotations, BookkeepingIndex:
+		bookkeeping = mode
+		return nil
+	}
+	return fmt.Errorf("unknown bookkeeping %s, expected annotations or index", mode)
+}
+
+//IndexBookkeeping reports whether the bookkeeping is kept in ConfigIndexes
+func IndexBookkeeping() bool {
+	return bookkeeping == BookkeepingIndex
+}
+
+//configState is the revision bookkeeping of a configMap or secret
+type configState struct {
+	//Version is the version the configMap or secret holds
+	Version string
+	//Revision is the customConfigMap or customSecret holding Version
+	Revision     string
+	UpdateMethod string
+}
+
+//ConfigIndexName returns the name of the ConfigIndex of a configMap or secret
+func ConfigIndexName(kind string, name string) string {
+	return strings.ToLower(kind) + "-" + name
+}
+
+func configKind(obj client.Object) string {
+	if _, ok := obj.(*corev1.Secret); ok {
+		return "Secret"
+	}
+	return "ConfigMap"
+}
+
+func versionAnnotation(kind string) string {
+	if kind == "Secret" {
+		return "currentCustomSecretVersion"
+	}
+	return "currentCustomConfigMapVersion"
+}
+
+func revisionAnnotation(kind string) string {
+	if kind == "Secret" {
+		return "customSecret-name"
+	}
+	return "customConfigMap-name"
+}
+
+func indexField(kind string) string {
+	if kind == "Secret" {
+		return index.SecretField
+	}
+	return index.ConfigMapField
+}
+
+//getConfigState returns the revision bookkeeping of a configMap or secret. The
+//updateMethod of a ConfigIndex takes precedence over the updateMethod annotation.
+func getConfigState(ctx context.Context, c client.Reader, obj client.Object) (configState, error) {
+	kind := configKind(obj)
+	annotations := obj.GetAnnotations()
+	state := configState{UpdateMethod: UpdateMethod(annotations)}
+	if !IndexBookkeeping() {
+		state.Version = annotations[versionAnnotation(kind)]
+		state.Revision = annotations[revisionAnnotation(kind)]
+		return state, nil
+	}
+
+	var configIndex configuratorgopaddleiov1alpha1.ConfigIndex
+	err := c.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: ConfigIndexName(kind, obj.GetName())}, &configIndex)
+	if errors.IsNotFound(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	state.Version = configIndex.Status.CurrentVersion
+	state.Revision = configIndex.Status.CurrentRevision
+	if configIndex.Spec.UpdateMethod != "" {
+		state.UpdateMethod = configIndex.Spec.UpdateMethod
+	}
+	return state, nil
+}
+
+//CurrentVersion returns the version a configMap or secret currently holds
+func CurrentVersion(ctx context.Context, c client.Reader, obj client.Object) (string, error) {
+	state, err := getConfigState(ctx, c, obj)
+	return state.Version, err
+}
+
+//currentRevisionVersion returns the version of the revision labelled current of the
+//configMap (CustomConfigMapList) or secret (CustomSecretList) name
+func currentRevisionVersion(ctx context.Context, c client.Reader, namespace string, name string, list client.ObjectList) (string, error) {
+	if err := c.List(ctx, list, client.MatchingLabels{"name": name, "current": "true"}, client.InNamespace(namespace)); err != nil {
+		return "", err
+	}
+	switch revisions := list.(type) {
+	case *configuratorgopaddleiov1alpha1.CustomConfigMapList:
+		if len(revisions.Items) != 0 {
+			return revisions.Items[0].Annotations["customConfigMapVersion"], nil
+		}
+	case *configuratorgopaddleiov1alpha1.CustomSecretList:
+		if len(revisions.Items) != 0 {
+			return revisions.Items[0].Annotations["customSecretVersion"], nil
+		}
+	}
+	return "", nil
+}
+
+//updateConfig updates the configMap or secret and records version and revision as
+//its current revision. With annotation bookkeeping they are written in the same
+//update, with index bookkeeping the ConfigIndex is written before the configMap or
+//secret is updated, so that the reconcile the update triggers never compares its
+//content to the previous version. An unchanged configMap or secret is left as it is.
+func updateConfig(ctx context.Context, c client.Client, obj client.Object, version string, revision string) error {
+	kind := configKind(obj)
+	if !IndexBookkeeping() {
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[versionAnnotation(kind)] = version
+		annotations[revisionAnnotation(kind)] = revision
+		annotations["updateMethod"] = UpdateMethod(annotations)
+		obj.SetAnnotations(annotations)
+		return c.Update(ctx, obj)
+	}
+
+	configIndex, err := getOrCreateConfigIndex(ctx, c, obj)
+	if err != nil {
+		return err
+	}
+	configIndex.Status.CurrentVersion = version
+	configIndex.Status.CurrentRevision = revision
+	consumers, err := index.Consumers(ctx, c, obj.GetNamespace(), indexField(kind), obj.GetName())
+	if err != nil {
+		return err
+	}
+	configIndex.Status.Consumers = consumers
+	if err := c.Status().Update(ctx, configIndex); err != nil {
+		return err
+	}
+	return c.Update(ctx, obj)
+}
+
+//recordConsumers refreshes the consumers in the ConfigIndex of a configMap or secret
+func recordConsumers(ctx context.Context, c client.Client, obj client.Object) error {
+	kind := configKind(obj)
+	var configIndex configuratorgopaddleiov1alpha1.ConfigIndex
+	err := c.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: ConfigIndexName(kind, obj.GetName())}, &configIndex)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	consumers, err := index.Consumers(ctx, c, obj.GetNamespace(), indexField(kind), obj.GetName())
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(consumers, configIndex.Status.Consumers) || (len(consumers) == 0 && len(configIndex.Status.Consumers) == 0) {
+		return nil
+	}
+	configIndex.Status.Consumers = consumers
+	return c.Status().Update(ctx, &configIndex)
+}
+
+//getOrCreateConfigIndex returns the ConfigIndex of a configMap or secret, creating
+//it owned by the configMap or secret when there is none yet
+func getOrCreateConfigIndex(ctx context.Context, c client.Client, obj client.Object) (*configuratorgopaddleiov1alpha1.ConfigIndex, error) {
+	kind := configKind(obj)
+	configIndex := &configuratorgopaddleiov1alpha1.ConfigIndex{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: ConfigIndexName(kind, obj.GetName())}, configIndex)
+	if err == nil || !errors.IsNotFound(err) {
+		return configIndex, err
+	}
+	configIndex = &configuratorgopaddleiov1alpha1.ConfigIndex{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigIndexName(kind, obj.GetName()),
+			Namespace: obj.GetNamespace(),
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(obj, corev1.SchemeGroupVersion.WithKind(kind)),
+			},
+		},
+		Spec: configuratorgopaddleiov1alpha1.ConfigIndexSpec{
+			Kind: kind,
+			Name: obj.GetName(),
+		},
+	}
+	if err := c.Create(ctx, configIndex); err != nil {
+		return nil, err
+	}
+	return configIndex, nil
+}
+
+//selectedConfigs filters the configMap and secret events down to the selection
+var selectedConfigs = predicate.NewPredicateFuncs(func(obj client.Object) bool {
+	return selection.Object(obj)
+})
+
+//referencedConfigs maps a workload to the configMaps (or secrets) its pod
+//template references, to keep the consumers of their ConfigIndex up to date
+func referencedConfigs(secrets bool) func(client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		configMaps, secretNames := index.References(obj)
+		names := configMaps
+		if secrets {
+			names = secretNames
+		}
+		requests := []reconcile.Request{}
+		if !selection.Namespace(obj.GetNamespace()) {
+			return requests
+		}
+		for _, name := range names {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}})
+		}
+		return requests
+	}
+}
@@ -18,16 +18,20 @@ package core
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"math/rand"
 	"reflect"
 	"regexp"
 	"strings"
-	"time"
 
 	customConfigMapv1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
-	appsV1 "k8s.io/api/apps/v1"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+	"github.com/gopaddle-io/configurator/pkg/selection"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -35,7 +39,10 @@ import (
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // ConfigMapReconciler reconciles a ConfigMap object
@@ -50,6 +57,8 @@ var log = ctrl.Log.WithName("ConfigMapController")
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=configmaps/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=core,resources=configmaps/finalizers,verbs=update
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=configindexes,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=configurator.gopaddle.io,resources=configindexes/status,verbs=get;update;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -71,11 +80,30 @@ func (r *ConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		} else {
 			log.Error(err, configMaplogname+" Unable to get configMap")
 		}
+		if errors.IsNotFound(err) {
+			forgetPendingChange("ConfigMap", req.Namespace, req.Name)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(nil)
 	}
+	//configMaps left out by the selection are not versioned
+	if !selection.ConfigMap(&configMap) {
+		forgetPendingChange("ConfigMap", req.Namespace, req.Name)
+		return ctrl.Result{}, nil
+	}
 
-	//get currentCCM version from configMap
-	currentCCM_Version := configMap.Annotations["currentCustomConfigMapVersion"]
+	//get currentCCM version from configMap bookkeeping
+	state, err := getConfigState(ctx, r.Client, &configMap)
+	if err != nil {
+		log.Error(err, configMaplogname+" Unable to get configIndex")
+		return ctrl.Result{}, err
+	}
+	currentCCM_Version := state.Version
+	//coalesce edits inside the quiet period into a single revision
+	if currentCCM_Version != "" {
+		if wait := debounce(r.EventRecorder, &configMap, currentCCM_Version, configMapVersion(&configMap)); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
 	// check the CCM_version in the configMap if the Version not exist.
 	// it create new version of CCM and add annotation to that configMap
 	if currentCCM_Version == "" {
@@ -89,25 +117,31 @@ func (r *ConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		if len(ccmList.Items) == 0 {
 			ccm, version := newCustomConfigMap(&configMap)
 			er := r.Create(ctx, ccm)
+			if er == nil {
+				metrics.RevisionCreated(ccm.Namespace, "CustomConfigMap")
+			} else if errors.IsAlreadyExists(er) {
+				//revision with the same content already recorded, make it current
+				existing := &customConfigMapv1alpha1.CustomConfigMap{}
+				er = r.Get(ctx, types.NamespacedName{Namespace: ccm.Namespace, Name: ccm.Name}, existing)
+				if er == nil {
+					er = sameCustomConfigMapContent(existing, ccm)
+				}
+				if er == nil {
+					er = markCurrentCustomConfigMap(ctx, r.Client, r.EventRecorder, existing)
+				}
+				ccm = existing
+			}
 			if er != nil {
 				r.EventRecorder.Eventf(&configMap, corev1.EventTypeWarning, "FailedCreateCustomConfigMap", "Error creating CustomConfigMap: %v", er.Error())
 				return ctrl.Result{}, er
 			}
-
-			//updating configMap with versionInfo
-			if len(configMap.Annotations) == 0 {
-				annotation := make(map[string]string)
-				annotation["currentCustomConfigMapVersion"] = version
-				annotation["customConfigMap-name"] = ccm.Name
-				annotation["updateMethod"] = "ignoreWhenShared"
-				configMap.Annotations = annotation
-			} else {
-				configMap.Annotations["currentCustomConfigMapVersion"] = version
-				configMap.Annotations["customConfigMap-name"] = ccm.Name
-				configMap.Annotations["updateMethod"] = "ignoreWhenShared"
+			//the revision the workloads get pinned to has to exist before their pods start
+			if er := MaterializeCustomConfigMap(ctx, r.Client, r.EventRecorder, ccm); er != nil {
+				return ctrl.Result{}, er
 			}
 
-			errs := r.Update(ctx, &configMap)
+			//updating configMap with versionInfo
+			errs := updateConfig(ctx, r.Client, &configMap, version, ccm.Name)
 			if errs != nil {
 				r.EventRecorder.Eventf(&configMap, corev1.EventTypeWarning, "FailedAddingCustomConfigMapVersion", "Error in adding CustomConfigMap version: %v", errs.Error())
 				return ctrl.Result{}, errs
@@ -121,20 +155,11 @@ func (r *ConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 						return ctrl.Result{}, errs
 					}
 				} else {
-					//updating configMap with versionInfo
-					if len(configMap.Annotations) == 0 {
-						annotation := make(map[string]string)
-						annotation["currentCustomConfigMapVersion"] = ccmList.Items[0].Annotations["customConfigMapVersion"]
-						annotation["customConfigMap-name"] = ccmList.Items[0].Name
-						annotation["updateMethod"] = "ignoreWhenShared"
-						configMap.Annotations = annotation
-					} else {
-						configMap.Annotations["currentCustomConfigMapVersion"] = ccmList.Items[0].Annotations["customConfigMapVersion"]
-						configMap.Annotations["customConfigMap-name"] = ccmList.Items[0].Name
-						configMap.Annotations["updateMethod"] = "ignoreWhenShared"
+					if errs := MaterializeCustomConfigMap(ctx, r.Client, r.EventRecorder, &ccmList.Items[0]); errs != nil {
+						return ctrl.Result{}, errs
 					}
-
-					errs := r.Update(ctx, &configMap)
+					//updating configMap with versionInfo
+					errs := updateConfig(ctx, r.Client, &configMap, ccmList.Items[0].Annotations["customConfigMapVersion"], ccmList.Items[0].Name)
 					if errs != nil {
 						r.EventRecorder.Eventf(&configMap, corev1.EventTypeWarning, "FailedAddingCustomConfigMapVersion", "Error in adding CustomConfigMap version: %v", errs.Error())
 						return ctrl.Result{}, errs
@@ -146,25 +171,38 @@ func (r *ConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	} else {
 		// version exist it compare the configMap content with currentCCM
-		er := r.UpdateConfigMap(ctx, &configMap)
+		er := r.UpdateConfigMap(ctx, &configMap, currentCCM_Version)
 		if er != nil {
 			r.EventRecorder.Eventf(&configMap, corev1.EventTypeNormal, "FailedCreateCustomConfigMapVersion", "Error in creating CustomConfigMap: %v", er.Error())
 			return ctrl.Result{}, er
 		}
 	}
+	if IndexBookkeeping() {
+		err = recordConsumers(ctx, r.Client, &configMap)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 	return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ConfigMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.ConfigMap{}).
-		Complete(r)
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(selectedConfigs))
+	if IndexBookkeeping() {
+		//keep the consumers in the configIndexes up to date
+		for _, obj := range index.WorkloadObjects() {
+			b = b.Watches(&source.Kind{Type: obj}, handler.EnqueueRequestsFromMapFunc(referencedConfigs(false)))
+		}
+	}
+	return b.Complete(r)
 }
 
 // newCustomConfigMap creates a new customConfigMap for a ConfigMap resource. It also sets
 // the appropriate OwnerReferences on the resource so handleObject can discover
-// the ConfigMap resource that 'owns' it.
+// the ConfigMap resource that 'owns' it. The version is derived from the content,
+// so the same content always maps to the same customConfigMap name.
 func newCustomConfigMap(configmap *corev1.ConfigMap) (*customConfigMapv1alpha1.CustomConfigMap, string) {
 	labels := map[string]string{
 		"name":    configmap.Name,
@@ -172,7 +210,7 @@ func newCustomConfigMap(configmap *corev1.ConfigMap) (*customConfigMapv1alpha1.C
 		"current": "true",
 	}
 
-	version := RandomSequence(5)
+	version := configMapVersion(configmap)
 	name := fmt.Sprintf("%s-%s", configmap.Name, version)
 	configName := NameValidation(name)
 	annotation := map[string]string{
@@ -198,7 +236,7 @@ func newCustomConfigMap(configmap *corev1.ConfigMap) (*customConfigMapv1alpha1.C
 }
 
 //Update ConfigMap
-func (r *ConfigMapReconciler) UpdateConfigMap(ctx context.Context, configMap *corev1.ConfigMap) error {
+func (r *ConfigMapReconciler) UpdateConfigMap(ctx context.Context, configMap *corev1.ConfigMap, version string) error {
 	//get CCM latest version
 	var configMaplogname string = configMap.Namespace + "/" + configMap.Name
 	var ccmList customConfigMapv1alpha1.CustomConfigMapList
@@ -209,9 +247,9 @@ func (r *ConfigMapReconciler) UpdateConfigMap(ctx context.Context, configMap *co
 	}
 	if len(ccmList.Items) == 1 {
 		//checking configmap annotation version with currentCCM version
-		if ccmList.Items[0].Annotations["customConfigMapVersion"] != configMap.Annotations["currentCustomConfigMapVersion"] {
+		if ccmList.Items[0].Annotations["customConfigMapVersion"] != version {
 			//calling copy CCM to CM function
-			er := r.CopyCCMToCM(ctx, configMap)
+			er := r.CopyCCMToCM(ctx, configMap, version)
 			if er != nil {
 				return er
 			}
@@ -238,13 +276,8 @@ func (r *ConfigMapReconciler) UpdateConfigMap(ctx context.Context, configMap *co
 }
 
 //copyCCMtoCM
-func (r *ConfigMapReconciler) CopyCCMToCM(ctx context.Context, configmap *corev1.ConfigMap) error {
-	var ccm customConfigMapv1alpha1.CustomConfigMap
-	ccmNameNamespace := types.NamespacedName{
-		Namespace: configmap.Namespace,
-		Name:      configmap.Name + "-" + configmap.Annotations["currentCustomConfigMapVersion"],
-	}
-	er := r.Get(ctx, ccmNameNamespace, &ccm)
+func (r *ConfigMapReconciler) CopyCCMToCM(ctx context.Context, configmap *corev1.ConfigMap, version string) error {
+	ccm, er := GetCustomConfigMapByVersion(ctx, r.Client, configmap.Namespace, configmap.Name, version)
 	if er != nil {
 		r.EventRecorder.Eventf(configmap, corev1.EventTypeWarning, "FailedGetCustomConfigMap", "Error Getting CustomConfigMap: %v", er.Error())
 		return er
@@ -254,16 +287,16 @@ func (r *ConfigMapReconciler) CopyCCMToCM(ctx context.Context, configmap *corev1
 	configmap.Data = ccm.Spec.Data
 	configmap.BinaryData = ccm.Spec.BinaryData
 	//Update configMap content based on configmap version
-	err := r.Update(ctx, configmap)
+	err := updateConfig(ctx, r.Client, configmap, version, ccm.Name)
 	if err != nil {
 		return err
 	}
 
 	//update CCM with current
 	ccm.Labels["current"] = "true"
-	errs := r.Update(ctx, &ccm)
+	errs := r.Update(ctx, ccm)
 	if errs != nil {
-		r.EventRecorder.Eventf(&ccm, corev1.EventTypeWarning, "FailedUpdatingCustomConfigMap", "Error Updating CustomConfigMap: %v", errs)
+		r.EventRecorder.Eventf(ccm, corev1.EventTypeWarning, "FailedUpdatingCustomConfigMap", "Error Updating CustomConfigMap: %v", errs)
 		return errs
 	}
 
@@ -272,6 +305,24 @@ func (r *ConfigMapReconciler) CopyCCMToCM(ctx context.Context, configmap *corev1
 }
 
 func (r *ConfigMapReconciler) CreateNewCCM(ctx context.Context, configMap *corev1.ConfigMap, currentccm *customConfigMapv1alpha1.CustomConfigMap) error {
+	ccmNew, version := newCustomConfigMap(configMap)
+	if version == currentccm.Annotations["customConfigMapVersion"] {
+		return nil
+	}
+
+	//check the content was already recorded by an earlier revision
+	reuse := true
+	existingccm, er := GetCustomConfigMapByVersion(ctx, r.Client, configMap.Namespace, configMap.Name, version)
+	if er != nil {
+		if !errors.IsNotFound(er) {
+			return er
+		}
+		reuse = false
+	} else if er = sameCustomConfigMapContent(existingccm, ccmNew); er != nil {
+		r.EventRecorder.Eventf(configMap, corev1.EventTypeWarning, "FailedCreateCustomConfigMap", "Error creating CustomConfigMap: %v", er)
+		return er
+	}
+
 	//delete current label from previous currentCCM
 	delete(currentccm.Labels, "current")
 	errs := r.Update(ctx, currentccm)
@@ -289,6 +340,9 @@ func (r *ConfigMapReconciler) CreateNewCCM(ctx context.Context, configMap *corev
 		if err != nil {
 			r.EventRecorder.Eventf(&latestccm, corev1.EventTypeWarning, "FailedUpdatingCustomConfigMap", "Error Updating CustomConfigMap in getting latest label: %v", errs)
 		}
+		if len(latestccm.Items) == 0 {
+			break
+		}
 		delete(latestccm.Items[0].Labels, "latest")
 		errs = r.Update(ctx, &latestccm.Items[0])
 		if errs != nil {
@@ -310,8 +364,16 @@ func (r *ConfigMapReconciler) CreateNewCCM(ctx context.Context, configMap *corev
 		}
 	}
 
-	ccmNew, version := newCustomConfigMap(configMap)
-	er := r.Create(ctx, ccmNew)
+	if reuse {
+		//make the earlier revision current and latest again instead of creating a duplicate
+		er = r.Get(ctx, types.NamespacedName{Namespace: existingccm.Namespace, Name: existingccm.Name}, existingccm)
+		if er == nil {
+			er = markCurrentCustomConfigMap(ctx, r.Client, r.EventRecorder, existingccm)
+		}
+		ccmNew = existingccm
+	} else {
+		er = r.Create(ctx, ccmNew)
+	}
 	if er != nil {
 		r.EventRecorder.Eventf(ccmNew, corev1.EventTypeWarning, "FailedCreateCustomConfigMap", "Error creating CustomConfigMap: %v", er)
 		//reverting the previous change
@@ -321,101 +383,235 @@ func (r *ConfigMapReconciler) CreateNewCCM(ctx context.Context, configMap *corev
 			r.EventRecorder.Eventf(currentccm, corev1.EventTypeWarning, "FailedUpdatingCustomConfigMap", "Error Updating CustomConfigMap: %v", errs)
 			return errs
 		}
-		latestccm.Items[0].Labels["latest"] = "true"
-		errs = r.Update(ctx, &latestccm.Items[0])
-		if errs != nil {
-			r.EventRecorder.Eventf(&latestccm, corev1.EventTypeWarning, "FailedUpdatingCustomConfigMap", "Error Updating CustomConfigMap: %v", errs)
-			return errs
+		if len(latestccm.Items) != 0 {
+			latestccm.Items[0].Labels["latest"] = "true"
+			errs = r.Update(ctx, &latestccm.Items[0])
+			if errs != nil {
+				r.EventRecorder.Eventf(&latestccm, corev1.EventTypeWarning, "FailedUpdatingCustomConfigMap", "Error Updating CustomConfigMap: %v", errs)
+				return errs
+			}
 		}
 		return er
 	}
+	if reuse {
+		r.EventRecorder.Eventf(configMap, corev1.EventTypeNormal, "reuseCustomConfigMap", "content matches ccm %v, making it current again", ccmNew.Name)
+	} else {
+		metrics.RevisionCreated(ccmNew.Namespace, "CustomConfigMap")
+	}
 
 	//update config map with version and ccm name
-	configMap.Annotations["currentCustomConfigMapVersion"] = version
-	configMap.Annotations["customConfigMap-name"] = ccmNew.Name
-	configMap.Annotations["updateMethod"] = "ignoreWhenShared"
-	err := r.Update(ctx, configMap)
+	err := updateConfig(ctx, r.Client, configMap, version, ccmNew.Name)
 	if err != nil {
 		return err
 	}
 	r.EventRecorder.Eventf(configMap, corev1.EventTypeNormal, "updateConfigMap", "update ccm version %v and name %v", version, ccmNew.Name)
 
-	//trigger rolling Update for kind=deployment
-	if configMap.Annotations["deployments"] != "" {
-		annotation := configMap.Annotations["deployments"]
-		split := strings.Split(annotation, ",")
-		if configMap.Annotations["updateMethod"] == "ignoreWhenShared" {
-			if len(split) > 1 {
-				klog.Error("can't trigger rolling update updateMethod is ignoreWhenShared")
-				return errors.NewBadRequest("can't trigger rolling update updateMethod is ignoreWhenShared")
-			} else {
-				var deployment appsV1.Deployment
-				deployNameNamespace := types.NamespacedName{
-					Namespace: configMap.Namespace,
-					Name:      split[0],
-				}
-				err := r.Get(ctx, deployNameNamespace, &deployment)
-				if err != nil {
-					klog.Error("Failed on getting deployment '%s' Error: %s", split[0], err.Error)
-					return err
-				}
-				//update new version
-				deployment.Spec.Template.Annotations["ccm-"+configMap.Name] = version
-				errs = r.Update(ctx, &deployment)
-				if errs != nil {
-					klog.Error("Failed on updating deployment '%s' Error: %s", split[0], errs.Error)
-					return errs
-				}
+	//the revision the workloads are rolled to has to exist before their pods start
+	if err := MaterializeCustomConfigMap(ctx, r.Client, r.EventRecorder, ccmNew); err != nil {
+		return err
+	}
+	return RolloutConfigMap(ctx, r.Client, r.EventRecorder, configMap, version, currentccm.Annotations["customConfigMapVersion"])
+}
+
+//configMapVersion returns a stable version for the content of the configMap
+func configMapVersion(configmap *corev1.ConfigMap) string {
+	content, _ := json.Marshal(struct {
+		Data       map[string]string `json:"data,omitempty"`
+		BinaryData map[string][]byte `json:"binaryData,omitempty"`
+	}{
+		Data:       configmap.Data,
+		BinaryData: configmap.BinaryData,
+	})
+	return contentHash(content)
+}
+
+//contentHash hashes the content into a string that is safe to use in resource
+//names. The version names the revision holding the content, it is the sha256 of
+//the content truncated to 64 bits, which makes distinct contents sharing a version
+//unlikely but not impossible: a recorded revision holding another content is
+//refused instead of being overwritten.
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+func NameValidation(name string) string {
+	reg, err := regexp.Compile("[^a-z0-9-]+")
+	if err != nil {
+		panic(err)
+	}
+
+	return reg.ReplaceAllString(name, "s")
+}
+
+//RolloutConfigMap starts a ConfigRollout rolling the workloads using the configMap
+//to the new version, the way its updateMethod asks for
+func RolloutConfigMap(ctx context.Context, c client.Client, recorder record.EventRecorder, configMap *corev1.ConfigMap, version string, previousVersion string) error {
+	return startRollout(ctx, c, recorder, configMap, index.ConfigMapField, version, previousVersion)
+}
+
+//GetCustomConfigMapByVersion returns the revision of the configMap holding the given version
+func GetCustomConfigMapByVersion(ctx context.Context, c client.Client, namespace string, configMapName string, version string) (*customConfigMapv1alpha1.CustomConfigMap, error) {
+	var ccmList customConfigMapv1alpha1.CustomConfigMapList
+	err := c.List(ctx, &ccmList, client.MatchingLabels{"name": configMapName}, client.InNamespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+	for i := range ccmList.Items {
+		if ccmList.Items[i].Annotations["customConfigMapVersion"] == version {
+			return &ccmList.Items[i], nil
+		}
+	}
+	return nil, errors.NewNotFound(customConfigMapv1alpha1.GroupVersion.WithResource("customconfigmaps").GroupResource(), NameValidation(configMapName+"-"+version))
+}
+
+//sameCustomConfigMapContent returns an error when the recorded revision holds
+//another content than the revision for the same version
+func sameCustomConfigMapContent(recorded *customConfigMapv1alpha1.CustomConfigMap, ccm *customConfigMapv1alpha1.CustomConfigMap) error {
+	if equality.Semantic.DeepEqual(recorded.Spec.Data, ccm.Spec.Data) && equality.Semantic.DeepEqual(recorded.Spec.BinaryData, ccm.Spec.BinaryData) {
+		return nil
+	}
+	return errors.NewConflict(customConfigMapv1alpha1.GroupVersion.WithResource("customconfigmaps").GroupResource(), recorded.Name,
+		fmt.Errorf("version %s of configMap %s is recorded with another content", recorded.Annotations["customConfigMapVersion"], recorded.Spec.ConfigMapName))
+}
+
+//markCurrentCustomConfigMap labels the revision current and latest, and removes
+//both labels from the other revisions of its configMap
+func markCurrentCustomConfigMap(ctx context.Context, c client.Client, recorder record.EventRecorder, ccm *customConfigMapv1alpha1.CustomConfigMap) error {
+	var ccmList customConfigMapv1alpha1.CustomConfigMapList
+	err := c.List(ctx, &ccmList, client.MatchingLabels{"name": ccm.Spec.ConfigMapName}, client.InNamespace(ccm.Namespace))
+	if err != nil {
+		return err
+	}
+	for i := range ccmList.Items {
+		revision := &ccmList.Items[i]
+		if revision.Name == ccm.Name || (revision.Labels["current"] == "" && revision.Labels["latest"] == "") {
+			continue
+		}
+		delete(revision.Labels, "current")
+		delete(revision.Labels, "latest")
+		err = c.Update(ctx, revision)
+		if err != nil {
+			recorder.Eventf(revision, corev1.EventTypeWarning, "FailedUpdatingCustomConfigMap", "Error Updating CustomConfigMap in removing current label: %v", err)
+			return err
+		}
+	}
+
+	if ccm.Labels == nil {
+		ccm.Labels = make(map[string]string)
+	}
+	ccm.Labels["current"] = "true"
+	ccm.Labels["latest"] = "true"
+	delete(ccm.Labels, "desired")
+	err = c.Update(ctx, ccm)
+	if err != nil {
+		recorder.Eventf(ccm, corev1.EventTypeWarning, "FailedUpdatingCustomConfigMap", "Error Updating CustomConfigMap: %v", err)
+		return err
+	}
+	return nil
+}
+
+//AdoptCustomConfigMap records a customConfigMap authored directly by a user as a
+//revision of its configMap. The revision stays staged (desired=false) until it is
+//marked desired=true, unless it was created with the desired label already set.
+//A customConfigMap duplicating the content of a recorded revision is folded into
+//that revision and deleted, it reports true then.
+func AdoptCustomConfigMap(ctx context.Context, c client.Client, recorder record.EventRecorder, ccm *customConfigMapv1alpha1.CustomConfigMap) (bool, error) {
+	version := configMapVersion(&corev1.ConfigMap{Data: ccm.Spec.Data, BinaryData: ccm.Spec.BinaryData})
+	existing, err := GetCustomConfigMapByVersion(ctx, c, ccm.Namespace, ccm.Spec.ConfigMapName, version)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if existing != nil && existing.Name != ccm.Name {
+		//the content is recorded already, a second revision of it would make the
+		//version ambiguous
+		if ccm.Labels["desired"] == "true" && existing.Labels["current"] != "true" && existing.Labels["desired"] != "true" {
+			existing.Labels["desired"] = "true"
+			if err := c.Update(ctx, existing); err != nil {
+				return false, err
 			}
 		}
+		if err := c.Delete(ctx, ccm); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+		recorder.Eventf(existing, corev1.EventTypeNormal, "duplicateCustomConfigMap", "ccm %v holding the same content removed", ccm.Name)
+		return true, nil
 	}
-	if configMap.Annotations["statefulsets"] != "" {
-		annotation := configMap.Annotations["statefulsets"]
-		split := strings.Split(annotation, ",")
-		if configMap.Annotations["updateMethod"] == "ignoreWhenShared" {
-			if len(split) > 1 {
-				klog.Error("can't trigger rolling update updateMethod is ignoreWhenShared")
-				return errors.NewBadRequest("can't trigger rolling update updateMethod is ignoreWhenShared")
-			} else {
-				var sts appsV1.StatefulSet
-				stsNameNamespace := types.NamespacedName{
-					Namespace: configMap.Namespace,
-					Name:      split[0],
-				}
 
-				err := r.Get(ctx, stsNameNamespace, &sts)
-				if err != nil {
-					klog.Error("Failed on getting statefulset '%s' Error: %s", split[0], err.Error)
-					return err
-				}
-				//update new version
-				sts.Spec.Template.Annotations["ccm-"+configMap.Name] = version
-				err = r.Update(ctx, &sts)
-				if err != nil {
-					klog.Error("Failed on updating statefulset '%s' Error: %s", split[0], err.Error)
-					return err
-				}
+	if ccm.Labels == nil {
+		ccm.Labels = make(map[string]string)
+	}
+	if ccm.Annotations == nil {
+		ccm.Annotations = make(map[string]string)
+	}
+	ccm.Labels["name"] = ccm.Spec.ConfigMapName
+	if ccm.Labels["desired"] == "" {
+		ccm.Labels["desired"] = "false"
+	}
+	ccm.Annotations["customConfigMapVersion"] = version
+
+	//owned by the configMap like the revisions recorded by the ConfigMapReconciler
+	if len(ccm.OwnerReferences) == 0 {
+		var configMap corev1.ConfigMap
+		err := c.Get(ctx, types.NamespacedName{Namespace: ccm.Namespace, Name: ccm.Spec.ConfigMapName}, &configMap)
+		if err == nil {
+			ccm.OwnerReferences = []metav1.OwnerReference{
+				*metav1.NewControllerRef(&configMap, corev1.SchemeGroupVersion.WithKind("ConfigMap")),
 			}
+		} else if !errors.IsNotFound(err) {
+			return false, err
 		}
 	}
-	return nil
+
+	err = c.Update(ctx, ccm)
+	if err != nil {
+		recorder.Eventf(ccm, corev1.EventTypeWarning, "FailedUpdatingCustomConfigMap", "Error adopting CustomConfigMap: %v", err)
+		return false, err
+	}
+	recorder.Eventf(ccm, corev1.EventTypeNormal, "adoptCustomConfigMap", "recorded ccm %v as version %v of configMap %v", ccm.Name, version, ccm.Spec.ConfigMapName)
+	return false, nil
 }
 
-func RandomSequence(n int) string {
-	var letters = []rune("abcdefghijklmnopqrstuvwxyz0123456789")
-	rand.Seed(time.Now().UTC().UnixNano())
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+//ApplyCustomConfigMap makes the revision current, copies its content to the
+//configMap named by Spec.ConfigMapName and rolls it out to the workloads
+func ApplyCustomConfigMap(ctx context.Context, c client.Client, recorder record.EventRecorder, ccm *customConfigMapv1alpha1.CustomConfigMap) error {
+	previousVersion, err := currentRevisionVersion(ctx, c, ccm.Namespace, ccm.Spec.ConfigMapName, &customConfigMapv1alpha1.CustomConfigMapList{})
+	if err != nil {
+		return err
 	}
-	return string(b)
+	configMap, err := RestoreCustomConfigMap(ctx, c, recorder, ccm)
+	if err != nil {
+		return err
+	}
+	if err := MaterializeCustomConfigMap(ctx, c, recorder, ccm); err != nil {
+		return err
+	}
+	return RolloutConfigMap(ctx, c, recorder, configMap, ccm.Annotations["customConfigMapVersion"], previousVersion)
 }
 
-func NameValidation(name string) string {
-	reg, err := regexp.Compile("[^a-z0-9-]+")
+//RestoreCustomConfigMap makes the revision current and copies its content to the
+//configMap named by Spec.ConfigMapName without rolling the workloads
+func RestoreCustomConfigMap(ctx context.Context, c client.Client, recorder record.EventRecorder, ccm *customConfigMapv1alpha1.CustomConfigMap) (*corev1.ConfigMap, error) {
+	var configMap corev1.ConfigMap
+	err := c.Get(ctx, types.NamespacedName{Namespace: ccm.Namespace, Name: ccm.Spec.ConfigMapName}, &configMap)
 	if err != nil {
-		panic(err)
+		recorder.Eventf(ccm, corev1.EventTypeWarning, "FailedGetConfigMap", "Error Getting ConfigMap %v: %v", ccm.Spec.ConfigMapName, err)
+		return nil, err
+	}
+	version := ccm.Annotations["customConfigMapVersion"]
+	if err := markCurrentCustomConfigMap(ctx, c, recorder, ccm); err != nil {
+		return nil, err
 	}
 
-	return reg.ReplaceAllString(name, "s")
+	//copying content
+	configMap.Data = ccm.Spec.Data
+	configMap.BinaryData = ccm.Spec.BinaryData
+	err = updateConfig(ctx, c, &configMap, version, ccm.Name)
+	if err != nil {
+		recorder.Eventf(ccm, corev1.EventTypeWarning, "FailedUpdatingConfigMap", "Error Updating ConfigMap %v: %v", configMap.Name, err)
+		return nil, err
+	}
+	recorder.Eventf(&configMap, corev1.EventTypeNormal, "applyCustomConfigMap", "applied ccm %v version %v to configMap %v", ccm.Name, version, configMap.Name)
+
+	return &configMap, nil
 }
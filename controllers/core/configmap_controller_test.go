@@ -0,0 +1,205 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	customConfigMapv1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//newNamespace creates a namespace for a single spec
+func newNamespace(ctx context.Context, prefix string) string {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: prefix + "-"}}
+	Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+	return ns.Name
+}
+
+//setConfigMapData replaces the content of the configMap
+func setConfigMapData(ctx context.Context, namespace string, name string, data map[string]string) {
+	var configMap corev1.ConfigMap
+	Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &configMap)).To(Succeed())
+	configMap.Data = data
+	Expect(k8sClient.Update(ctx, &configMap)).To(Succeed())
+}
+
+//customConfigMaps returns the revisions of the configMap
+func customConfigMaps(ctx context.Context, namespace string, name string) []customConfigMapv1alpha1.CustomConfigMap {
+	var ccmList customConfigMapv1alpha1.CustomConfigMapList
+	Expect(k8sClient.List(ctx, &ccmList, client.MatchingLabels{"name": name}, client.InNamespace(namespace))).To(Succeed())
+	return ccmList.Items
+}
+
+var _ = Describe("ConfigMap revisions", func() {
+	var (
+		ctx        context.Context
+		namespace  string
+		reconciler *ConfigMapReconciler
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		namespace = newNamespace(ctx, "revisions")
+		reconciler = &ConfigMapReconciler{Client: testClient, Scheme: scheme.Scheme, EventRecorder: record.NewFakeRecorder(1024)}
+	})
+
+	reconcile := func(name string) {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("names the revision after the content of the configMap", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace},
+			Data:       map[string]string{"key": "one"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+		reconcile("app")
+
+		version := configMapVersion(&corev1.ConfigMap{Data: map[string]string{"key": "one"}})
+		Expect(version).To(HaveLen(16))
+		revisions := customConfigMaps(ctx, namespace, "app")
+		Expect(revisions).To(HaveLen(1))
+		Expect(revisions[0].Name).To(Equal(NameValidation("app-" + version)))
+		Expect(revisions[0].Labels).To(HaveKeyWithValue("current", "true"))
+
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "app"}, configMap)).To(Succeed())
+		Expect(configMap.Annotations).To(HaveKeyWithValue("currentCustomConfigMapVersion", version))
+	})
+
+	It("makes the revision of a reverted content current again", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace},
+			Data:       map[string]string{"key": "one"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+		reconcile("app")
+		first := configMapVersion(&corev1.ConfigMap{Data: map[string]string{"key": "one"}})
+
+		setConfigMapData(ctx, namespace, "app", map[string]string{"key": "two"})
+		reconcile("app")
+		Expect(customConfigMaps(ctx, namespace, "app")).To(HaveLen(2))
+
+		setConfigMapData(ctx, namespace, "app", map[string]string{"key": "one"})
+		reconcile("app")
+		revisions := customConfigMaps(ctx, namespace, "app")
+		Expect(revisions).To(HaveLen(2))
+		for _, ccm := range revisions {
+			if ccm.Annotations["customConfigMapVersion"] == first {
+				Expect(ccm.Labels).To(HaveKeyWithValue("current", "true"))
+			} else {
+				Expect(ccm.Labels).NotTo(HaveKey("current"))
+			}
+		}
+
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "app"}, configMap)).To(Succeed())
+		Expect(configMap.Annotations).To(HaveKeyWithValue("currentCustomConfigMapVersion", first))
+	})
+
+	It("refuses a recorded revision holding another content", func() {
+		recorded := &customConfigMapv1alpha1.CustomConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app-recorded",
+				Namespace:   namespace,
+				Annotations: map[string]string{"customConfigMapVersion": "recorded"},
+			},
+			Spec: customConfigMapv1alpha1.CustomConfigMapSpec{
+				ConfigMapName: "app",
+				Data:          map[string]string{"key": "one"},
+			},
+		}
+		Expect(sameCustomConfigMapContent(recorded, recorded.DeepCopy())).To(Succeed())
+
+		ccm := recorded.DeepCopy()
+		ccm.Spec.Data = map[string]string{"key": "two"}
+		Expect(errors.IsConflict(sameCustomConfigMapContent(recorded, ccm))).To(BeTrue())
+	})
+	It("records the current version in the ConfigIndex with index bookkeeping", func() {
+		Expect(SetBookkeeping(BookkeepingIndex)).To(Succeed())
+		defer SetBookkeeping(BookkeepingAnnotations)
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace},
+			Data:       map[string]string{"key": "one"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+		reconcile("app")
+		version := configMapVersion(&corev1.ConfigMap{Data: map[string]string{"key": "one"}})
+
+		Eventually(func() (string, error) {
+			var configIndex customConfigMapv1alpha1.ConfigIndex
+			err := testClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ConfigIndexName("ConfigMap", "app")}, &configIndex)
+			return configIndex.Status.CurrentVersion, err
+		}).Should(Equal(version))
+
+		By("leaving the revisions alone on the reconcile the update triggers")
+		reconcile("app")
+		revisions := customConfigMaps(ctx, namespace, "app")
+		Expect(revisions).To(HaveLen(1))
+		Expect(revisions[0].Labels).To(HaveKeyWithValue("current", "true"))
+	})
+	It("folds a customConfigMap duplicating a recorded revision into it", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace},
+			Data:       map[string]string{"key": "one"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+		reconcile("app")
+		setConfigMapData(ctx, namespace, "app", map[string]string{"key": "two"})
+		reconcile("app")
+		first := configMapVersion(&corev1.ConfigMap{Data: map[string]string{"key": "one"}})
+		Eventually(func() error {
+			_, err := GetCustomConfigMapByVersion(ctx, testClient, namespace, "app", first)
+			return err
+		}).Should(Succeed())
+
+		duplicate := &customConfigMapv1alpha1.CustomConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app-copy",
+				Namespace: namespace,
+				Labels:    map[string]string{"desired": "true"},
+			},
+			Spec: customConfigMapv1alpha1.CustomConfigMapSpec{
+				ConfigMapName: "app",
+				Data:          map[string]string{"key": "one"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, duplicate)).To(Succeed())
+		folded, err := AdoptCustomConfigMap(ctx, testClient, record.NewFakeRecorder(1024), duplicate)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(folded).To(BeTrue())
+		Expect(errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "app-copy"}, duplicate))).To(BeTrue())
+
+		revisions := customConfigMaps(ctx, namespace, "app")
+		Expect(revisions).To(HaveLen(2))
+		for _, ccm := range revisions {
+			if ccm.Annotations["customConfigMapVersion"] == first {
+				Expect(ccm.Labels).To(HaveKeyWithValue("desired", "true"))
+			}
+		}
+	})
+})
@@ -0,0 +1,100 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//DebounceAnnotation on a configMap or secret overrides the global quiet period
+const DebounceAnnotation = "debounce"
+
+//DebouncePeriod is how long a configMap or secret has to stay unchanged before its
+//changes are recorded as a new revision and rolled out, 0 records every change
+var DebouncePeriod time.Duration
+
+//pendingChange is an edit of a configMap or secret waiting for the quiet period
+type pendingChange struct {
+	version string
+	since   time.Time
+}
+
+var pendingChanges = struct {
+	sync.Mutex
+	changes map[string]pendingChange
+}{changes: make(map[string]pendingChange)}
+
+//debounce returns how long to wait before recording version, the content of a
+//configMap or secret holding current, as a new revision. Every change of the
+//content restarts the quiet period, so edits inside it end up in a single
+//revision and a single rollout.
+func debounce(recorder record.EventRecorder, obj client.Object, current string, version string) time.Duration {
+	key := pendingChangeKey(configKind(obj), obj.GetNamespace(), obj.GetName())
+	pendingChanges.Lock()
+	defer pendingChanges.Unlock()
+
+	period := debouncePeriod(recorder, obj)
+	if period == 0 || version == current {
+		delete(pendingChanges.changes, key)
+		return 0
+	}
+	change, ok := pendingChanges.changes[key]
+	if !ok || change.version != version {
+		if !ok {
+			recorder.Eventf(obj, corev1.EventTypeNormal, "Debounced", "waiting %v for further changes before recording a new revision", period)
+		}
+		change = pendingChange{version: version, since: time.Now()}
+		pendingChanges.changes[key] = change
+	}
+	if wait := period - time.Since(change.since); wait > 0 {
+		return wait
+	}
+	delete(pendingChanges.changes, key)
+	return 0
+}
+
+//forgetPendingChange drops the edit waiting for the quiet period of a configMap or
+//secret that was deleted or is no longer versioned
+func forgetPendingChange(kind string, namespace string, name string) {
+	pendingChanges.Lock()
+	defer pendingChanges.Unlock()
+	delete(pendingChanges.changes, pendingChangeKey(kind, namespace, name))
+}
+
+func pendingChangeKey(kind string, namespace string, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+//debouncePeriod returns the quiet period of a configMap or secret, its debounce
+//annotation or else the global DebouncePeriod
+func debouncePeriod(recorder record.EventRecorder, obj client.Object) time.Duration {
+	value := obj.GetAnnotations()[DebounceAnnotation]
+	if value == "" {
+		return DebouncePeriod
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period < 0 {
+		recorder.Eventf(obj, corev1.EventTypeWarning, "InvalidDebounce", "%v %q is not a duration, using %v", DebounceAnnotation, value, DebouncePeriod)
+		return DebouncePeriod
+	}
+	return period
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	"github.com/gopaddle-io/configurator/pkg/revision"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//MaterializeCustomConfigMap writes the revision as an immutable configMap named
+//after it, owned by the customConfigMap so that it is deleted with it. The pod
+//templates pinned to the revision reference it instead of the configMap. Nothing
+//is written unless the revisions are materialized.
+func MaterializeCustomConfigMap(ctx context.Context, c client.Client, recorder record.EventRecorder, ccm *configuratorgopaddleiov1alpha1.CustomConfigMap) error {
+	version := ccm.Annotations["customConfigMapVersion"]
+	if !revision.Immutable() || version == "" {
+		return nil
+	}
+	name := revision.Name(ccm.Spec.ConfigMapName, version)
+	var existing corev1.ConfigMap
+	err := c.Get(ctx, types.NamespacedName{Namespace: ccm.Namespace, Name: name}, &existing)
+	if err == nil {
+		if existing.Labels[revision.Label] != ccm.Spec.ConfigMapName {
+			recorder.Eventf(ccm, corev1.EventTypeWarning, "FailedMaterializingCustomConfigMap", "configMap %v exists and does not hold a revision of configMap %v", name, ccm.Spec.ConfigMapName)
+			return nil
+		}
+		if !equality.Semantic.DeepEqual(existing.Data, ccm.Spec.Data) || !equality.Semantic.DeepEqual(existing.BinaryData, ccm.Spec.BinaryData) {
+			recorder.Eventf(ccm, corev1.EventTypeWarning, "FailedMaterializingCustomConfigMap", "configMap %v holds another content than version %v of configMap %v", name, version, ccm.Spec.ConfigMapName)
+			return errors.NewConflict(corev1.Resource("configmaps"), name, fmt.Errorf("configMap holds another content than version %s of configMap %s", version, ccm.Spec.ConfigMapName))
+		}
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	immutable := true
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ccm.Namespace,
+			Labels: map[string]string{
+				revision.Label: ccm.Spec.ConfigMapName,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(ccm, configuratorgopaddleiov1alpha1.GroupVersion.WithKind("CustomConfigMap")),
+			},
+		},
+		Data:       ccm.Spec.Data,
+		BinaryData: ccm.Spec.BinaryData,
+		Immutable:  &immutable,
+	}
+	err = c.Create(ctx, configMap)
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		recorder.Eventf(ccm, corev1.EventTypeWarning, "FailedMaterializingCustomConfigMap", "Error creating immutable configMap %v: %v", name, err)
+		return err
+	}
+	recorder.Eventf(ccm, corev1.EventTypeNormal, "materializeCustomConfigMap", "created immutable configMap %v for version %v of configMap %v", name, version, ccm.Spec.ConfigMapName)
+	return nil
+}
+
+//MaterializeCustomSecret writes the revision as an immutable secret named after
+//it, owned by the customSecret so that it is deleted with it, with the annotations
+//of the secret the revision recorded. The pod templates pinned to the revision
+//reference it instead of the secret. Nothing is written unless the revisions are
+//materialized.
+func MaterializeCustomSecret(ctx context.Context, c client.Client, recorder record.EventRecorder, cs *configuratorgopaddleiov1alpha1.CustomSecret) error {
+	version := cs.Annotations["customSecretVersion"]
+	if !revision.Immutable() || version == "" {
+		return nil
+	}
+	name := revision.Name(cs.Spec.SecretName, version)
+	var existing corev1.Secret
+	err := c.Get(ctx, types.NamespacedName{Namespace: cs.Namespace, Name: name}, &existing)
+	if err == nil {
+		if existing.Labels[revision.Label] != cs.Spec.SecretName {
+			recorder.Eventf(cs, corev1.EventTypeWarning, "FailedMaterializingCustomSecret", "secret %v exists and does not hold a revision of secret %v", name, cs.Spec.SecretName)
+			return nil
+		}
+		if (cs.Spec.Type != "" && existing.Type != cs.Spec.Type) || !equality.Semantic.DeepEqual(existing.Data, customSecretData(cs)) {
+			recorder.Eventf(cs, corev1.EventTypeWarning, "FailedMaterializingCustomSecret", "secret %v holds another content than version %v of secret %v", name, version, cs.Spec.SecretName)
+			return errors.NewConflict(corev1.Resource("secrets"), name, fmt.Errorf("secret holds another content than version %s of secret %s", version, cs.Spec.SecretName))
+		}
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	immutable := true
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cs.Namespace,
+			Labels: map[string]string{
+				revision.Label: cs.Spec.SecretName,
+			},
+			Annotations: cs.Spec.SecretAnnotations,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cs, configuratorgopaddleiov1alpha1.GroupVersion.WithKind("CustomSecret")),
+			},
+		},
+		Type:       cs.Spec.Type,
+		Data:       cs.Spec.Data,
+		StringData: cs.Spec.StringData,
+		Immutable:  &immutable,
+	}
+	err = c.Create(ctx, secret)
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		recorder.Eventf(cs, corev1.EventTypeWarning, "FailedMaterializingCustomSecret", "Error creating immutable secret %v: %v", name, err)
+		return err
+	}
+	recorder.Eventf(cs, corev1.EventTypeNormal, "materializeCustomSecret", "created immutable secret %v for version %v of secret %v", name, version, cs.Spec.SecretName)
+	return nil
+}
+
+//customSecretData returns the data of the secret materializing the revision, its
+//stringData merged into its data the way the apiserver does
+func customSecretData(cs *configuratorgopaddleiov1alpha1.CustomSecret) map[string][]byte {
+	data := make(map[string][]byte)
+	for k, v := range cs.Spec.Data {
+		data[k] = v
+	}
+	for k, v := range cs.Spec.StringData {
+		data[k] = []byte(v)
+	}
+	return data
+}
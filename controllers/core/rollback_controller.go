@@ -0,0 +1,269 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+	"github.com/gopaddle-io/configurator/pkg/selection"
+	"github.com/gopaddle-io/configurator/pkg/workload"
+	appsV1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+//deploymentRevision is the annotation the deployment controller numbers the
+//replicaSets of a deployment with. A rollback reuses the replicaSet of the
+//previous template and bumps its revision.
+const deploymentRevision = "deployment.kubernetes.io/revision"
+
+//+kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apps,resources=controllerrevisions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+//ReplicaSetReconciler restores the configMaps and secrets to the versions pinned
+//on the pod template of a deployment when its replicaSets show it was rolled
+//back to a previous template
+type ReplicaSetReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+}
+
+//Reconcile restores the versions pinned on the template of the active replicaSet
+func (r *ReplicaSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var rs appsV1.ReplicaSet
+	if err := r.Get(ctx, req.NamespacedName, &rs); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	owner := metav1.GetControllerOf(&rs)
+	if owner == nil {
+		return ctrl.Result{}, nil
+	}
+	//only the replicaSet with the highest revision of the deployment is active
+	var replicaSets appsV1.ReplicaSetList
+	if err := r.List(ctx, &replicaSets, client.InNamespace(rs.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+	revision := replicaSetRevision(&rs)
+	for i := range replicaSets.Items {
+		other := &replicaSets.Items[i]
+		if controlledBy(other, owner.UID) && replicaSetRevision(other) > revision {
+			return ctrl.Result{}, nil
+		}
+	}
+	return ctrl.Result{}, restorePinnedVersions(ctx, r.Client, r.EventRecorder, &rs, rs.Spec.Template.Annotations)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ReplicaSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("replicaset-rollback").
+		For(&appsV1.ReplicaSet{}, builder.WithPredicates(rolledBack(func(obj client.Object) string {
+			return obj.GetAnnotations()[deploymentRevision]
+		}))).
+		Complete(r)
+}
+
+//ControllerRevisionReconciler restores the configMaps and secrets to the versions
+//pinned on the pod template of a statefulSet, daemonSet or custom workload when
+//its controllerRevisions show it was rolled back to a previous template
+type ControllerRevisionReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	EventRecorder record.EventRecorder
+}
+
+//Reconcile restores the versions pinned on the template of the active controllerRevision
+func (r *ControllerRevisionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var revision appsV1.ControllerRevision
+	if err := r.Get(ctx, req.NamespacedName, &revision); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	owner := metav1.GetControllerOf(&revision)
+	if owner == nil {
+		return ctrl.Result{}, nil
+	}
+	//only the controllerRevision with the highest revision of the workload is active
+	var revisions appsV1.ControllerRevisionList
+	if err := r.List(ctx, &revisions, client.InNamespace(revision.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+	for i := range revisions.Items {
+		other := &revisions.Items[i]
+		if controlledBy(other, owner.UID) && other.Revision > revision.Revision {
+			return ctrl.Result{}, nil
+		}
+	}
+
+	data := make(map[string]interface{})
+	if err := json.Unmarshal(revision.Data.Raw, &data); err != nil {
+		klog.Infof("failed on unmarshal controllerRevision %s: %v", revision.Name, err.Error())
+		return ctrl.Result{}, nil
+	}
+	annotations, _, _ := unstructured.NestedStringMap(data, "spec", "template", "metadata", "annotations")
+	if adapter, found := workload.ForGroupVersionKind(schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind)); found {
+		annotations = adapter.TemplateAnnotations(data)
+	}
+	return ctrl.Result{}, restorePinnedVersions(ctx, r.Client, r.EventRecorder, &revision, annotations)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ControllerRevisionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("controllerrevision-rollback").
+		For(&appsV1.ControllerRevision{}, builder.WithPredicates(rolledBack(func(obj client.Object) string {
+			if revision, ok := obj.(*appsV1.ControllerRevision); ok {
+				return strconv.FormatInt(revision.Revision, 10)
+			}
+			return ""
+		}))).
+		Complete(r)
+}
+
+//rolledBack passes the replicaSets and controllerRevisions whose revision was
+//bumped, which is how a rollback makes a previous template the active one again.
+//A new replicaSet or controllerRevision is a new template, rolled by a rollout or
+//by an edit of the workload: it may pin an older version under the manual,
+//ignoreWhenShared and sequential update methods, restoring it would revert the
+//configMap or secret.
+func rolledBack(revision func(client.Object) string) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return selection.Namespace(e.ObjectNew.GetNamespace()) && revision(e.ObjectOld) != revision(e.ObjectNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+//replicaSetRevision returns the revision the deployment controller numbered the replicaSet with
+func replicaSetRevision(rs *appsV1.ReplicaSet) int64 {
+	revision, _ := strconv.ParseInt(rs.Annotations[deploymentRevision], 10, 64)
+	return revision
+}
+
+//controlledBy reports whether the controller of obj has the given uid
+func controlledBy(obj metav1.Object, uid types.UID) bool {
+	owner := metav1.GetControllerOf(obj)
+	return owner != nil && owner.UID == uid
+}
+
+//restorePinnedVersions restores every configMap and secret pinned on the template
+//annotations to the pinned version when it holds another version. A pinned version
+//whose revision was purged is left as it is.
+func restorePinnedVersions(ctx context.Context, c client.Client, recorder record.EventRecorder, obj client.Object, annotations map[string]string) error {
+	if annotations["config-sync-controller"] != "configurator" {
+		return nil
+	}
+	namespace := obj.GetNamespace()
+	for key, version := range annotations {
+		if version == "" {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(key, "ccm-"):
+			name := strings.TrimPrefix(key, "ccm-")
+			var configMap corev1.ConfigMap
+			if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &configMap); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return err
+			}
+			if !selection.ConfigMap(&configMap) {
+				continue
+			}
+			current, err := CurrentVersion(ctx, c, &configMap)
+			if err != nil {
+				return err
+			}
+			if current == version {
+				continue
+			}
+			ccm, err := GetCustomConfigMapByVersion(ctx, c, namespace, name, version)
+			if errors.IsNotFound(err) {
+				klog.Infof("%s/%s pins version %s of configMap %s, no customConfigMap holds it", namespace, obj.GetName(), version, name)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := RestoreCustomConfigMap(ctx, c, recorder, ccm); err != nil {
+				return err
+			}
+			metrics.Restored(namespace, "ConfigMap")
+			recorder.Eventf(obj, corev1.EventTypeNormal, "restoreConfigMap", "template pins version %v of configMap %v, restored it from version %v", version, name, current)
+		case strings.HasPrefix(key, "cs-"):
+			name := strings.TrimPrefix(key, "cs-")
+			var secret corev1.Secret
+			if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return err
+			}
+			if !selection.Secret(&secret) {
+				continue
+			}
+			current, err := CurrentVersion(ctx, c, &secret)
+			if err != nil {
+				return err
+			}
+			if current == version {
+				continue
+			}
+			cs, err := GetCustomSecretByVersion(ctx, c, namespace, name, version)
+			if errors.IsNotFound(err) {
+				klog.Infof("%s/%s pins version %s of secret %s, no customSecret holds it", namespace, obj.GetName(), version, name)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := RestoreCustomSecret(ctx, c, recorder, cs); err != nil {
+				return err
+			}
+			metrics.Restored(namespace, "Secret")
+			recorder.Eventf(obj, corev1.EventTypeNormal, "restoreSecret", "template pins version %v of secret %v, restored it from version %v", version, name, current)
+		}
+	}
+	return nil
+}
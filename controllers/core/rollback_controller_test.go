@@ -0,0 +1,112 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsV1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Rollback restore", func() {
+	var (
+		ctx       context.Context
+		namespace string
+		recorder  record.EventRecorder
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		namespace = newNamespace(ctx, "rollback")
+		recorder = record.NewFakeRecorder(1024)
+	})
+
+	//newReplicaSet returns a replicaSet of the deployment numbered revision whose
+	//pod template pins version of configMap app
+	newReplicaSet := func(deployment *appsV1.Deployment, name string, revision string, version string) *appsV1.ReplicaSet {
+		template := *deployment.Spec.Template.DeepCopy()
+		template.Annotations = map[string]string{
+			"config-sync-controller": "configurator",
+			"ccm-app":                version,
+		}
+		return &appsV1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       namespace,
+				Annotations:     map[string]string{deploymentRevision: revision},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment, appsV1.SchemeGroupVersion.WithKind("Deployment"))},
+			},
+			Spec: appsV1.ReplicaSetSpec{
+				Selector: deployment.Spec.Selector,
+				Template: template,
+			},
+		}
+	}
+
+	It("restores the version pinned on the active replicaSet of a rolled back deployment", func() {
+		configMaps := &ConfigMapReconciler{Client: testClient, Scheme: scheme.Scheme, EventRecorder: recorder}
+		request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "app"}}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace},
+			Data:       map[string]string{"key": "one"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+		_, err := configMaps.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		first := configMapVersion(&corev1.ConfigMap{Data: map[string]string{"key": "one"}})
+
+		setConfigMapData(ctx, namespace, "app", map[string]string{"key": "two"})
+		_, err = configMaps.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		second := configMapVersion(&corev1.ConfigMap{Data: map[string]string{"key": "two"}})
+
+		deployment := newDeployment(namespace, "web", "app")
+		Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+		//the rollback bumped the revision of the replicaSet pinning the first version
+		Expect(k8sClient.Create(ctx, newReplicaSet(deployment, "web-second", "2", second))).To(Succeed())
+		Expect(k8sClient.Create(ctx, newReplicaSet(deployment, "web-first", "3", first))).To(Succeed())
+
+		replicaSets := &ReplicaSetReconciler{Client: testClient, Scheme: scheme.Scheme, EventRecorder: recorder}
+		By("leaving the configMap alone for a replicaSet that is not active")
+		_, err = replicaSets.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "web-second"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, request.NamespacedName, configMap)).To(Succeed())
+		Expect(configMap.Data).To(Equal(map[string]string{"key": "two"}))
+
+		By("restoring the version pinned on the active replicaSet")
+		_, err = replicaSets.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "web-first"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, request.NamespacedName, configMap)).To(Succeed())
+		Expect(configMap.Data).To(Equal(map[string]string{"key": "one"}))
+		Expect(configMap.Annotations).To(HaveKeyWithValue("currentCustomConfigMapVersion", first))
+		for _, ccm := range customConfigMaps(ctx, namespace, "app") {
+			if ccm.Annotations["customConfigMapVersion"] == first {
+				Expect(ccm.Labels).To(HaveKeyWithValue("current", "true"))
+			} else {
+				Expect(ccm.Labels).NotTo(HaveKey("current"))
+			}
+		}
+	})
+})
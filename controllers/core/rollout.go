@@ -0,0 +1,426 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+	"github.com/gopaddle-io/configurator/pkg/workload"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//values of the updateMethod annotation of a configMap or secret
+const (
+	//UpdateMethodIgnoreWhenShared rolls the workload only when a single workload uses the configMap or secret
+	UpdateMethodIgnoreWhenShared = "ignoreWhenShared"
+	//UpdateMethodRollAll rolls every workload at once
+	UpdateMethodRollAll = "rollAll"
+	//UpdateMethodSequential rolls one workload at a time, once the previous one is ready
+	UpdateMethodSequential = "sequential"
+	//UpdateMethodManual only records the revision, workloads are rolled by the user
+	UpdateMethodManual = "manual"
+)
+
+//annotations opting a configMap or secret, or every configMap and secret of a
+//namespace, into rolling back a rollout whose workloads fail
+const (
+	//AutoRollbackAnnotation set to true rolls back failing rollouts
+	AutoRollbackAnnotation = "autoRollback"
+	//AutoRollbackMaxRestartsAnnotation is how often the containers may restart, 3 when not set
+	AutoRollbackMaxRestartsAnnotation = "autoRollbackMaxRestarts"
+	//AutoRollbackWindowAnnotation is how long the workloads are watched once available, 5m when not set
+	AutoRollbackWindowAnnotation = "autoRollbackWindow"
+)
+
+//ProgressDeadline is how long a workload may take to become available before a
+//ConfigRollout halts
+var ProgressDeadline = 10 * time.Minute
+
+//UpdateMethod returns the updateMethod of a configMap or secret, ignoreWhenShared when not set
+func UpdateMethod(annotations map[string]string) string {
+	if annotations["updateMethod"] == "" {
+		return UpdateMethodIgnoreWhenShared
+	}
+	return annotations["updateMethod"]
+}
+
+//ConfigRolloutName returns the name of the ConfigRollout of version of a configMap or secret
+func ConfigRolloutName(kind string, name string, version string) string {
+	return NameValidation(ConfigIndexName(kind, name) + "-" + version)
+}
+
+//startRollout records a ConfigRollout rolling the workloads referencing a configMap
+//or secret, looked up in the consumer index field, to version, the way the
+//updateMethod of the configMap or secret asks for. The workloads are then rolled
+//and watched by the ConfigRollout controller.
+func startRollout(ctx context.Context, c client.Client, recorder record.EventRecorder, config client.Object, field string, version string, previousVersion string) error {
+	state, err := getConfigState(ctx, c, config)
+	if err != nil {
+		return err
+	}
+	consumers, err := RolloutConsumers(ctx, c, config.GetNamespace(), field, config.GetName())
+	if err != nil {
+		return err
+	}
+	if len(consumers) == 0 {
+		return nil
+	}
+
+	method := state.UpdateMethod
+	switch method {
+	case UpdateMethodManual:
+		recorder.Eventf(config, corev1.EventTypeNormal, "RolloutSkipped", "updateMethod is manual, version %v recorded without rolling %d workloads", version, len(consumers))
+		return nil
+	case UpdateMethodIgnoreWhenShared:
+		//the version is recorded, returning an error would requeue and skip it again
+		if len(consumers) > 1 {
+			klog.Infof("%s/%s is shared by %d workloads, updateMethod ignoreWhenShared skips the rolling update", config.GetNamespace(), config.GetName(), len(consumers))
+			recorder.Eventf(config, corev1.EventTypeWarning, "RolloutSkipped", "updateMethod is ignoreWhenShared and %d workloads share it", len(consumers))
+			metrics.Rollout(config.GetNamespace(), configKind(config), metrics.RolloutSkipped)
+			return nil
+		}
+	case UpdateMethodRollAll, UpdateMethodSequential:
+	default:
+		recorder.Eventf(config, corev1.EventTypeWarning, "RolloutSkipped", "unknown updateMethod %v", method)
+		return errors.NewBadRequest(fmt.Sprintf("unknown updateMethod %s, expected ignoreWhenShared, rollAll, sequential or manual", method))
+	}
+
+	policy, err := autoRollbackPolicy(ctx, c, recorder, config)
+	if err != nil {
+		return err
+	}
+	kind := configKind(config)
+	rollout := &configuratorgopaddleiov1alpha1.ConfigRollout{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigRolloutName(kind, config.GetName(), version),
+			Namespace: config.GetNamespace(),
+			Labels: map[string]string{
+				"name": config.GetName(),
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(config, corev1.SchemeGroupVersion.WithKind(kind)),
+			},
+		},
+		Spec: configuratorgopaddleiov1alpha1.ConfigRolloutSpec{
+			Kind:                    kind,
+			Name:                    config.GetName(),
+			Version:                 version,
+			PreviousVersion:         previousVersion,
+			UpdateMethod:            method,
+			ProgressDeadlineSeconds: int32(ProgressDeadline.Seconds()),
+			AutoRollback:            policy,
+		},
+	}
+	err = c.Create(ctx, rollout)
+	if errors.IsAlreadyExists(err) {
+		//the version was rolled out before, roll it out again
+		existing := &configuratorgopaddleiov1alpha1.ConfigRollout{}
+		err = c.Get(ctx, types.NamespacedName{Namespace: rollout.Namespace, Name: rollout.Name}, existing)
+		if err == nil {
+			err = c.Delete(ctx, existing)
+		}
+		if err == nil {
+			err = c.Create(ctx, rollout)
+		}
+	}
+	if err != nil {
+		recorder.Eventf(config, corev1.EventTypeWarning, "FailedRollout", "Error creating ConfigRollout %v: %v", rollout.Name, err)
+		return err
+	}
+	recorder.Eventf(config, corev1.EventTypeNormal, "RolloutStarted", "ConfigRollout %v rolls %d workloads to version %v", rollout.Name, len(consumers), version)
+	metrics.Rollout(config.GetNamespace(), kind, metrics.RolloutTriggered)
+	return nil
+}
+
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+
+//autoRollbackPolicy returns the auto rollback policy of a configMap or secret from
+//its annotations, or else from the annotations of its namespace. It returns nil
+//when the configMap or secret did not opt in.
+func autoRollbackPolicy(ctx context.Context, c client.Reader, recorder record.EventRecorder, config client.Object) (*configuratorgopaddleiov1alpha1.AutoRollbackPolicy, error) {
+	annotations := config.GetAnnotations()
+	if annotations[AutoRollbackAnnotation] == "" {
+		var namespace corev1.Namespace
+		if err := c.Get(ctx, types.NamespacedName{Name: config.GetNamespace()}, &namespace); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+		annotations = namespace.Annotations
+	}
+	if annotations[AutoRollbackAnnotation] != "true" {
+		return nil, nil
+	}
+
+	policy := &configuratorgopaddleiov1alpha1.AutoRollbackPolicy{MaxRestarts: 3, WindowSeconds: 300}
+	if value := annotations[AutoRollbackMaxRestartsAnnotation]; value != "" {
+		maxRestarts, err := strconv.Atoi(value)
+		if err != nil || maxRestarts < 0 {
+			recorder.Eventf(config, corev1.EventTypeWarning, "InvalidAutoRollback", "%v %q is not a number of restarts, using %d", AutoRollbackMaxRestartsAnnotation, value, policy.MaxRestarts)
+		} else {
+			policy.MaxRestarts = int32(maxRestarts)
+		}
+	}
+	if value := annotations[AutoRollbackWindowAnnotation]; value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window < time.Second {
+			recorder.Eventf(config, corev1.EventTypeWarning, "InvalidAutoRollback", "%v %q is not a duration, using %ds", AutoRollbackWindowAnnotation, value, policy.WindowSeconds)
+		} else {
+			policy.WindowSeconds = int32(window.Seconds())
+		}
+	}
+	return policy, nil
+}
+
+//RolloutConsumers returns the workloads referencing the configMap (ConfigMapField)
+//or secret (SecretField) name that a rollout rolls, in the order they are rolled.
+//Jobs are left out as they are pinned to the version they were created with.
+func RolloutConsumers(ctx context.Context, c client.Reader, namespace string, field string, name string) ([]configuratorgopaddleiov1alpha1.ConsumerReference, error) {
+	indexed, err := index.Consumers(ctx, c, namespace, field, name)
+	if err != nil {
+		return nil, err
+	}
+	consumers := []configuratorgopaddleiov1alpha1.ConsumerReference{}
+	for _, consumer := range indexed {
+		if consumer.Kind != "Job" {
+			consumers = append(consumers, consumer)
+		}
+	}
+	return consumers, nil
+}
+
+//GetConsumer returns the workload a consumer reference points to
+func GetConsumer(ctx context.Context, c client.Reader, namespace string, consumer configuratorgopaddleiov1alpha1.ConsumerReference) (client.Object, error) {
+	obj, err := newConsumerObject(consumer.Kind)
+	if err != nil {
+		return nil, errors.NewBadRequest(err.Error())
+	}
+	err = c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: consumer.Name}, obj)
+	return obj, err
+}
+
+//RollConsumer sets version under key (ccm-<configMap> or cs-<secret>) on the pod
+//template of a workload and returns the updated workload. The workload is read
+//again and the update retried when it conflicts with another writer.
+func RollConsumer(ctx context.Context, c client.Client, namespace string, consumer configuratorgopaddleiov1alpha1.ConsumerReference, key string, version string) (client.Object, error) {
+	var obj client.Object
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		obj, err = GetConsumer(ctx, c, namespace, consumer)
+		if err != nil {
+			return err
+		}
+		if templateAnnotations(obj)[key] == version {
+			return nil
+		}
+		//update new version
+		if err := setTemplateAnnotation(obj, key, version); err != nil {
+			return err
+		}
+		return c.Update(ctx, obj)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+//newConsumerObject returns an empty object of the given kind of workload
+func newConsumerObject(kind string) (client.Object, error) {
+	switch kind {
+	case "Deployment":
+		return &appsV1.Deployment{}, nil
+	case "StatefulSet":
+		return &appsV1.StatefulSet{}, nil
+	case "DaemonSet":
+		return &appsV1.DaemonSet{}, nil
+	case "CronJob":
+		return &batchV1beta1.CronJob{}, nil
+	}
+	for _, adapter := range workload.Adapters() {
+		if adapter.Kind == kind {
+			return adapter.NewObject(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown workload kind %s", kind)
+}
+
+//templateAnnotations returns the pod template annotations of a workload
+func templateAnnotations(obj client.Object) map[string]string {
+	switch w := obj.(type) {
+	case *appsV1.Deployment:
+		return w.Spec.Template.Annotations
+	case *appsV1.StatefulSet:
+		return w.Spec.Template.Annotations
+	case *appsV1.DaemonSet:
+		return w.Spec.Template.Annotations
+	case *batchV1beta1.CronJob:
+		return w.Spec.JobTemplate.Spec.Template.Annotations
+	case *unstructured.Unstructured:
+		if adapter, ok := workload.ForGroupVersionKind(w.GroupVersionKind()); ok {
+			return adapter.TemplateAnnotations(w.Object)
+		}
+	}
+	return nil
+}
+
+//setTemplateAnnotation sets an annotation on the pod template of a workload
+func setTemplateAnnotation(obj client.Object, key string, value string) error {
+	switch w := obj.(type) {
+	case *appsV1.Deployment:
+		w.Spec.Template.Annotations = setAnnotation(w.Spec.Template.Annotations, key, value)
+	case *appsV1.StatefulSet:
+		w.Spec.Template.Annotations = setAnnotation(w.Spec.Template.Annotations, key, value)
+	case *appsV1.DaemonSet:
+		w.Spec.Template.Annotations = setAnnotation(w.Spec.Template.Annotations, key, value)
+	case *batchV1beta1.CronJob:
+		w.Spec.JobTemplate.Spec.Template.Annotations = setAnnotation(w.Spec.JobTemplate.Spec.Template.Annotations, key, value)
+	case *unstructured.Unstructured:
+		adapter, ok := workload.ForGroupVersionKind(w.GroupVersionKind())
+		if !ok {
+			return fmt.Errorf("no workload adapter configured for %s", w.GroupVersionKind().String())
+		}
+		return adapter.SetTemplateAnnotation(w.Object, key, value)
+	}
+	return nil
+}
+
+func setAnnotation(annotations map[string]string, key string, value string) map[string]string {
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[key] = value
+	return annotations
+}
+
+//ConsumerReady reports whether a workload finished rolling out its pod template
+//and all its replicas are available. A statefulset with the OnDelete strategy only
+//has to observe its pod template, as its pods are not replaced until deleted. A custom workload has to report its
+//status.observedGeneration, else its readiness can't be told and an error is returned.
+func ConsumerReady(obj client.Object) (bool, error) {
+	switch w := obj.(type) {
+	case *appsV1.Deployment:
+		replicas := int32(1)
+		if w.Spec.Replicas != nil {
+			replicas = *w.Spec.Replicas
+		}
+		return w.Status.ObservedGeneration >= w.Generation && w.Status.Replicas == replicas &&
+			w.Status.UpdatedReplicas == replicas && w.Status.AvailableReplicas == replicas, nil
+	case *appsV1.StatefulSet:
+		replicas := int32(1)
+		if w.Spec.Replicas != nil {
+			replicas = *w.Spec.Replicas
+		}
+		if w.Spec.UpdateStrategy.Type == appsV1.OnDeleteStatefulSetStrategyType {
+			//the pods are only replaced once deleted, the new template is never rolled out
+			//by the statefulset itself
+			return w.Status.ObservedGeneration >= w.Generation && w.Status.ReadyReplicas == replicas, nil
+		}
+		//the pods below the partition keep the previous template
+		updated := replicas
+		if rollingUpdate := w.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil {
+			updated = replicas - *rollingUpdate.Partition
+			if updated < 0 {
+				updated = 0
+			}
+		}
+		return w.Status.ObservedGeneration >= w.Generation && w.Status.UpdatedReplicas >= updated &&
+			w.Status.ReadyReplicas == replicas, nil
+	case *appsV1.DaemonSet:
+		return w.Status.ObservedGeneration >= w.Generation && w.Status.UpdatedNumberScheduled == w.Status.DesiredNumberScheduled &&
+			w.Status.NumberAvailable == w.Status.DesiredNumberScheduled, nil
+	case *unstructured.Unstructured:
+		observedGeneration, found, err := unstructured.NestedInt64(w.Object, "status", "observedGeneration")
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			return false, fmt.Errorf("%s %s reports no status.observedGeneration, its readiness can't be checked", w.GetKind(), w.GetName())
+		}
+		if observedGeneration < w.GetGeneration() {
+			return false, nil
+		}
+		//the replica counts are checked when the workload reports them
+		replicas, found, _ := unstructured.NestedInt64(w.Object, "spec", "replicas")
+		if !found {
+			return true, nil
+		}
+		for _, field := range []string{"updatedReplicas", "availableReplicas"} {
+			if count, found, _ := unstructured.NestedInt64(w.Object, "status", field); found && count != replicas {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	//cronjobs pick up the version on their next run
+	return true, nil
+}
+
+//ConsumerRestarts returns how often the containers of the pods of a workload
+//running the version (annotation key) restarted
+func ConsumerRestarts(ctx context.Context, c client.Reader, obj client.Object, key string, version string) (int32, error) {
+	var selector *metav1.LabelSelector
+	switch w := obj.(type) {
+	case *appsV1.Deployment:
+		selector = w.Spec.Selector
+	case *appsV1.StatefulSet:
+		selector = w.Spec.Selector
+	case *appsV1.DaemonSet:
+		selector = w.Spec.Selector
+	case *unstructured.Unstructured:
+		matchLabels, found, _ := unstructured.NestedStringMap(w.Object, "spec", "selector", "matchLabels")
+		if found {
+			selector = &metav1.LabelSelector{MatchLabels: matchLabels}
+		}
+	}
+	if selector == nil {
+		return 0, nil
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return 0, err
+	}
+	var pods corev1.PodList
+	if err := c.List(ctx, &pods, client.InNamespace(obj.GetNamespace()), client.MatchingLabelsSelector{Selector: labelSelector}); err != nil {
+		return 0, err
+	}
+	restarts := int32(0)
+	for _, pod := range pods.Items {
+		if pod.Annotations[key] != version {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts += status.RestartCount
+		}
+	}
+	return restarts, nil
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsV1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+//waitForConsumers waits for the index of the manager cache to list count
+//workloads referencing the configMap
+func waitForConsumers(ctx context.Context, namespace string, configMap string, count int) {
+	Eventually(func() ([]configuratorgopaddleiov1alpha1.ConsumerReference, error) {
+		return index.Consumers(ctx, testClient, namespace, index.ConfigMapField, configMap)
+	}).Should(HaveLen(count))
+}
+
+//newDeployment returns a deployment whose pod template references the configMap
+func newDeployment(namespace string, name string, configMap string) *appsV1.Deployment {
+	labels := map[string]string{"app": name}
+	return &appsV1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: appsV1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  name,
+						Image: "nginx",
+						EnvFrom: []corev1.EnvFromSource{{
+							ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: configMap}},
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+var _ = Describe("Consumer readiness", func() {
+	It("waits for a deployment to roll out and make all its replicas available", func() {
+		replicas := int32(2)
+		deployment := &appsV1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Generation: 2},
+			Spec:       appsV1.DeploymentSpec{Replicas: &replicas},
+			Status:     appsV1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+		}
+		ready, err := ConsumerReady(deployment)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeFalse())
+
+		deployment.Status.ObservedGeneration = 2
+		ready, err = ConsumerReady(deployment)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeTrue())
+
+		deployment.Status.AvailableReplicas = 1
+		ready, err = ConsumerReady(deployment)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeFalse())
+	})
+
+	It("waits for a statefulset to update and ready all its replicas", func() {
+		replicas := int32(2)
+		statefulSet := &appsV1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Generation: 2},
+			Spec:       appsV1.StatefulSetSpec{Replicas: &replicas},
+			Status:     appsV1.StatefulSetStatus{ObservedGeneration: 2, UpdatedReplicas: 1, ReadyReplicas: 2, CurrentRevision: "web-1", UpdateRevision: "web-2"},
+		}
+		ready, err := ConsumerReady(statefulSet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeFalse())
+
+		statefulSet.Status.UpdatedReplicas = 2
+		ready, err = ConsumerReady(statefulSet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeTrue())
+
+		By("leaving the pods below the partition on the previous template")
+		partition := int32(1)
+		statefulSet.Spec.UpdateStrategy.RollingUpdate = &appsV1.RollingUpdateStatefulSetStrategy{Partition: &partition}
+		statefulSet.Status.UpdatedReplicas = 1
+		ready, err = ConsumerReady(statefulSet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeTrue())
+	})
+
+	It("only waits for an OnDelete statefulset to observe its template and ready its replicas", func() {
+		replicas := int32(2)
+		statefulSet := &appsV1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Generation: 2},
+			Spec: appsV1.StatefulSetSpec{
+				Replicas:       &replicas,
+				UpdateStrategy: appsV1.StatefulSetUpdateStrategy{Type: appsV1.OnDeleteStatefulSetStrategyType},
+			},
+			Status: appsV1.StatefulSetStatus{ObservedGeneration: 1, ReadyReplicas: 2, CurrentRevision: "web-1", UpdateRevision: "web-2"},
+		}
+		ready, err := ConsumerReady(statefulSet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeFalse())
+
+		statefulSet.Status.ObservedGeneration = 2
+		ready, err = ConsumerReady(statefulSet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeTrue())
+	})
+
+	It("fails for a custom workload reporting no observedGeneration", func() {
+		obj := rolloutAdapter.NewObject()
+		obj.SetName("web")
+		obj.SetGeneration(1)
+		_, err := ConsumerReady(obj)
+		Expect(err).To(HaveOccurred())
+
+		Expect(unstructured.SetNestedField(obj.Object, int64(1), "status", "observedGeneration")).To(Succeed())
+		ready, err := ConsumerReady(obj)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeTrue())
+
+		Expect(unstructured.SetNestedField(obj.Object, int64(2), "spec", "replicas")).To(Succeed())
+		Expect(unstructured.SetNestedField(obj.Object, int64(1), "status", "availableReplicas")).To(Succeed())
+		ready, err = ConsumerReady(obj)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ready).To(BeFalse())
+	})
+})
+
+var _ = Describe("Rolling consumers", func() {
+	var (
+		ctx       context.Context
+		namespace string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		namespace = newNamespace(ctx, "consumers")
+	})
+
+	It("pins the version on the pod template of a deployment", func() {
+		Expect(k8sClient.Create(ctx, newDeployment(namespace, "web", "app"))).To(Succeed())
+		Eventually(func() ([]configuratorgopaddleiov1alpha1.ConsumerReference, error) {
+			return RolloutConsumers(ctx, testClient, namespace, index.ConfigMapField, "app")
+		}).Should(Equal([]configuratorgopaddleiov1alpha1.ConsumerReference{{Kind: "Deployment", Name: "web"}}))
+
+		_, err := RollConsumer(ctx, testClient, namespace, configuratorgopaddleiov1alpha1.ConsumerReference{Kind: "Deployment", Name: "web"}, "ccm-app", "v2")
+		Expect(err).NotTo(HaveOccurred())
+
+		var deployment appsV1.Deployment
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "web"}, &deployment)).To(Succeed())
+		Expect(deployment.Spec.Template.Annotations).To(HaveKeyWithValue("ccm-app", "v2"))
+	})
+})
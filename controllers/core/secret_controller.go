@@ -25,8 +25,11 @@ import (
 	"strings"
 
 	customSecretv1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
-	appsV1 "k8s.io/api/apps/v1"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+	"github.com/gopaddle-io/configurator/pkg/selection"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -34,7 +37,10 @@ import (
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // SecretReconciler reconciles a Secret object
@@ -72,11 +78,31 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		} else {
 			slog.Error(err, secretlogname+" Unable to get secret")
 		}
+		if errors.IsNotFound(err) {
+			forgetPendingChange("Secret", req.Namespace, req.Name)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(nil)
 	}
+	//secrets left out by the selection are not versioned
+	if !selection.Secret(&secret) {
+		forgetPendingChange("Secret", req.Namespace, req.Name)
+		return ctrl.Result{}, nil
+	}
 
-	//get currentCCM version from configMap
-	currentCS_Version := secret.Annotations["currentCustomSecretVersion"]
+	//get currentCS version from secret bookkeeping
+	state, err := getConfigState(ctx, r.Client, &secret)
+	if err != nil {
+		log.Error(err, secretlogname+" Unable to get configIndex")
+		return ctrl.Result{}, err
+	}
+	currentCS_Version := state.Version
+	//coalesce edits inside the quiet period into a single revision
+	if currentCS_Version != "" {
+		_, version := newCustomSecret(&secret)
+		if wait := debounce(r.EventRecorder, &secret, currentCS_Version, version); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
 	// check the CCM_version in the configMap if the Version not exist.
 	// it create new version of CCM and add annotation to that configMap
 	if currentCS_Version == "" {
@@ -89,24 +115,31 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		if len(csList.Items) == 0 {
 			cs, version := newCustomSecret(&secret)
 			er := r.Create(ctx, cs)
+			if er == nil {
+				metrics.RevisionCreated(cs.Namespace, "CustomSecret")
+			} else if errors.IsAlreadyExists(er) {
+				//revision with the same content already recorded, make it current
+				existing := &customSecretv1alpha1.CustomSecret{}
+				er = r.Get(ctx, types.NamespacedName{Namespace: cs.Namespace, Name: cs.Name}, existing)
+				if er == nil {
+					er = sameCustomSecretContent(existing, cs)
+				}
+				if er == nil {
+					er = markCurrentCustomSecret(ctx, r.Client, r.EventRecorder, existing)
+				}
+				cs = existing
+			}
 			if er != nil {
 				r.EventRecorder.Eventf(&secret, corev1.EventTypeWarning, "FailedCreateCustomSecret", "Error creating CustomSecret: %v", er.Error())
 				return ctrl.Result{}, er
 			}
+			//the revision the workloads get pinned to has to exist before their pods start
+			if er := MaterializeCustomSecret(ctx, r.Client, r.EventRecorder, cs); er != nil {
+				return ctrl.Result{}, er
+			}
 
 			//updating configMap with versionInfo
-			if len(secret.Annotations) == 0 {
-				annotation := make(map[string]string)
-				annotation["currentCustomSecretVersion"] = version
-				annotation["customSecret-name"] = cs.Name
-				annotation["updateMethod"] = "ignoreWhenShared"
-				secret.Annotations = annotation
-			} else {
-				secret.Annotations["currentCustomSecretVersion"] = version
-				secret.Annotations["customSecret-name"] = cs.Name
-				secret.Annotations["updateMethod"] = "ignoreWhenShared"
-			}
-			errs := r.Update(ctx, &secret)
+			errs := updateConfig(ctx, r.Client, &secret, version, cs.Name)
 			if errs != nil {
 				r.EventRecorder.Eventf(&secret, corev1.EventTypeWarning, "FailedAddingCustomSecretVersion", "Error in adding CustomSecret version: %v", errs.Error())
 				return ctrl.Result{}, errs
@@ -114,14 +147,7 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			r.EventRecorder.Eventf(&secret, corev1.EventTypeNormal, "configMap", "update cs content %v to secret %v", secret.Name, cs.Name)
 		} else {
 			if len(csList.Items) == 1 {
-				secretAnnotation := make(map[string]string)
-				data, _ := json.Marshal(secret.ObjectMeta.Annotations)
-				_ = json.Unmarshal(data, &secretAnnotation)
-				delete(secretAnnotation, "currentCustomSecretVersion")
-				delete(secretAnnotation, "customSecret-name")
-				delete(secretAnnotation, "updateMethod")
-				delete(secretAnnotation, "deployments")
-				delete(secretAnnotation, "statefulsets")
+				secretAnnotation := versionedAnnotations(&secret)
 				//content of configMap and customSecret are not same create newCS and make that as current
 				if len(csList.Items[0].Spec.SecretAnnotations) != 0 {
 					if reflect.DeepEqual(secret.Data, csList.Items[0].Spec.Data) == false || secret.Type != csList.Items[0].Spec.Type || reflect.DeepEqual(secretAnnotation, csList.Items[0].Spec.SecretAnnotations) == false {
@@ -131,19 +157,11 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 						}
 
 					} else {
-						//updating configMap with versionInfo
-						if len(secret.Annotations) == 0 {
-							annotation := make(map[string]string)
-							annotation["currentCustomSecretVersion"] = csList.Items[0].Annotations["customSecretVersion"]
-							annotation["customSecret-name"] = csList.Items[0].Name
-							annotation["updateMethod"] = "ignoreWhenShared"
-							secret.Annotations = annotation
-						} else {
-							secret.Annotations["currentCustomSecretVersion"] = csList.Items[0].Annotations["customSecretVersion"]
-							secret.Annotations["customSecret-name"] = csList.Items[0].Name
-							secret.Annotations["updateMethod"] = "ignoreWhenShared"
+						if errs := MaterializeCustomSecret(ctx, r.Client, r.EventRecorder, &csList.Items[0]); errs != nil {
+							return ctrl.Result{}, errs
 						}
-						errs := r.Update(ctx, &secret)
+						//updating configMap with versionInfo
+						errs := updateConfig(ctx, r.Client, &secret, csList.Items[0].Annotations["customSecretVersion"], csList.Items[0].Name)
 						if errs != nil {
 							r.EventRecorder.Eventf(&secret, corev1.EventTypeWarning, "FailedAddingCustomSecretVersion", "Error in adding CustomSecret version: %v", errs.Error())
 							return ctrl.Result{}, errs
@@ -158,19 +176,11 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 						}
 
 					} else {
-						//updating configMap with versionInfo
-						if len(secret.Annotations) == 0 {
-							annotation := make(map[string]string)
-							annotation["currentCustomSecretVersion"] = csList.Items[0].Annotations["customSecretVersion"]
-							annotation["customSecret-name"] = csList.Items[0].Name
-							annotation["updateMethod"] = "ignoreWhenShared"
-							secret.Annotations = annotation
-						} else {
-							secret.Annotations["currentCustomSecretVersion"] = csList.Items[0].Annotations["customSecretVersion"]
-							secret.Annotations["customSecret-name"] = csList.Items[0].Name
-							secret.Annotations["updateMethod"] = "ignoreWhenShared"
+						if errs := MaterializeCustomSecret(ctx, r.Client, r.EventRecorder, &csList.Items[0]); errs != nil {
+							return ctrl.Result{}, errs
 						}
-						errs := r.Update(ctx, &secret)
+						//updating configMap with versionInfo
+						errs := updateConfig(ctx, r.Client, &secret, csList.Items[0].Annotations["customSecretVersion"], csList.Items[0].Name)
 						if errs != nil {
 							r.EventRecorder.Eventf(&secret, corev1.EventTypeWarning, "FailedAddingCustomSecretVersion", "Error in adding CustomSecret version: %v", errs.Error())
 							return ctrl.Result{}, errs
@@ -183,39 +193,45 @@ func (r *SecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 	} else {
 		// version exist it compare the configMap content with currentCCM
-		er := r.UpdateSecret(ctx, &secret)
+		er := r.UpdateSecret(ctx, &secret, currentCS_Version)
 		if er != nil {
 			r.EventRecorder.Eventf(&secret, corev1.EventTypeNormal, "FailedCreateCustomSecretVersion", "Error in creating CustomSecret: %v", er.Error())
 			return ctrl.Result{}, er
 		}
 	}
+	if IndexBookkeeping() {
+		err = recordConsumers(ctx, r.Client, &secret)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
 	return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Secret{}).
-		Complete(r)
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Secret{}, builder.WithPredicates(selectedConfigs))
+	if IndexBookkeeping() {
+		//keep the consumers in the configIndexes up to date
+		for _, obj := range index.WorkloadObjects() {
+			b = b.Watches(&source.Kind{Type: obj}, handler.EnqueueRequestsFromMapFunc(referencedConfigs(true)))
+		}
+	}
+	return b.Complete(r)
 }
 
 // newSecret creates a new Secret for a CustomSecret resource. It also sets
 // the appropriate OwnerReferences on the resource so handleObject can discover
-// the CustomSecret resource that 'owns' it.
+// the CustomSecret resource that 'owns' it. The version is derived from the data,
+// type and annotations, so the same content always maps to the same customSecret name.
 func newCustomSecret(secret *corev1.Secret) (*customSecretv1alpha1.CustomSecret, string) {
 	labels := map[string]string{
 		"name":    secret.Name,
 		"latest":  "true",
 		"current": "true",
 	}
-	version := RandomSequence(5)
-	name := fmt.Sprintf("%s-%s", secret.Name, version)
-	secretName := NameValidation(name)
-	annotation := map[string]string{
-		"customSecretVersion": version,
-	}
-
 	//coverting stringdata into data
 	var data = make(map[string][]byte)
 	for k, v := range secret.StringData {
@@ -230,16 +246,14 @@ func newCustomSecret(secret *corev1.Secret) (*customSecretv1alpha1.CustomSecret,
 	for k, v := range secret.Data {
 		data[k] = v
 	}
-	secretAnnotation := make(map[string]string)
-	sdata, _ := json.Marshal(secret.Annotations)
-	_ = json.Unmarshal(sdata, &secretAnnotation)
+	secretAnnotation := versionedAnnotations(secret)
 
-	//remove customsecret annotation from version
-	delete(secretAnnotation, "currentCustomSecretVersion")
-	delete(secretAnnotation, "customSecret-name")
-	delete(secretAnnotation, "updateMethod")
-	delete(secretAnnotation, "deployments")
-	delete(secretAnnotation, "statefulsets")
+	version := secretVersion(data, secret.Type, secretAnnotation)
+	name := fmt.Sprintf("%s-%s", secret.Name, version)
+	secretName := NameValidation(name)
+	annotation := map[string]string{
+		"customSecretVersion": version,
+	}
 	cs := &customSecretv1alpha1.CustomSecret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      secretName,
@@ -261,7 +275,7 @@ func newCustomSecret(secret *corev1.Secret) (*customSecretv1alpha1.CustomSecret,
 }
 
 //Update Secret
-func (r *SecretReconciler) UpdateSecret(ctx context.Context, secret *corev1.Secret) error {
+func (r *SecretReconciler) UpdateSecret(ctx context.Context, secret *corev1.Secret, version string) error {
 	//get CCM latest version
 	var secretlogname string = secret.Namespace + "/" + secret.Name
 	var csList customSecretv1alpha1.CustomSecretList
@@ -272,9 +286,9 @@ func (r *SecretReconciler) UpdateSecret(ctx context.Context, secret *corev1.Secr
 	}
 	if len(csList.Items) == 1 {
 		//checking configmap annotation version with currentCCM version
-		if csList.Items[0].Annotations["customSecretVersion"] != secret.Annotations["currentCustomSecretVersion"] {
+		if csList.Items[0].Annotations["customSecretVersion"] != version {
 			//calling copy CCM to CM function
-			er := r.CopyCSToSecret(ctx, secret)
+			er := r.CopyCSToSecret(ctx, secret, version)
 			if er != nil {
 				return er
 			}
@@ -287,14 +301,7 @@ func (r *SecretReconciler) UpdateSecret(ctx context.Context, secret *corev1.Secr
 				return errs
 			}
 		} else {
-			secretAnnotation := make(map[string]string)
-			data, _ := json.Marshal(secret.ObjectMeta.Annotations)
-			_ = json.Unmarshal(data, &secretAnnotation)
-			delete(secretAnnotation, "currentCustomSecretVersion")
-			delete(secretAnnotation, "customSecret-name")
-			delete(secretAnnotation, "updateMethod")
-			delete(secretAnnotation, "deployments")
-			delete(secretAnnotation, "statefulsets")
+			secretAnnotation := versionedAnnotations(secret)
 			//content of configMap and customSecret are not same create newCS and make that as current
 			if len(csList.Items[0].Spec.SecretAnnotations) != 0 {
 				if reflect.DeepEqual(secret.Data, csList.Items[0].Spec.Data) == false || secret.Type != csList.Items[0].Spec.Type || reflect.DeepEqual(secretAnnotation, csList.Items[0].Spec.SecretAnnotations) == false {
@@ -318,13 +325,8 @@ func (r *SecretReconciler) UpdateSecret(ctx context.Context, secret *corev1.Secr
 }
 
 //copyCStoSecret
-func (r *SecretReconciler) CopyCSToSecret(ctx context.Context, secret *corev1.Secret) error {
-	var cs customSecretv1alpha1.CustomSecret
-	csNameNamespace := types.NamespacedName{
-		Namespace: secret.Namespace,
-		Name:      secret.Name + "-" + secret.Annotations["currentCustomSecretVersion"],
-	}
-	er := r.Get(ctx, csNameNamespace, &cs)
+func (r *SecretReconciler) CopyCSToSecret(ctx context.Context, secret *corev1.Secret, version string) error {
+	cs, er := GetCustomSecretByVersion(ctx, r.Client, secret.Namespace, secret.Name, version)
 	if er != nil {
 		r.EventRecorder.Eventf(secret, corev1.EventTypeWarning, "FailedGetCustomSecret", "Error Getting CustomSecret: %v", er.Error())
 		return er
@@ -332,26 +334,16 @@ func (r *SecretReconciler) CopyCSToSecret(ctx context.Context, secret *corev1.Se
 
 	//copying content
 	secret.Data = cs.Spec.Data
-	cs.Spec.SecretAnnotations["deployments"] = secret.Annotations["deployments"]
-	cs.Spec.SecretAnnotations["statefulsets"] = secret.Annotations["statefulsets"]
-	cs.Spec.SecretAnnotations["updateMethod"] = secret.Annotations["updateMethod"]
-	cs.Spec.SecretAnnotations["currentCustomSecretVersion"] = secret.Annotations["currentCustomSecretVersion"]
-	cs.Spec.SecretAnnotations["customSecret-name"] = cs.Name
-	secret.Annotations = cs.Spec.SecretAnnotations
+	secret.Annotations = restoredAnnotations(cs, secret)
 	//Update configMap content based on configmap version
-	err := r.Update(ctx, secret)
+	err := updateConfig(ctx, r.Client, secret, version, cs.Name)
 	if err != nil {
 		return err
 	}
 
 	//update CCM with current
 	cs.Labels["current"] = "true"
-	delete(cs.Spec.SecretAnnotations, "currentCustomSecretVersion")
-	delete(cs.Spec.SecretAnnotations, "customSecret-name")
-	delete(cs.Spec.SecretAnnotations, "updateMethod")
-	delete(cs.Spec.SecretAnnotations, "deployments")
-	delete(cs.Spec.SecretAnnotations, "statefulsets")
-	errs := r.Update(ctx, &cs)
+	errs := r.Update(ctx, cs)
 	if errs != nil {
 		r.EventRecorder.Eventf(secret, corev1.EventTypeWarning, "FailedUpdatingCustomSecret", "Error Updating CustomSecret: %v", errs)
 		return errs
@@ -362,6 +354,24 @@ func (r *SecretReconciler) CopyCSToSecret(ctx context.Context, secret *corev1.Se
 }
 
 func (r *SecretReconciler) CreateNewCS(ctx context.Context, secret *corev1.Secret, currentcs *customSecretv1alpha1.CustomSecret) error {
+	csNew, version := newCustomSecret(secret)
+	if version == currentcs.Annotations["customSecretVersion"] {
+		return nil
+	}
+
+	//check the content was already recorded by an earlier revision
+	reuse := true
+	existingcs, er := GetCustomSecretByVersion(ctx, r.Client, secret.Namespace, secret.Name, version)
+	if er != nil {
+		if !errors.IsNotFound(er) {
+			return er
+		}
+		reuse = false
+	} else if er = sameCustomSecretContent(existingcs, csNew); er != nil {
+		r.EventRecorder.Eventf(secret, corev1.EventTypeWarning, "FailedCreateCustomSecret", "Error creating CustomSecret: %v", er)
+		return er
+	}
+
 	//delete current label from previous currentCCM
 	delete(currentcs.Labels, "current")
 	errs := r.Update(ctx, currentcs)
@@ -378,6 +388,9 @@ func (r *SecretReconciler) CreateNewCS(ctx context.Context, secret *corev1.Secre
 		if err != nil {
 			r.EventRecorder.Eventf(secret, corev1.EventTypeWarning, "FailedUpdatingCustomSecret", "Error Updating CustomSecret in getting latest label level:: %v", errs)
 		}
+		if len(latestcs.Items) == 0 {
+			break
+		}
 		delete(latestcs.Items[0].Labels, "latest")
 		errs = r.Update(ctx, &latestcs.Items[0])
 		if errs != nil {
@@ -398,8 +411,16 @@ func (r *SecretReconciler) CreateNewCS(ctx context.Context, secret *corev1.Secre
 		}
 	}
 
-	csNew, version := newCustomSecret(secret)
-	er := r.Create(ctx, csNew)
+	if reuse {
+		//make the earlier revision current and latest again instead of creating a duplicate
+		er = r.Get(ctx, types.NamespacedName{Namespace: existingcs.Namespace, Name: existingcs.Name}, existingcs)
+		if er == nil {
+			er = markCurrentCustomSecret(ctx, r.Client, r.EventRecorder, existingcs)
+		}
+		csNew = existingcs
+	} else {
+		er = r.Create(ctx, csNew)
+	}
 	if er != nil {
 		r.EventRecorder.Eventf(secret, corev1.EventTypeWarning, "FailedCreateCustomSecret", "Error creating CustomSecret: %v", er)
 		//reverting the previous change
@@ -409,82 +430,259 @@ func (r *SecretReconciler) CreateNewCS(ctx context.Context, secret *corev1.Secre
 			r.EventRecorder.Eventf(currentcs, corev1.EventTypeWarning, "FailedUpdatingCustomSecret", "Error Updating CustomSecret: %v", errs)
 			return errs
 		}
-		latestcs.Items[0].Labels["latest"] = "true"
-		errs = r.Update(ctx, &latestcs.Items[0])
-		if errs != nil {
-			r.EventRecorder.Eventf(secret, corev1.EventTypeWarning, "FailedUpdatingCustomSecret", "Error Updating CustomSecret: %v", errs)
-			return errs
+		if len(latestcs.Items) != 0 {
+			latestcs.Items[0].Labels["latest"] = "true"
+			errs = r.Update(ctx, &latestcs.Items[0])
+			if errs != nil {
+				r.EventRecorder.Eventf(secret, corev1.EventTypeWarning, "FailedUpdatingCustomSecret", "Error Updating CustomSecret: %v", errs)
+				return errs
+			}
 		}
 		return er
 	}
+	if reuse {
+		r.EventRecorder.Eventf(secret, corev1.EventTypeNormal, "reuseCustomSecret", "content matches cs %v, making it current again", csNew.Name)
+	} else {
+		metrics.RevisionCreated(csNew.Namespace, "CustomSecret")
+	}
 
 	//update config map with version and ccm name
-	secret.Annotations["currentCustomSecretVersion"] = version
-	secret.Annotations["customSecret-name"] = csNew.Name
-	secret.Annotations["updateMethod"] = "ignoreWhenShared"
-	err := r.Update(ctx, secret)
+	err := updateConfig(ctx, r.Client, secret, version, csNew.Name)
 	if err != nil {
 		return err
 	}
 	r.EventRecorder.Eventf(secret, corev1.EventTypeNormal, "updateSecret", "update cs version %v and name %v", version, csNew.Name)
 
-	//trigger rolling Update for kind=deployment
-	if secret.Annotations["deployments"] != "" {
-		annotation := secret.Annotations["deployments"]
-		split := strings.Split(annotation, ",")
-		if secret.Annotations["updateMethod"] == "ignoreWhenShared" {
-			if len(split) > 1 {
-				klog.Error("can't trigger rolling update updateMethod is ignoreWhenShared")
-				return errors.NewBadRequest("can't trigger rolling update updateMethod is ignoreWhenShared")
-			} else {
-				var deployment appsV1.Deployment
-				deployNameNamespace := types.NamespacedName{
-					Namespace: secret.Namespace,
-					Name:      split[0],
-				}
-				err := r.Get(ctx, deployNameNamespace, &deployment)
-				if err != nil {
-					klog.Error("Failed on getting deployment '%s' Error: %s", split[0], err.Error)
-					return err
-				}
-				//update new version
-				deployment.Spec.Template.Annotations["cs-"+secret.Name] = version
-				err = r.Update(ctx, &deployment)
-				if err != nil {
-					klog.Error("Failed on updating deployment '%s' Error: %s", split[0], err.Error)
-					return err
-				}
+	//the revision the workloads are rolled to has to exist before their pods start
+	if err := MaterializeCustomSecret(ctx, r.Client, r.EventRecorder, csNew); err != nil {
+		return err
+	}
+	return RolloutSecret(ctx, r.Client, r.EventRecorder, secret, version, currentcs.Annotations["customSecretVersion"])
+}
+
+//restoredAnnotations returns the annotations of the revision for the secret, keeping
+//the updateMethod, the autoRollback policy and the debounce the user set on the secret
+func restoredAnnotations(cs *customSecretv1alpha1.CustomSecret, secret *corev1.Secret) map[string]string {
+	annotations := make(map[string]string)
+	for k, v := range cs.Spec.SecretAnnotations {
+		annotations[k] = v
+	}
+	for _, key := range []string{"updateMethod", AutoRollbackAnnotation, AutoRollbackMaxRestartsAnnotation, AutoRollbackWindowAnnotation, DebounceAnnotation} {
+		if secret.Annotations[key] != "" {
+			annotations[key] = secret.Annotations[key]
+		}
+	}
+	return annotations
+}
+
+//versionedAnnotations returns the annotations of the secret recorded in its
+//revisions, without the bookkeeping and the settings of the configurator
+func versionedAnnotations(secret *corev1.Secret) map[string]string {
+	annotations := make(map[string]string)
+	for k, v := range secret.Annotations {
+		annotations[k] = v
+	}
+	for _, key := range []string{"currentCustomSecretVersion", "customSecret-name", "updateMethod", AutoRollbackAnnotation, AutoRollbackMaxRestartsAnnotation, AutoRollbackWindowAnnotation, DebounceAnnotation, "deployments", "statefulsets", "daemonsets", "cronjobs"} {
+		delete(annotations, key)
+	}
+	deleteWorkloadAnnotations(annotations)
+	return annotations
+}
+
+//secretVersion returns a stable version for the data, type and annotations of a secret
+func secretVersion(data map[string][]byte, secretType corev1.SecretType, annotations map[string]string) string {
+	content, _ := json.Marshal(struct {
+		Data        map[string][]byte `json:"data,omitempty"`
+		Type        corev1.SecretType `json:"type,omitempty"`
+		Annotations map[string]string `json:"annotations,omitempty"`
+	}{
+		Data:        data,
+		Type:        secretType,
+		Annotations: annotations,
+	})
+	return contentHash(content)
+}
+
+//RolloutSecret starts a ConfigRollout rolling the workloads using the secret to
+//the new version, the way its updateMethod asks for
+func RolloutSecret(ctx context.Context, c client.Client, recorder record.EventRecorder, secret *corev1.Secret, version string, previousVersion string) error {
+	return startRollout(ctx, c, recorder, secret, index.SecretField, version, previousVersion)
+}
+
+//GetCustomSecretByVersion returns the revision of the secret holding the given version
+func GetCustomSecretByVersion(ctx context.Context, c client.Client, namespace string, secretName string, version string) (*customSecretv1alpha1.CustomSecret, error) {
+	var csList customSecretv1alpha1.CustomSecretList
+	err := c.List(ctx, &csList, client.MatchingLabels{"name": secretName}, client.InNamespace(namespace))
+	if err != nil {
+		return nil, err
+	}
+	for i := range csList.Items {
+		if csList.Items[i].Annotations["customSecretVersion"] == version {
+			return &csList.Items[i], nil
+		}
+	}
+	return nil, errors.NewNotFound(customSecretv1alpha1.GroupVersion.WithResource("customsecrets").GroupResource(), NameValidation(secretName+"-"+version))
+}
+
+//sameCustomSecretContent returns an error when the recorded revision holds
+//another data, type or annotations than the revision for the same version
+func sameCustomSecretContent(recorded *customSecretv1alpha1.CustomSecret, cs *customSecretv1alpha1.CustomSecret) error {
+	if equality.Semantic.DeepEqual(recorded.Spec.Data, cs.Spec.Data) && equality.Semantic.DeepEqual(recorded.Spec.StringData, cs.Spec.StringData) &&
+		recorded.Spec.Type == cs.Spec.Type && equality.Semantic.DeepEqual(recorded.Spec.SecretAnnotations, cs.Spec.SecretAnnotations) {
+		return nil
+	}
+	return errors.NewConflict(customSecretv1alpha1.GroupVersion.WithResource("customsecrets").GroupResource(), recorded.Name,
+		fmt.Errorf("version %s of secret %s is recorded with another content", recorded.Annotations["customSecretVersion"], recorded.Spec.SecretName))
+}
+
+//markCurrentCustomSecret labels the revision current and latest, and removes both
+//labels from the other revisions of its secret
+func markCurrentCustomSecret(ctx context.Context, c client.Client, recorder record.EventRecorder, cs *customSecretv1alpha1.CustomSecret) error {
+	var csList customSecretv1alpha1.CustomSecretList
+	err := c.List(ctx, &csList, client.MatchingLabels{"name": cs.Spec.SecretName}, client.InNamespace(cs.Namespace))
+	if err != nil {
+		return err
+	}
+	for i := range csList.Items {
+		revision := &csList.Items[i]
+		if revision.Name == cs.Name || (revision.Labels["current"] == "" && revision.Labels["latest"] == "") {
+			continue
+		}
+		delete(revision.Labels, "current")
+		delete(revision.Labels, "latest")
+		err = c.Update(ctx, revision)
+		if err != nil {
+			recorder.Eventf(revision, corev1.EventTypeWarning, "FailedUpdatingCustomSecret", "Error Updating CustomSecret in removing current label: %v", err)
+			return err
+		}
+	}
+
+	if cs.Labels == nil {
+		cs.Labels = make(map[string]string)
+	}
+	cs.Labels["current"] = "true"
+	cs.Labels["latest"] = "true"
+	delete(cs.Labels, "desired")
+	err = c.Update(ctx, cs)
+	if err != nil {
+		recorder.Eventf(cs, corev1.EventTypeWarning, "FailedUpdatingCustomSecret", "Error Updating CustomSecret: %v", err)
+		return err
+	}
+	return nil
+}
+
+//AdoptCustomSecret records a customSecret authored directly by a user as a
+//revision of its secret. The revision stays staged (desired=false) until it is
+//marked desired=true, unless it was created with the desired label already set.
+//A customSecret duplicating the content of a recorded revision is folded into
+//that revision and deleted, it reports true then.
+func AdoptCustomSecret(ctx context.Context, c client.Client, recorder record.EventRecorder, cs *customSecretv1alpha1.CustomSecret) (bool, error) {
+	version := secretVersion(cs.Spec.Data, cs.Spec.Type, cs.Spec.SecretAnnotations)
+	existing, err := GetCustomSecretByVersion(ctx, c, cs.Namespace, cs.Spec.SecretName, version)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if existing != nil && existing.Name != cs.Name {
+		//the content is recorded already, a second revision of it would make the
+		//version ambiguous
+		if cs.Labels["desired"] == "true" && existing.Labels["current"] != "true" && existing.Labels["desired"] != "true" {
+			existing.Labels["desired"] = "true"
+			if err := c.Update(ctx, existing); err != nil {
+				return false, err
 			}
 		}
+		if err := c.Delete(ctx, cs); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+		recorder.Eventf(existing, corev1.EventTypeNormal, "duplicateCustomSecret", "cs %v holding the same content removed", cs.Name)
+		return true, nil
 	}
-	if secret.Annotations["statefulsets"] != "" {
-		annotation := secret.Annotations["statefulsets"]
-		split := strings.Split(annotation, ",")
-		if secret.Annotations["updateMethod"] == "ignoreWhenShared" {
-			if len(split) > 1 {
-				klog.Error("can't trigger rolling update updateMethod is ignoreWhenShared")
-				return errors.NewBadRequest("can't trigger rolling update updateMethod is ignoreWhenShared")
-			} else {
-				var sts appsV1.StatefulSet
-				stsNameNamespace := types.NamespacedName{
-					Namespace: secret.Namespace,
-					Name:      split[0],
-				}
 
-				err := r.Get(ctx, stsNameNamespace, &sts)
-				if err != nil {
-					klog.Error("Failed on getting statefulset '%s' Error: %s", split[0], err.Error)
-					return err
-				}
-				//update new version
-				sts.Spec.Template.Annotations["cs-"+secret.Name] = version
-				err = r.Update(ctx, &sts)
-				if err != nil {
-					klog.Error("Failed on updating statefulset '%s' Error: %s", split[0], err.Error)
-					return err
-				}
+	if cs.Labels == nil {
+		cs.Labels = make(map[string]string)
+	}
+	if cs.Annotations == nil {
+		cs.Annotations = make(map[string]string)
+	}
+	cs.Labels["name"] = cs.Spec.SecretName
+	if cs.Labels["desired"] == "" {
+		cs.Labels["desired"] = "false"
+	}
+	cs.Annotations["customSecretVersion"] = version
+
+	//owned by the secret like the revisions recorded by the SecretReconciler
+	if len(cs.OwnerReferences) == 0 {
+		var secret corev1.Secret
+		err := c.Get(ctx, types.NamespacedName{Namespace: cs.Namespace, Name: cs.Spec.SecretName}, &secret)
+		if err == nil {
+			cs.OwnerReferences = []metav1.OwnerReference{
+				*metav1.NewControllerRef(&secret, corev1.SchemeGroupVersion.WithKind("Secret")),
 			}
+		} else if !errors.IsNotFound(err) {
+			return false, err
 		}
 	}
-	return nil
+
+	err = c.Update(ctx, cs)
+	if err != nil {
+		recorder.Eventf(cs, corev1.EventTypeWarning, "FailedUpdatingCustomSecret", "Error adopting CustomSecret: %v", err)
+		return false, err
+	}
+	recorder.Eventf(cs, corev1.EventTypeNormal, "adoptCustomSecret", "recorded cs %v as version %v of secret %v", cs.Name, version, cs.Spec.SecretName)
+	return false, nil
+}
+
+//ApplyCustomSecret makes the revision current, copies its data and annotations
+//to the secret named by Spec.SecretName and rolls it out to the workloads.
+//The type of a secret is immutable, so a revision with a different type is not applied.
+func ApplyCustomSecret(ctx context.Context, c client.Client, recorder record.EventRecorder, cs *customSecretv1alpha1.CustomSecret) error {
+	previousVersion, err := currentRevisionVersion(ctx, c, cs.Namespace, cs.Spec.SecretName, &customSecretv1alpha1.CustomSecretList{})
+	if err != nil {
+		return err
+	}
+	secret, err := RestoreCustomSecret(ctx, c, recorder, cs)
+	if err != nil {
+		if errors.IsBadRequest(err) {
+			//retrying can't change the type, the event tells the user why
+			return nil
+		}
+		return err
+	}
+	if err := MaterializeCustomSecret(ctx, c, recorder, cs); err != nil {
+		return err
+	}
+	return RolloutSecret(ctx, c, recorder, secret, cs.Annotations["customSecretVersion"], previousVersion)
+}
+
+//RestoreCustomSecret makes the revision current and copies its data and annotations
+//to the secret named by Spec.SecretName without rolling the workloads
+func RestoreCustomSecret(ctx context.Context, c client.Client, recorder record.EventRecorder, cs *customSecretv1alpha1.CustomSecret) (*corev1.Secret, error) {
+	var secret corev1.Secret
+	err := c.Get(ctx, types.NamespacedName{Namespace: cs.Namespace, Name: cs.Spec.SecretName}, &secret)
+	if err != nil {
+		recorder.Eventf(cs, corev1.EventTypeWarning, "FailedGetSecret", "Error Getting Secret %v: %v", cs.Spec.SecretName, err)
+		return nil, err
+	}
+	if cs.Spec.Type != "" && secret.Type != cs.Spec.Type {
+		klog.Errorf("can't apply customSecret '%s/%s' type %s to secret of type %s", cs.Namespace, cs.Name, cs.Spec.Type, secret.Type)
+		recorder.Eventf(cs, corev1.EventTypeWarning, "FailedApplyCustomSecret", "secret %v has type %v, type %v can't be applied as secret type is immutable", secret.Name, secret.Type, cs.Spec.Type)
+		return nil, errors.NewBadRequest(fmt.Sprintf("secret %s has type %s, type %s can't be applied as secret type is immutable", secret.Name, secret.Type, cs.Spec.Type))
+	}
+	version := cs.Annotations["customSecretVersion"]
+	if err := markCurrentCustomSecret(ctx, c, recorder, cs); err != nil {
+		return nil, err
+	}
+
+	//copying content
+	secret.Data = cs.Spec.Data
+	secret.StringData = nil
+	secret.Annotations = restoredAnnotations(cs, &secret)
+	err = updateConfig(ctx, c, &secret, version, cs.Name)
+	if err != nil {
+		recorder.Eventf(cs, corev1.EventTypeWarning, "FailedUpdatingSecret", "Error Updating Secret %v: %v", secret.Name, err)
+		return nil, err
+	}
+	recorder.Eventf(&secret, corev1.EventTypeNormal, "applyCustomSecret", "applied cs %v version %v to secret %v", cs.Name, version, secret.Name)
+
+	return &secret, nil
 }
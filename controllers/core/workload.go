@@ -0,0 +1,30 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"github.com/gopaddle-io/configurator/pkg/workload"
+)
+
+//deleteWorkloadAnnotations removes the custom workload lists earlier releases
+//recorded in the annotations of a secret before they are compared with or stored
+//in a customSecret
+func deleteWorkloadAnnotations(annotations map[string]string) {
+	for _, adapter := range workload.Adapters() {
+		delete(annotations, adapter.ConsumerAnnotation())
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+//newRollout returns a custom workload whose pod template references the configMap
+func newRollout(namespace string, name string, configMap string) *unstructured.Unstructured {
+	obj := rolloutAdapter.NewObject()
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	template := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{"app": name},
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{
+					"name":  name,
+					"image": "nginx",
+					"envFrom": []interface{}{
+						map[string]interface{}{"configMapRef": map[string]interface{}{"name": configMap}},
+					},
+				},
+			},
+		},
+	}
+	Expect(unstructured.SetNestedField(obj.Object, template, "spec", "template")).To(Succeed())
+	return obj
+}
+
+var _ = Describe("Workload adapters", func() {
+	var (
+		ctx       context.Context
+		namespace string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		namespace = newNamespace(ctx, "adapters")
+	})
+
+	It("reads the pod template of a custom workload", func() {
+		obj := newRollout(namespace, "web", "app")
+		template, err := rolloutAdapter.PodTemplate(obj.Object)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(template.Spec.Containers).To(HaveLen(1))
+		Expect(template.Spec.Containers[0].EnvFrom[0].ConfigMapRef.Name).To(Equal("app"))
+		Expect(rolloutAdapter.AnnotationsPatchPath()).To(Equal("/spec/template/metadata/annotations"))
+	})
+
+	It("rolls a custom workload through its adapter", func() {
+		Expect(k8sClient.Create(ctx, newRollout(namespace, "web", "app"))).To(Succeed())
+		consumer := configuratorgopaddleiov1alpha1.ConsumerReference{Kind: "Rollout", Name: "web"}
+		Eventually(func() ([]configuratorgopaddleiov1alpha1.ConsumerReference, error) {
+			return RolloutConsumers(ctx, testClient, namespace, index.ConfigMapField, "app")
+		}).Should(Equal([]configuratorgopaddleiov1alpha1.ConsumerReference{consumer}))
+
+		_, err := RollConsumer(ctx, testClient, namespace, consumer, "ccm-app", "v2")
+		Expect(err).NotTo(HaveOccurred())
+
+		rolled := rolloutAdapter.NewObject()
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "web"}, rolled)).To(Succeed())
+		Expect(rolloutAdapter.TemplateAnnotations(rolled.Object)).To(HaveKeyWithValue("ccm-app", "v2"))
+	})
+})
@@ -17,8 +17,12 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -30,10 +34,17 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
 	configuratorgopaddleiocontrollers "github.com/gopaddle-io/configurator/controllers/configurator.gopaddle.io"
 	corecontrollers "github.com/gopaddle-io/configurator/controllers/core"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+	"github.com/gopaddle-io/configurator/pkg/revision"
+	"github.com/gopaddle-io/configurator/pkg/selection"
+	"github.com/gopaddle-io/configurator/pkg/workload"
+	"github.com/gopaddle-io/configurator/webhooks"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -47,14 +58,43 @@ func init() {
 
 	utilruntime.Must(configuratorgopaddleiov1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
+
+	//served next to the controller-runtime metrics on the metrics endpoint
+	metrics.Register(crmetrics.Registry)
 }
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var workloadConfig string
+	var selectionConfig string
+	var bookkeeping string
+	var progressDeadline time.Duration
+	var debounce time.Duration
+	var immutableRevisions bool
+	var purgeSchedule string
+	var retentionPolicyNamespace string
+	var enableWebhooks bool
+	var webhookCertDir string
+	var rotator webhooks.CertRotator
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&workloadConfig, "workload-config", "", "The file describing the custom workload resources to annotate, roll and purge.")
+	flag.StringVar(&selectionConfig, "selection-config", "", "The file selecting the configMaps and secrets to version, the system namespaces, service account tokens and helm releases are left out when not set.")
+	flag.StringVar(&bookkeeping, "bookkeeping", corecontrollers.BookkeepingAnnotations, "Where the current revision of configMaps and secrets is kept, annotations on them or index for ConfigIndex objects.")
+	flag.DurationVar(&progressDeadline, "progress-deadline", corecontrollers.ProgressDeadline, "How long a workload may take to become available before a ConfigRollout halts.")
+	flag.DurationVar(&debounce, "debounce", 0, "How long a configMap or secret has to stay unchanged before its changes are recorded as a single revision, 0 records every change.")
+	flag.BoolVar(&immutableRevisions, "immutable-revisions", false, "Write every revision as an immutable configMap or secret named after it and point the pod templates at it.")
+	flag.StringVar(&purgeSchedule, "purge-schedule", configuratorgopaddleiocontrollers.DefaultPurgeSchedule, "The cron schedule on which unused customConfigMaps and customSecrets are purged.")
+	flag.StringVar(&retentionPolicyNamespace, "retention-policy-namespace", "", "The namespace whose default RevisionRetentionPolicy applies to the namespaces without one, the namespace of the controller when not set.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the admission webhooks from the manager, with a self-signed certificate it rotates before expiry.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir", "", "The directory the webhook server reads tls.crt and tls.key from.")
+	flag.StringVar(&rotator.Service, "webhook-service", "", "The service the webhook certificate is generated for.")
+	flag.StringVar(&rotator.Namespace, "webhook-namespace", "", "The namespace of the webhook service and the webhook certificate secret.")
+	flag.StringVar(&rotator.SecretName, "webhook-cert-secret", "configurator-webhook-cert", "The secret holding the webhook CA and certificate shared by the replicas.")
+	flag.StringVar(&rotator.WebhookConfiguration, "webhook-configuration", "", "The mutating and validating webhook configurations whose caBundle is patched with the webhook CA.")
+	flag.DurationVar(&rotator.RotateBefore, "webhook-cert-rotate-before", webhooks.DefaultRotateBefore, "How long before expiry the webhook certificate is rotated.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -66,13 +106,42 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	//trigger a purge job
-	configuratorgopaddleiocontrollers.PurgeJob()
+	if retentionPolicyNamespace == "" {
+		retentionPolicyNamespace = controllerNamespace()
+	}
+
+	if workloadConfig != "" {
+		config, err := workload.LoadConfig(workloadConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to load workload config", "file", workloadConfig)
+			os.Exit(1)
+		}
+		workload.Register(config.Workloads...)
+	}
+
+	if selectionConfig != "" {
+		config, err := selection.LoadConfig(selectionConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to load selection config", "file", selectionConfig)
+			os.Exit(1)
+		}
+		selection.Set(config)
+	}
+
+	if err := corecontrollers.SetBookkeeping(bookkeeping); err != nil {
+		setupLog.Error(err, "invalid bookkeeping")
+		os.Exit(1)
+	}
+
+	corecontrollers.ProgressDeadline = progressDeadline
+	corecontrollers.DebouncePeriod = debounce
+	revision.SetImmutable(immutableRevisions)
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
+		CertDir:                webhookCertDir,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "adddb861.configurator.gopaddle.io",
@@ -82,9 +151,52 @@ func main() {
 		os.Exit(1)
 	}
 
+	//index the workloads by the configMaps and secrets they reference
+	if err := index.Setup(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to set up consumer index")
+		os.Exit(1)
+	}
+
+	//purge unused revisions on the leader, the last run is served on the metrics endpoint
+	purger := &configuratorgopaddleiocontrollers.Purger{
+		Client:          mgr.GetClient(),
+		Schedule:        purgeSchedule,
+		PolicyNamespace: retentionPolicyNamespace,
+		EventRecorder:   mgr.GetEventRecorderFor("Purger"),
+	}
+	if err := mgr.Add(purger); err != nil {
+		setupLog.Error(err, "unable to set up purge")
+		os.Exit(1)
+	}
+	if err := mgr.AddMetricsExtraHandler("/purge", purger); err != nil {
+		setupLog.Error(err, "unable to serve purge result")
+		os.Exit(1)
+	}
+
+	if enableWebhooks {
+		if rotator.Service == "" || rotator.Namespace == "" {
+			setupLog.Error(nil, "--webhook-service and --webhook-namespace are required to serve the webhooks")
+			os.Exit(1)
+		}
+		webhooks.SetupWithManager(mgr)
+		//the certificate has to be in place before the webhook server starts
+		rotator.Client = mgr.GetClient()
+		rotator.Reader = mgr.GetAPIReader()
+		rotator.CertDir = mgr.GetWebhookServer().CertDir
+		if err := rotator.Ensure(context.Background()); err != nil {
+			setupLog.Error(err, "unable to set up webhook certificate")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&rotator); err != nil {
+			setupLog.Error(err, "unable to set up webhook certificate rotation")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&configuratorgopaddleiocontrollers.CustomConfigMapReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("CustomConfigMapReconciler"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CustomConfigMap")
 		os.Exit(1)
@@ -106,12 +218,45 @@ func main() {
 		os.Exit(1)
 	}
 	if err = (&configuratorgopaddleiocontrollers.CustomSecretReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("CustomSecretReconciler"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CustomSecret")
 		os.Exit(1)
 	}
+	if err = (&configuratorgopaddleiocontrollers.ConfigRollbackReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("ConfigRollbackReconciler"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ConfigRollback")
+		os.Exit(1)
+	}
+	if err = (&configuratorgopaddleiocontrollers.ConfigRolloutReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("ConfigRolloutReconciler"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ConfigRollout")
+		os.Exit(1)
+	}
+	if err = (&corecontrollers.ReplicaSetReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("ReplicaSetReconciler"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ReplicaSet")
+		os.Exit(1)
+	}
+	if err = (&corecontrollers.ControllerRevisionReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		EventRecorder: mgr.GetEventRecorderFor("ControllerRevisionReconciler"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ControllerRevision")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -129,3 +274,13 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+//controllerNamespace returns the namespace the controller runs in, read from its
+//service account, or an empty string out of a cluster
+func controllerNamespace() string {
+	namespace, err := ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(namespace))
+}
@@ -27,6 +27,10 @@ type ConfiguratorV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	CustomConfigMapsGetter
 	CustomSecretsGetter
+	RevisionRetentionPoliciesGetter
+	ConfigRolloutsGetter
+	ConfigIndexesGetter
+	ConfigRollbacksGetter
 }
 
 // ConfiguratorV1alpha1Client is used to interact with features provided by the configurator.gopaddle.io group.
@@ -42,6 +46,22 @@ func (c *ConfiguratorV1alpha1Client) CustomSecrets(namespace string) CustomSecre
 	return newCustomSecrets(c, namespace)
 }
 
+func (c *ConfiguratorV1alpha1Client) ConfigRollbacks(namespace string) ConfigRollbackInterface {
+	return newConfigRollbacks(c, namespace)
+}
+
+func (c *ConfiguratorV1alpha1Client) ConfigIndexes(namespace string) ConfigIndexInterface {
+	return newConfigIndexes(c, namespace)
+}
+
+func (c *ConfiguratorV1alpha1Client) ConfigRollouts(namespace string) ConfigRolloutInterface {
+	return newConfigRollouts(c, namespace)
+}
+
+func (c *ConfiguratorV1alpha1Client) RevisionRetentionPolicies(namespace string) RevisionRetentionPolicyInterface {
+	return newRevisionRetentionPolicies(c, namespace)
+}
+
 // NewForConfig creates a new ConfiguratorV1alpha1Client for the given config.
 func NewForConfig(c *rest.Config) (*ConfiguratorV1alpha1Client, error) {
 	config := *c
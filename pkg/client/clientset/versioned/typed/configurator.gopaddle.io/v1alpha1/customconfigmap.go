@@ -39,6 +39,7 @@ type CustomConfigMapsGetter interface {
 type CustomConfigMapInterface interface {
 	Create(ctx context.Context, customConfigMap *v1alpha1.CustomConfigMap, opts v1.CreateOptions) (*v1alpha1.CustomConfigMap, error)
 	Update(ctx context.Context, customConfigMap *v1alpha1.CustomConfigMap, opts v1.UpdateOptions) (*v1alpha1.CustomConfigMap, error)
+	UpdateStatus(ctx context.Context, customConfigMap *v1alpha1.CustomConfigMap, opts v1.UpdateOptions) (*v1alpha1.CustomConfigMap, error)
 	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
 	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.CustomConfigMap, error)
@@ -134,6 +135,22 @@ func (c *customConfigMaps) Update(ctx context.Context, customConfigMap *v1alpha1
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *customConfigMaps) UpdateStatus(ctx context.Context, customConfigMap *v1alpha1.CustomConfigMap, opts v1.UpdateOptions) (result *v1alpha1.CustomConfigMap, err error) {
+	result = &v1alpha1.CustomConfigMap{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("customconfigmaps").
+		Name(customConfigMap.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(customConfigMap).
+		Do(ctx).
+		Into(result)
+	return
+}
+
 // Delete takes name of the customConfigMap and deletes it. Returns an error if one occurs.
 func (c *customConfigMaps) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -39,6 +39,7 @@ type CustomSecretsGetter interface {
 type CustomSecretInterface interface {
 	Create(ctx context.Context, customSecret *v1alpha1.CustomSecret, opts v1.CreateOptions) (*v1alpha1.CustomSecret, error)
 	Update(ctx context.Context, customSecret *v1alpha1.CustomSecret, opts v1.UpdateOptions) (*v1alpha1.CustomSecret, error)
+	UpdateStatus(ctx context.Context, customSecret *v1alpha1.CustomSecret, opts v1.UpdateOptions) (*v1alpha1.CustomSecret, error)
 	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
 	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.CustomSecret, error)
@@ -134,6 +135,22 @@ func (c *customSecrets) Update(ctx context.Context, customSecret *v1alpha1.Custo
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *customSecrets) UpdateStatus(ctx context.Context, customSecret *v1alpha1.CustomSecret, opts v1.UpdateOptions) (result *v1alpha1.CustomSecret, err error) {
+	result = &v1alpha1.CustomSecret{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("customsecrets").
+		Name(customSecret.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(customSecret).
+		Do(ctx).
+		Into(result)
+	return
+}
+
 // Delete takes name of the customSecret and deletes it. Returns an error if one occurs.
 func (c *customSecrets) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -35,6 +35,22 @@ func (c *FakeConfiguratorV1alpha1) CustomSecrets(namespace string) v1alpha1.Cust
 	return &FakeCustomSecrets{c, namespace}
 }
 
+func (c *FakeConfiguratorV1alpha1) ConfigRollbacks(namespace string) v1alpha1.ConfigRollbackInterface {
+	return &FakeConfigRollbacks{c, namespace}
+}
+
+func (c *FakeConfiguratorV1alpha1) ConfigIndexes(namespace string) v1alpha1.ConfigIndexInterface {
+	return &FakeConfigIndexes{c, namespace}
+}
+
+func (c *FakeConfiguratorV1alpha1) ConfigRollouts(namespace string) v1alpha1.ConfigRolloutInterface {
+	return &FakeConfigRollouts{c, namespace}
+}
+
+func (c *FakeConfiguratorV1alpha1) RevisionRetentionPolicies(namespace string) v1alpha1.RevisionRetentionPolicyInterface {
+	return &FakeRevisionRetentionPolicies{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeConfiguratorV1alpha1) RESTClient() rest.Interface {
@@ -101,6 +101,18 @@ func (c *FakeCustomConfigMaps) Update(ctx context.Context, customConfigMap *v1al
 	return obj.(*v1alpha1.CustomConfigMap), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeCustomConfigMaps) UpdateStatus(ctx context.Context, customConfigMap *v1alpha1.CustomConfigMap, opts v1.UpdateOptions) (*v1alpha1.CustomConfigMap, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(customconfigmapsResource, "status", c.ns, customConfigMap), &v1alpha1.CustomConfigMap{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.CustomConfigMap), err
+}
+
 // Delete takes name of the customConfigMap and deletes it. Returns an error if one occurs.
 func (c *FakeCustomConfigMaps) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	_, err := c.Fake.
@@ -101,6 +101,18 @@ func (c *FakeCustomSecrets) Update(ctx context.Context, customSecret *v1alpha1.C
 	return obj.(*v1alpha1.CustomSecret), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeCustomSecrets) UpdateStatus(ctx context.Context, customSecret *v1alpha1.CustomSecret, opts v1.UpdateOptions) (*v1alpha1.CustomSecret, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(customsecretsResource, "status", c.ns, customSecret), &v1alpha1.CustomSecret{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.CustomSecret), err
+}
+
 // Delete takes name of the customSecret and deletes it. Returns an error if one occurs.
 func (c *FakeCustomSecrets) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	_, err := c.Fake.
@@ -20,3 +20,11 @@ package v1alpha1
 type CustomConfigMapExpansion interface{}
 
 type CustomSecretExpansion interface{}
+
+type ConfigRollbackExpansion interface{}
+
+type ConfigIndexExpansion interface{}
+
+type ConfigRolloutExpansion interface{}
+
+type RevisionRetentionPolicyExpansion interface{}
@@ -0,0 +1,185 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package index keeps the configMap and secret to workload index in the
+//informer cache of the manager, so that the consumers of a configMap or secret
+//are looked up from the pod templates instead of annotations on the configMap
+//or secret itself.
+package index
+
+import (
+	"context"
+	"sort"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	"github.com/gopaddle-io/configurator/pkg/podtemplate"
+	"github.com/gopaddle-io/configurator/pkg/workload"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	batchV1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	//ConfigMapField indexes workloads by the configMaps their pod template references
+	ConfigMapField = "configurator.gopaddle.io/configmaps"
+	//SecretField indexes workloads by the secrets their pod template references
+	SecretField = "configurator.gopaddle.io/secrets"
+)
+
+//Setup registers the configMap and secret fields on every workload kind
+func Setup(ctx context.Context, indexer client.FieldIndexer) error {
+	for _, obj := range WorkloadObjects() {
+		if err := indexer.IndexField(ctx, obj, ConfigMapField, func(obj client.Object) []string {
+			configMaps, _ := podtemplate.References(PodTemplate(obj))
+			return configMaps
+		}); err != nil {
+			return err
+		}
+		if err := indexer.IndexField(ctx, obj, SecretField, func(obj client.Object) []string {
+			_, secrets := podtemplate.References(PodTemplate(obj))
+			return secrets
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ListConsumers returns the workloads in the namespace whose pod template
+//references the configMap (ConfigMapField) or the secret (SecretField) name
+func ListConsumers(ctx context.Context, c client.Reader, namespace string, field string, name string) ([]client.Object, error) {
+	consumers := []client.Object{}
+	for _, list := range workloadLists() {
+		if err := c.List(ctx, list, client.InNamespace(namespace), client.MatchingFields{field: name}); err != nil {
+			return nil, err
+		}
+		switch items := list.(type) {
+		case *appsV1.DeploymentList:
+			for i := range items.Items {
+				consumers = append(consumers, &items.Items[i])
+			}
+		case *appsV1.StatefulSetList:
+			for i := range items.Items {
+				consumers = append(consumers, &items.Items[i])
+			}
+		case *appsV1.DaemonSetList:
+			for i := range items.Items {
+				consumers = append(consumers, &items.Items[i])
+			}
+		case *batchV1beta1.CronJobList:
+			for i := range items.Items {
+				consumers = append(consumers, &items.Items[i])
+			}
+		case *batchV1.JobList:
+			for i := range items.Items {
+				consumers = append(consumers, &items.Items[i])
+			}
+		case *unstructured.UnstructuredList:
+			for i := range items.Items {
+				consumers = append(consumers, &items.Items[i])
+			}
+		}
+	}
+	return consumers, nil
+}
+
+//Consumers returns the references of the workloads in the namespace whose pod
+//template references the configMap or secret name, sorted by kind and name
+func Consumers(ctx context.Context, c client.Reader, namespace string, field string, name string) ([]configuratorgopaddleiov1alpha1.ConsumerReference, error) {
+	objs, err := ListConsumers(ctx, c, namespace, field, name)
+	if err != nil {
+		return nil, err
+	}
+	consumers := []configuratorgopaddleiov1alpha1.ConsumerReference{}
+	for _, obj := range objs {
+		consumers = append(consumers, Reference(obj))
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		if consumers[i].Kind != consumers[j].Kind {
+			return consumers[i].Kind < consumers[j].Kind
+		}
+		return consumers[i].Name < consumers[j].Name
+	})
+	return consumers, nil
+}
+
+//Reference returns the consumer reference of a workload
+func Reference(obj client.Object) configuratorgopaddleiov1alpha1.ConsumerReference {
+	kind := ""
+	switch w := obj.(type) {
+	case *appsV1.Deployment:
+		kind = "Deployment"
+	case *appsV1.StatefulSet:
+		kind = "StatefulSet"
+	case *appsV1.DaemonSet:
+		kind = "DaemonSet"
+	case *batchV1beta1.CronJob:
+		kind = "CronJob"
+	case *batchV1.Job:
+		kind = "Job"
+	case *unstructured.Unstructured:
+		kind = w.GetKind()
+	}
+	return configuratorgopaddleiov1alpha1.ConsumerReference{Kind: kind, Name: obj.GetName()}
+}
+
+//PodTemplate returns the pod template of a workload
+func PodTemplate(obj client.Object) *corev1.PodTemplateSpec {
+	switch w := obj.(type) {
+	case *appsV1.Deployment:
+		return &w.Spec.Template
+	case *appsV1.StatefulSet:
+		return &w.Spec.Template
+	case *appsV1.DaemonSet:
+		return &w.Spec.Template
+	case *batchV1beta1.CronJob:
+		return &w.Spec.JobTemplate.Spec.Template
+	case *batchV1.Job:
+		return &w.Spec.Template
+	case *unstructured.Unstructured:
+		if adapter, ok := workload.ForGroupVersionKind(w.GroupVersionKind()); ok {
+			if template, err := adapter.PodTemplate(w.Object); err == nil {
+				return template
+			}
+		}
+	}
+	return &corev1.PodTemplateSpec{}
+}
+
+//References returns the configMaps and secrets referenced by the pod template of a workload
+func References(obj client.Object) (configMaps []string, secrets []string) {
+	return podtemplate.References(PodTemplate(obj))
+}
+
+//WorkloadObjects returns an empty object of every indexed workload kind
+func WorkloadObjects() []client.Object {
+	objs := []client.Object{&appsV1.Deployment{}, &appsV1.StatefulSet{}, &appsV1.DaemonSet{}, &batchV1beta1.CronJob{}, &batchV1.Job{}}
+	for _, adapter := range workload.Adapters() {
+		objs = append(objs, adapter.NewObject())
+	}
+	return objs
+}
+
+func workloadLists() []client.ObjectList {
+	lists := []client.ObjectList{&appsV1.DeploymentList{}, &appsV1.StatefulSetList{}, &appsV1.DaemonSetList{}, &batchV1beta1.CronJobList{}, &batchV1.JobList{}}
+	for _, adapter := range workload.Adapters() {
+		lists = append(lists, adapter.NewList())
+	}
+	return lists
+}
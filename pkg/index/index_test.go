@@ -0,0 +1,148 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package index
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	configuratorgopaddleiov1alpha1 "github.com/gopaddle-io/configurator/apis/configurator.gopaddle.io/v1alpha1"
+	"github.com/gopaddle-io/configurator/pkg/workload"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	batchV1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//recordingIndexer keeps the index functions registered for each type and field
+type recordingIndexer map[string]client.IndexerFunc
+
+func (r recordingIndexer) IndexField(ctx context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	r[fmt.Sprintf("%T/%s", obj, field)] = extractValue
+	return nil
+}
+
+//testTemplate references configMaps a, b and c and secrets s and t, a twice
+func testTemplate() corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{Name: "a", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "a"}}}},
+				{Name: "projected", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+					{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "b"}}},
+					{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "s"}}},
+				}}}},
+			},
+			Containers: []corev1.Container{{
+				Name:    "app",
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "a"}}}},
+				Env: []corev1.EnvVar{{Name: "KEY", ValueFrom: &corev1.EnvVarSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "c"}, Key: "key"},
+				}}},
+			}},
+			InitContainers: []corev1.Container{{
+				Name:    "init",
+				EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "t"}}}},
+			}},
+		},
+	}
+}
+
+func TestSetupIndexesEveryReference(t *testing.T) {
+	indexer := recordingIndexer{}
+	if err := Setup(context.Background(), indexer); err != nil {
+		t.Fatal(err)
+	}
+	deployment := &appsV1.Deployment{Spec: appsV1.DeploymentSpec{Template: testTemplate()}}
+
+	configMaps := indexer["*v1.Deployment/"+ConfigMapField](deployment)
+	if !reflect.DeepEqual(configMaps, []string{"a", "b", "c"}) {
+		t.Errorf("configMaps = %v, want [a b c]", configMaps)
+	}
+	secrets := indexer["*v1.Deployment/"+SecretField](deployment)
+	if !reflect.DeepEqual(secrets, []string{"s", "t"}) {
+		t.Errorf("secrets = %v, want [s t]", secrets)
+	}
+	for _, key := range []string{"*v1.StatefulSet/", "*v1.DaemonSet/", "*v1beta1.CronJob/", "*v1.Job/"} {
+		if indexer[key+ConfigMapField] == nil || indexer[key+SecretField] == nil {
+			t.Errorf("no index registered for %s", key)
+		}
+	}
+}
+
+func TestReference(t *testing.T) {
+	rollout := &unstructured.Unstructured{}
+	rollout.SetKind("Rollout")
+	tests := []struct {
+		obj  client.Object
+		kind string
+	}{
+		{&appsV1.Deployment{}, "Deployment"},
+		{&appsV1.StatefulSet{}, "StatefulSet"},
+		{&appsV1.DaemonSet{}, "DaemonSet"},
+		{&batchV1beta1.CronJob{}, "CronJob"},
+		{&batchV1.Job{}, "Job"},
+		{rollout, "Rollout"},
+	}
+	for _, test := range tests {
+		test.obj.SetName("web")
+		want := configuratorgopaddleiov1alpha1.ConsumerReference{Kind: test.kind, Name: "web"}
+		if got := Reference(test.obj); got != want {
+			t.Errorf("Reference(%T) = %v, want %v", test.obj, got, want)
+		}
+	}
+}
+
+func TestPodTemplate(t *testing.T) {
+	cronJob := &batchV1beta1.CronJob{}
+	cronJob.Spec.JobTemplate.Spec.Template.ObjectMeta = metav1.ObjectMeta{Annotations: map[string]string{"ccm-a": "v1"}}
+	if got := PodTemplate(cronJob).Annotations["ccm-a"]; got != "v1" {
+		t.Errorf("cronJob template annotation = %q, want v1", got)
+	}
+
+	adapter := workload.Adapter{Group: "example.com", Version: "v1", Kind: "Widget", Resource: "widgets", PodTemplatePath: "spec.template"}
+	workload.Register(adapter)
+	obj := adapter.NewObject()
+	template, err := runtimeTemplate(testTemplate())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unstructured.SetNestedField(obj.Object, template, "spec", "template"); err != nil {
+		t.Fatal(err)
+	}
+	if got := PodTemplate(obj); len(got.Spec.Containers) != 1 || got.Spec.Containers[0].Name != "app" {
+		t.Errorf("widget template = %v, want the containers of spec.template", got.Spec)
+	}
+
+	unknown := &unstructured.Unstructured{}
+	unknown.SetAPIVersion("example.com/v1")
+	unknown.SetKind("Gadget")
+	if got := PodTemplate(unknown); !reflect.DeepEqual(got, &corev1.PodTemplateSpec{}) {
+		t.Errorf("gadget template = %v, want an empty template", got)
+	}
+}
+
+//runtimeTemplate converts a pod template to its unstructured content
+func runtimeTemplate(template corev1.PodTemplateSpec) (map[string]interface{}, error) {
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(&template)
+}
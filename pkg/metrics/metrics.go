@@ -0,0 +1,82 @@
+//Package metrics holds the configurator metrics. The manager registers them with
+//the controller-runtime metrics registry, so that they are served next to the
+//default controller-runtime metrics, and the webhook serves them on its own registry.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+//rollout results
+const (
+	RolloutTriggered = "triggered"
+	RolloutFailed    = "failed"
+	RolloutSkipped   = "skipped"
+)
+
+var (
+	//RevisionsCreated counts the customConfigMaps and customSecrets created
+	RevisionsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "configurator_revisions_created_total",
+		Help: "Number of customConfigMap and customSecret revisions created",
+	}, []string{"namespace", "kind"})
+
+	//Rollouts counts the rollouts triggered, failed or skipped because the
+	//configMap or secret is shared and its updateMethod is ignoreWhenShared
+	Rollouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "configurator_rollouts_total",
+		Help: "Number of rollouts by result",
+	}, []string{"namespace", "kind", "result"})
+
+	//Restores counts the configMaps and secrets ConfigValidation restored to
+	//the version pinned on a pod
+	Restores = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "configurator_restores_total",
+		Help: "Number of configMaps and secrets restored to the version pinned on a pod",
+	}, []string{"namespace", "kind"})
+
+	//RevisionsPurged counts the customConfigMaps and customSecrets purged
+	RevisionsPurged = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "configurator_revisions_purged_total",
+		Help: "Number of customConfigMap and customSecret revisions purged",
+	}, []string{"namespace", "kind"})
+
+	//AdmissionDuration observes the time the webhook takes to admit a request
+	AdmissionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "configurator_admission_duration_seconds",
+		Help:    "Time taken to admit a request by webhook endpoint",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+)
+
+//Register registers the configurator metrics with registerer
+func Register(registerer prometheus.Registerer) {
+	registerer.MustRegister(RevisionsCreated, Rollouts, Restores, RevisionsPurged, AdmissionDuration)
+}
+
+//RevisionCreated records a revision of kind CustomConfigMap or CustomSecret created in namespace
+func RevisionCreated(namespace string, kind string) {
+	RevisionsCreated.WithLabelValues(namespace, kind).Inc()
+}
+
+//Rollout records a rollout of a ConfigMap or Secret with result
+func Rollout(namespace string, kind string, result string) {
+	Rollouts.WithLabelValues(namespace, kind, result).Inc()
+}
+
+//Restored records a ConfigMap or Secret restored by ConfigValidation
+func Restored(namespace string, kind string) {
+	Restores.WithLabelValues(namespace, kind).Inc()
+}
+
+//RevisionPurged records a revision of kind CustomConfigMap or CustomSecret purged from namespace
+func RevisionPurged(namespace string, kind string) {
+	RevisionsPurged.WithLabelValues(namespace, kind).Inc()
+}
+
+//ObserveAdmission records the time an admission request to endpoint took since start
+func ObserveAdmission(endpoint string, start time.Time) {
+	AdmissionDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package podtemplate walks the references of a pod template to configMaps and
+//secrets, pins them with ccm-<name> and cs-<name> annotations and patches the pod
+//template accordingly. It only depends on the kubernetes api types, so that the
+//manager, the standalone webhook and controllerInit share it.
+package podtemplate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gopaddle-io/configurator/pkg/revision"
+	"github.com/gopaddle-io/configurator/pkg/selection"
+	"gomodules.xyz/jsonpatch/v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+//walk calls configMap and secret with the json patch path, relative to the pod
+//template, and the name of every reference of the volumes, the sources of the
+//projected volumes, and the envFrom and the env valueFrom of the containers and
+//initContainers of the pod template
+func walk(template *corev1.PodTemplateSpec, configMap func(path string, name string), secret func(path string, name string)) {
+	for i, volume := range template.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			configMap(fmt.Sprintf("/spec/volumes/%d/configMap/name", i), volume.ConfigMap.Name)
+		} else if volume.Secret != nil {
+			secret(fmt.Sprintf("/spec/volumes/%d/secret/secretName", i), volume.Secret.SecretName)
+		} else if volume.Projected != nil {
+			for j, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					configMap(fmt.Sprintf("/spec/volumes/%d/projected/sources/%d/configMap/name", i, j), source.ConfigMap.Name)
+				} else if source.Secret != nil {
+					secret(fmt.Sprintf("/spec/volumes/%d/projected/sources/%d/secret/name", i, j), source.Secret.Name)
+				}
+			}
+		}
+	}
+	containers := func(field string, containers []corev1.Container) {
+		for i, container := range containers {
+			for j, env := range container.EnvFrom {
+				if env.ConfigMapRef != nil {
+					configMap(fmt.Sprintf("/spec/%s/%d/envFrom/%d/configMapRef/name", field, i, j), env.ConfigMapRef.Name)
+				} else if env.SecretRef != nil {
+					secret(fmt.Sprintf("/spec/%s/%d/envFrom/%d/secretRef/name", field, i, j), env.SecretRef.Name)
+				}
+			}
+			for j, env := range container.Env {
+				if env.ValueFrom == nil {
+					continue
+				}
+				if env.ValueFrom.ConfigMapKeyRef != nil {
+					configMap(fmt.Sprintf("/spec/%s/%d/env/%d/valueFrom/configMapKeyRef/name", field, i, j), env.ValueFrom.ConfigMapKeyRef.Name)
+				} else if env.ValueFrom.SecretKeyRef != nil {
+					secret(fmt.Sprintf("/spec/%s/%d/env/%d/valueFrom/secretKeyRef/name", field, i, j), env.ValueFrom.SecretKeyRef.Name)
+				}
+			}
+		}
+	}
+	containers("containers", template.Spec.Containers)
+	containers("initContainers", template.Spec.InitContainers)
+}
+
+//References returns the configMaps and secrets referenced by the volumes, the
+//sources of the projected volumes, and the envFrom and the env valueFrom of the
+//containers and initContainers of a pod template, each name once. A reference
+//pointed at an immutable revision stands for the configMap or secret of the revision.
+func References(template *corev1.PodTemplateSpec) (configMaps []string, secrets []string) {
+	walk(template, func(path string, name string) {
+		if name = revision.ResolveConfigMap(template.Annotations, name); !contains(configMaps, name) {
+			configMaps = append(configMaps, name)
+		}
+	}, func(path string, name string) {
+		if name = revision.ResolveSecret(template.Annotations, name); !contains(secrets, name) {
+			secrets = append(secrets, name)
+		}
+	})
+	return configMaps, secrets
+}
+
+//VersionFunc returns the current version of the configMap or secret name. It
+//reports false when there is no version to pin: the configMap or secret is left
+//out by the selection or does not exist yet.
+type VersionFunc func(name string) (string, bool, error)
+
+//Annotations returns the annotations of the pod template pinned to the current
+//version of the configMaps and secrets it references. The versions already pinned
+//are kept, and the annotations of the configMaps and secrets no longer referenced
+//are dropped. It also returns the names the references to the configMaps and
+//secrets point at, their immutable revisions when materialized.
+func Annotations(namespace string, template *corev1.PodTemplateSpec, configMapVersion VersionFunc, secretVersion VersionFunc) (annotations map[string]string, configMapTargets map[string]string, secretTargets map[string]string, err error) {
+	configMaps, secrets := References(template)
+	if !selection.Namespace(namespace) {
+		//nothing is versioned in the namespace
+		configMaps, secrets = nil, nil
+	}
+
+	annotations = make(map[string]string)
+	for key, value := range template.Annotations {
+		if strings.HasPrefix(key, "ccm-") && !contains(configMaps, strings.TrimPrefix(key, "ccm-")) {
+			continue
+		}
+		if strings.HasPrefix(key, "cs-") && !contains(secrets, strings.TrimPrefix(key, "cs-")) {
+			continue
+		}
+		if revision.IsAnnotation(key) {
+			continue
+		}
+		annotations[key] = value
+	}
+	for _, name := range configMaps {
+		if annotations["ccm-"+name] != "" {
+			continue
+		}
+		version, ok, err := configMapVersion(name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if ok {
+			annotations["ccm-"+name] = version
+		}
+	}
+	for _, name := range secrets {
+		if annotations["cs-"+name] != "" {
+			continue
+		}
+		version, ok, err := secretVersion(name)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if ok {
+			annotations["cs-"+name] = version
+		}
+	}
+	//it to handle the workload in pod validation
+	annotations["config-sync-controller"] = "configurator"
+
+	configMapTargets, secretTargets, revisionAnnotations := revision.Targets(annotations, configMaps, secrets)
+	for key, value := range revisionAnnotations {
+		annotations[key] = value
+	}
+	return annotations, configMapTargets, secretTargets, nil
+}
+
+//Patch returns the json patch turning the pod template annotations found at path
+//into annotations, and pointing the references of the pod template at the targets
+//of the configMaps and secrets they stand for. The targets map the name of a
+//configMap or secret to the name to reference, its own name or the name of a
+//revision. The references without a target are left as they are.
+func Patch(template *corev1.PodTemplateSpec, path string, annotations map[string]string, configMapTargets map[string]string, secretTargets map[string]string) []jsonpatch.JsonPatchOperation {
+	patch := annotationPatch(template.Annotations, annotations, path)
+	specPath := strings.TrimSuffix(path, "/metadata/annotations")
+	walk(template, func(path string, name string) {
+		if target, ok := configMapTargets[revision.ResolveConfigMap(template.Annotations, name)]; ok && target != name {
+			patch = append(patch, jsonpatch.NewOperation("replace", specPath+path, target))
+		}
+	}, func(path string, name string) {
+		if target, ok := secretTargets[revision.ResolveSecret(template.Annotations, name)]; ok && target != name {
+			patch = append(patch, jsonpatch.NewOperation("replace", specPath+path, target))
+		}
+	})
+	return patch
+}
+
+//annotationPatch returns the json patch turning the annotations found at path
+//from current into desired
+func annotationPatch(current map[string]string, desired map[string]string, path string) []jsonpatch.JsonPatchOperation {
+	if len(current) == 0 {
+		if len(desired) == 0 {
+			return nil
+		}
+		return []jsonpatch.JsonPatchOperation{jsonpatch.NewOperation("add", path, desired)}
+	}
+	var patch []jsonpatch.JsonPatchOperation
+	for key, value := range desired {
+		if current[key] != value {
+			patch = append(patch, jsonpatch.NewOperation("add", path+"/"+escapeKey(key), value))
+		}
+	}
+	for key := range current {
+		if _, ok := desired[key]; !ok {
+			patch = append(patch, jsonpatch.NewOperation("remove", path+"/"+escapeKey(key), nil))
+		}
+	}
+	return patch
+}
+
+//escapeKey escapes an annotation key for a json patch path, kubernetes.io/ingress.class
+//becomes kubernetes.io~1ingress.class
+func escapeKey(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
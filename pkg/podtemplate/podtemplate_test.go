@@ -0,0 +1,202 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtemplate
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gopaddle-io/configurator/pkg/revision"
+	"gomodules.xyz/jsonpatch/v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+//testTemplate returns a pod template referencing configMaps a, b and c and
+//secrets s and t through every kind of reference
+func testTemplate() *corev1.PodTemplateSpec {
+	template := &corev1.PodTemplateSpec{}
+	template.Spec.Volumes = []corev1.Volume{
+		{Name: "a", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "a"}}}},
+		{Name: "s", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "s"}}},
+		{Name: "projected", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+			{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "b"}}},
+			{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "t"}}},
+		}}}},
+	}
+	template.Spec.InitContainers = []corev1.Container{{
+		Name: "init",
+		Env: []corev1.EnvVar{{Name: "KEY", ValueFrom: &corev1.EnvVarSource{
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "c"}, Key: "key"},
+		}}},
+	}}
+	template.Spec.Containers = []corev1.Container{{
+		Name:    "app",
+		EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "a"}}}},
+	}}
+	return template
+}
+
+//versions returns a VersionFunc pinning the names it knows
+func versions(known map[string]string) VersionFunc {
+	return func(name string) (string, bool, error) {
+		version, ok := known[name]
+		return version, ok, nil
+	}
+}
+
+func TestReferences(t *testing.T) {
+	configMaps, secrets := References(testTemplate())
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(configMaps, want) {
+		t.Errorf("configMaps = %v, want %v", configMaps, want)
+	}
+	if want := []string{"s", "t"}; !reflect.DeepEqual(secrets, want) {
+		t.Errorf("secrets = %v, want %v", secrets, want)
+	}
+
+	template := testTemplate()
+	template.Annotations = map[string]string{revision.ConfigMapAnnotation("a"): "a-v1"}
+	template.Spec.Volumes[0].ConfigMap.Name = "a-v1"
+	configMaps, _ = References(template)
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(configMaps, want) {
+		t.Errorf("configMaps of a template pointed at a revision = %v, want %v", configMaps, want)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	template := testTemplate()
+	template.Annotations = map[string]string{
+		"team":     "web",
+		"ccm-a":    "pinned",
+		"ccm-gone": "v1",
+		"cs-gone":  "v1",
+	}
+	annotations, configMapTargets, secretTargets, err := Annotations("default", template,
+		versions(map[string]string{"a": "va", "b": "vb"}), versions(map[string]string{"s": "vs", "t": "vt"}))
+	if err != nil {
+		t.Fatalf("Annotations: %v", err)
+	}
+	want := map[string]string{
+		"team":                   "web",
+		"ccm-a":                  "pinned",
+		"ccm-b":                  "vb",
+		"cs-s":                   "vs",
+		"cs-t":                   "vt",
+		"config-sync-controller": "configurator",
+	}
+	if !reflect.DeepEqual(annotations, want) {
+		t.Errorf("annotations = %v, want %v", annotations, want)
+	}
+	if want := map[string]string{"a": "a", "b": "b", "c": "c"}; !reflect.DeepEqual(configMapTargets, want) {
+		t.Errorf("configMap targets = %v, want %v", configMapTargets, want)
+	}
+	if want := map[string]string{"s": "s", "t": "t"}; !reflect.DeepEqual(secretTargets, want) {
+		t.Errorf("secret targets = %v, want %v", secretTargets, want)
+	}
+}
+
+func TestAnnotationsError(t *testing.T) {
+	failing := func(name string) (string, bool, error) {
+		return "", false, fmt.Errorf("lookup of %s failed", name)
+	}
+	if _, _, _, err := Annotations("default", testTemplate(), failing, versions(nil)); err == nil {
+		t.Errorf("Annotations did not return the error of the version lookup")
+	}
+}
+
+func TestPatch(t *testing.T) {
+	template := testTemplate()
+	template.Annotations = map[string]string{revision.ConfigMapAnnotation("a"): "a-v1"}
+	template.Spec.Volumes[0].ConfigMap.Name = "a-v1"
+	annotations := map[string]string{"ccm-a": "v2", revision.ConfigMapAnnotation("a"): "a-v2"}
+
+	got := Patch(template, "/spec/template/metadata/annotations", annotations, map[string]string{"a": "a-v2", "b": "b"}, map[string]string{"t": "t-v3"})
+	want := []jsonpatch.JsonPatchOperation{
+		jsonpatch.NewOperation("add", "/spec/template/metadata/annotations/ccm-a", "v2"),
+		jsonpatch.NewOperation("add", "/spec/template/metadata/annotations/"+revision.ConfigMapAnnotation("a"), "a-v2"),
+		jsonpatch.NewOperation("replace", "/spec/template/spec/volumes/0/configMap/name", "a-v2"),
+		jsonpatch.NewOperation("replace", "/spec/template/spec/volumes/2/projected/sources/1/secret/name", "t-v3"),
+		jsonpatch.NewOperation("replace", "/spec/template/spec/containers/0/envFrom/0/configMapRef/name", "a-v2"),
+	}
+	sortPatch(got)
+	sortPatch(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Patch = %v, want %v", got, want)
+	}
+}
+
+func TestAnnotationPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		current map[string]string
+		desired map[string]string
+		want    []jsonpatch.JsonPatchOperation
+	}{
+		{name: "nothing", want: nil},
+		{
+			name:    "no annotations yet",
+			desired: map[string]string{"ccm-a": "v1"},
+			want:    []jsonpatch.JsonPatchOperation{jsonpatch.NewOperation("add", "/metadata/annotations", map[string]string{"ccm-a": "v1"})},
+		},
+		{
+			name:    "changed and dropped",
+			current: map[string]string{"ccm-a": "v1", "kubernetes.io/change-cause": "edit"},
+			desired: map[string]string{"ccm-a": "v2"},
+			want: []jsonpatch.JsonPatchOperation{
+				jsonpatch.NewOperation("add", "/metadata/annotations/ccm-a", "v2"),
+				jsonpatch.NewOperation("remove", "/metadata/annotations/kubernetes.io~1change-cause", nil),
+			},
+		},
+		{
+			name:    "unchanged",
+			current: map[string]string{"ccm-a": "v1"},
+			desired: map[string]string{"ccm-a": "v1"},
+			want:    nil,
+		},
+	}
+	for _, test := range tests {
+		got := annotationPatch(test.current, test.desired, "/metadata/annotations")
+		sortPatch(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: annotationPatch = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEscapeKey(t *testing.T) {
+	tests := map[string]string{
+		"ccm-app":                     "ccm-app",
+		"kubernetes.io/ingress.class": "kubernetes.io~1ingress.class",
+		"a~b/c":                       "a~0b~1c",
+	}
+	for key, want := range tests {
+		if got := escapeKey(key); got != want {
+			t.Errorf("escapeKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+//sortPatch orders the operations of a patch built from a map by operation and path
+func sortPatch(patch []jsonpatch.JsonPatchOperation) {
+	sort.Slice(patch, func(i, j int) bool {
+		if patch[i].Operation != patch[j].Operation {
+			return patch[i].Operation < patch[j].Operation
+		}
+		return patch[i].Path < patch[j].Path
+	})
+}
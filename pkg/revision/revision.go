@@ -0,0 +1,120 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package revision names the immutable configMaps and secrets every revision is
+//materialized as, and the targets the references of a pod template point at, so
+//that every pod sees exactly the revision pinned on its template.
+package revision
+
+import (
+	"regexp"
+	"strings"
+)
+
+//Label is set on the configMaps and secrets materializing a revision, its value is
+//the name of the configMap or secret. They are never versioned themselves.
+const Label = "revisionOf"
+
+const (
+	configMapAnnotationPrefix = "ccmRevision-"
+	secretAnnotationPrefix    = "csRevision-"
+)
+
+var immutable = false
+
+//SetImmutable turns materializing the revisions as immutable configMaps and
+//secrets, and pointing the pod templates at them, on or off
+func SetImmutable(enabled bool) {
+	immutable = enabled
+}
+
+//Immutable reports whether the pod templates are pointed at the immutable
+//configMaps and secrets of their revisions
+func Immutable() bool {
+	return immutable
+}
+
+var invalidName = regexp.MustCompile("[^a-z0-9-]+")
+
+//Name returns the name of the revision holding version of the configMap or secret
+//name, the name of its customConfigMap or customSecret and of the immutable
+//configMap or secret materializing it
+func Name(name string, version string) string {
+	return invalidName.ReplaceAllString(name+"-"+version, "s")
+}
+
+//ConfigMapAnnotation is the pod template annotation recording the revision the
+//references to the configMap name point at
+func ConfigMapAnnotation(name string) string {
+	return configMapAnnotationPrefix + name
+}
+
+//SecretAnnotation is the pod template annotation recording the revision the
+//references to the secret name point at
+func SecretAnnotation(name string) string {
+	return secretAnnotationPrefix + name
+}
+
+//IsAnnotation reports whether key is a ConfigMapAnnotation or a SecretAnnotation
+func IsAnnotation(key string) bool {
+	return strings.HasPrefix(key, configMapAnnotationPrefix) || strings.HasPrefix(key, secretAnnotationPrefix)
+}
+
+//ResolveConfigMap returns the configMap a reference of the pod template stands
+//for, the name of the reference unless it points at a revision
+func ResolveConfigMap(annotations map[string]string, name string) string {
+	return resolve(annotations, configMapAnnotationPrefix, name)
+}
+
+//ResolveSecret returns the secret a reference of the pod template stands for, the
+//name of the reference unless it points at a revision
+func ResolveSecret(annotations map[string]string, name string) string {
+	return resolve(annotations, secretAnnotationPrefix, name)
+}
+
+func resolve(annotations map[string]string, prefix string, name string) string {
+	for key, value := range annotations {
+		if value == name && strings.HasPrefix(key, prefix) {
+			return strings.TrimPrefix(key, prefix)
+		}
+	}
+	return name
+}
+
+//Targets returns the names the references to the configMaps and secrets pinned in
+//the annotations point at, and the ConfigMapAnnotation and SecretAnnotation
+//recording them. Without immutable revisions the references point back at the
+//configMaps and secrets themselves.
+func Targets(pinned map[string]string, configMaps []string, secrets []string) (configMapTargets map[string]string, secretTargets map[string]string, annotations map[string]string) {
+	configMapTargets = make(map[string]string)
+	secretTargets = make(map[string]string)
+	annotations = make(map[string]string)
+	for _, name := range configMaps {
+		configMapTargets[name] = name
+		if version := pinned["ccm-"+name]; immutable && version != "" {
+			configMapTargets[name] = Name(name, version)
+			annotations[ConfigMapAnnotation(name)] = configMapTargets[name]
+		}
+	}
+	for _, name := range secrets {
+		secretTargets[name] = name
+		if version := pinned["cs-"+name]; immutable && version != "" {
+			secretTargets[name] = Name(name, version)
+			annotations[SecretAnnotation(name)] = secretTargets[name]
+		}
+	}
+	return configMapTargets, secretTargets, annotations
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package revision
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    string
+	}{
+		{"app", "v1", "app-v1"},
+		{"app.conf", "3f2a", "appsconf-3f2a"},
+		{"app", "V_1", "app-s1"},
+	}
+	for _, test := range tests {
+		if got := Name(test.name, test.version); got != test.want {
+			t.Errorf("Name(%q, %q) = %q, want %q", test.name, test.version, got, test.want)
+		}
+	}
+}
+
+func TestIsAnnotation(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{ConfigMapAnnotation("app"), true},
+		{SecretAnnotation("app"), true},
+		{"ccm-app", false},
+		{"cs-app", false},
+	}
+	for _, test := range tests {
+		if got := IsAnnotation(test.key); got != test.want {
+			t.Errorf("IsAnnotation(%q) = %v, want %v", test.key, got, test.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	annotations := map[string]string{
+		ConfigMapAnnotation("app"): "app-v1",
+		SecretAnnotation("creds"):  "creds-v2",
+	}
+	if got := ResolveConfigMap(annotations, "app-v1"); got != "app" {
+		t.Errorf("ResolveConfigMap(app-v1) = %q, want app", got)
+	}
+	if got := ResolveConfigMap(annotations, "other"); got != "other" {
+		t.Errorf("ResolveConfigMap(other) = %q, want other", got)
+	}
+	if got := ResolveConfigMap(annotations, "creds-v2"); got != "creds-v2" {
+		t.Errorf("ResolveConfigMap(creds-v2) = %q, want the secret revision left alone", got)
+	}
+	if got := ResolveSecret(annotations, "creds-v2"); got != "creds" {
+		t.Errorf("ResolveSecret(creds-v2) = %q, want creds", got)
+	}
+}
+
+func TestTargets(t *testing.T) {
+	pinned := map[string]string{"ccm-app": "v1", "cs-creds": "v2"}
+	tests := []struct {
+		immutable       bool
+		wantConfigMaps  map[string]string
+		wantSecrets     map[string]string
+		wantAnnotations map[string]string
+	}{
+		{
+			immutable:       false,
+			wantConfigMaps:  map[string]string{"app": "app", "unpinned": "unpinned"},
+			wantSecrets:     map[string]string{"creds": "creds"},
+			wantAnnotations: map[string]string{},
+		},
+		{
+			immutable:      true,
+			wantConfigMaps: map[string]string{"app": "app-v1", "unpinned": "unpinned"},
+			wantSecrets:    map[string]string{"creds": "creds-v2"},
+			wantAnnotations: map[string]string{
+				ConfigMapAnnotation("app"): "app-v1",
+				SecretAnnotation("creds"):  "creds-v2",
+			},
+		},
+	}
+	defer SetImmutable(false)
+	for _, test := range tests {
+		SetImmutable(test.immutable)
+		configMaps, secrets, annotations := Targets(pinned, []string{"app", "unpinned"}, []string{"creds"})
+		if !reflect.DeepEqual(configMaps, test.wantConfigMaps) {
+			t.Errorf("immutable %v: configMap targets = %v, want %v", test.immutable, configMaps, test.wantConfigMaps)
+		}
+		if !reflect.DeepEqual(secrets, test.wantSecrets) {
+			t.Errorf("immutable %v: secret targets = %v, want %v", test.immutable, secrets, test.wantSecrets)
+		}
+		if !reflect.DeepEqual(annotations, test.wantAnnotations) {
+			t.Errorf("immutable %v: annotations = %v, want %v", test.immutable, annotations, test.wantAnnotations)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package selection decides which configMaps and secrets configurator versions,
+//so that the reconcilers, the webhook, the purge job and controllerInit leave
+//the same configMaps and secrets alone.
+package selection
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/gopaddle-io/configurator/pkg/revision"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+//Config is the content of the selection config file
+type Config struct {
+	//IncludeNamespaces versions only the configMaps and secrets of these namespaces,
+	//every namespace when empty
+	IncludeNamespaces []string `json:"includeNamespaces,omitempty"`
+	//ExcludeNamespaces never versions the configMaps and secrets of these namespaces
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+	//ExcludeNames never versions the configMaps and secrets with these names, like
+	//the kube-root-ca.crt configMap of every namespace
+	ExcludeNames []string `json:"excludeNames,omitempty"`
+	//Selector opts in, when set only the configMaps and secrets whose labels match
+	//it are versioned
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	//DenySecretTypes never versions the secrets of these types
+	DenySecretTypes []corev1.SecretType `json:"denySecretTypes,omitempty"`
+	//IgnoreDefaults keeps only the exclusions of the file. Otherwise the system
+	//namespaces, names and secret types of Default are excluded as well.
+	IgnoreDefaults bool `json:"ignoreDefaults,omitempty"`
+
+	selector labels.Selector
+}
+
+var config = Default()
+
+//Default returns the selection used without a config file, leaving out the
+//system namespaces, the kube-root-ca.crt configMap, service account tokens and
+//helm releases
+func Default() *Config {
+	return &Config{
+		ExcludeNamespaces: []string{"kube-system", "kube-public", "kube-node-lease"},
+		ExcludeNames:      []string{"kube-root-ca.crt"},
+		DenySecretTypes:   []corev1.SecretType{corev1.SecretTypeServiceAccountToken, "helm.sh/release.v1"},
+		selector:          labels.Everything(),
+	}
+}
+
+//LoadConfig reads and validates the selection config file. The exclusions of
+//Default are added to the ones of the file unless it sets ignoreDefaults.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if !c.IgnoreDefaults {
+		defaults := Default()
+		for _, namespace := range defaults.ExcludeNamespaces {
+			if !contains(c.ExcludeNamespaces, namespace) {
+				c.ExcludeNamespaces = append(c.ExcludeNamespaces, namespace)
+			}
+		}
+		for _, name := range defaults.ExcludeNames {
+			if !contains(c.ExcludeNames, name) {
+				c.ExcludeNames = append(c.ExcludeNames, name)
+			}
+		}
+		for _, secretType := range defaults.DenySecretTypes {
+			if !containsType(c.DenySecretTypes, secretType) {
+				c.DenySecretTypes = append(c.DenySecretTypes, secretType)
+			}
+		}
+	}
+	c.selector = labels.Everything()
+	if c.Selector != nil {
+		c.selector, err = metav1.LabelSelectorAsSelector(c.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("selector: %v", err)
+		}
+	}
+	return c, nil
+}
+
+//Set makes the selection known to the reconcilers, the webhook, the purge job and controllerInit
+func Set(c *Config) {
+	config = c
+}
+
+//Namespace reports whether the configMaps and secrets of the namespace may be versioned
+func Namespace(namespace string) bool {
+	if len(config.IncludeNamespaces) != 0 && !contains(config.IncludeNamespaces, namespace) {
+		return false
+	}
+	return !contains(config.ExcludeNamespaces, namespace)
+}
+
+//ConfigMap reports whether the configMap is versioned. The immutable configMaps
+//and secrets materializing a revision never are.
+func ConfigMap(obj metav1.Object) bool {
+	if _, ok := obj.GetLabels()[revision.Label]; ok {
+		return false
+	}
+	return Namespace(obj.GetNamespace()) && !contains(config.ExcludeNames, obj.GetName()) &&
+		config.selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+//Secret reports whether the secret is versioned
+func Secret(secret *corev1.Secret) bool {
+	for _, secretType := range config.DenySecretTypes {
+		if secret.Type == secretType {
+			return false
+		}
+	}
+	return ConfigMap(secret)
+}
+
+//Object reports whether the configMap or secret is versioned, for watch predicates
+func Object(obj metav1.Object) bool {
+	if secret, ok := obj.(*corev1.Secret); ok {
+		return Secret(secret)
+	}
+	return ConfigMap(obj)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsType(secretTypes []corev1.SecretType, secretType corev1.SecretType) bool {
+	for _, t := range secretTypes {
+		if t == secretType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package selection
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+//writeConfig writes a selection config file and returns its path
+func writeConfig(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "selection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "selection.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func configMap(namespace string, name string, labels map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+}
+
+func TestDefault(t *testing.T) {
+	Set(Default())
+	tests := []struct {
+		obj  metav1.Object
+		want bool
+	}{
+		{configMap("default", "app", nil), true},
+		{configMap("kube-system", "app", nil), false},
+		{configMap("default", "kube-root-ca.crt", nil), false},
+		{&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"}, Type: corev1.SecretTypeOpaque}, true},
+		{&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "token"}, Type: corev1.SecretTypeServiceAccountToken}, false},
+		{&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "release"}, Type: "helm.sh/release.v1"}, false},
+	}
+	for _, test := range tests {
+		if got := Object(test.obj); got != test.want {
+			t.Errorf("Object(%s/%s) = %v, want %v", test.obj.GetNamespace(), test.obj.GetName(), got, test.want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	defer Set(Default())
+	c, err := LoadConfig(writeConfig(t, `
+includeNamespaces: [apps, web]
+excludeNames: [generated]
+selector:
+  matchLabels:
+    configurator: enabled
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Set(c)
+
+	enabled := map[string]string{"configurator": "enabled"}
+	tests := []struct {
+		obj  metav1.Object
+		want bool
+	}{
+		{configMap("apps", "app", enabled), true},
+		{configMap("apps", "app", nil), false},
+		{configMap("default", "app", enabled), false},
+		{configMap("web", "generated", enabled), false},
+	}
+	for _, test := range tests {
+		if got := ConfigMap(test.obj); got != test.want {
+			t.Errorf("ConfigMap(%s/%s %v) = %v, want %v", test.obj.GetNamespace(), test.obj.GetName(), test.obj.GetLabels(), got, test.want)
+		}
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	defer Set(Default())
+	token := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "token"}, Type: corev1.SecretTypeServiceAccountToken}
+	tests := []struct {
+		name    string
+		content string
+		objs    map[metav1.Object]bool
+	}{
+		{
+			name:    "merged",
+			content: "excludeNames: [generated]\n",
+			objs: map[metav1.Object]bool{
+				configMap("default", "generated", nil):        false,
+				configMap("default", "kube-root-ca.crt", nil): false,
+				configMap("kube-system", "app", nil):          false,
+				token:                                         false,
+				configMap("default", "app", nil):              true,
+			},
+		},
+		{
+			name:    "ignored",
+			content: "ignoreDefaults: true\nexcludeNames: [generated]\n",
+			objs: map[metav1.Object]bool{
+				configMap("default", "generated", nil):        false,
+				configMap("default", "kube-root-ca.crt", nil): true,
+				configMap("kube-system", "app", nil):          true,
+				token:                                         true,
+			},
+		},
+	}
+	for _, test := range tests {
+		c, err := LoadConfig(writeConfig(t, test.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		Set(c)
+		for obj, want := range test.objs {
+			if got := Object(obj); got != want {
+				t.Errorf("%s: Object(%s/%s) = %v, want %v", test.name, obj.GetNamespace(), obj.GetName(), got, want)
+			}
+		}
+	}
+}
+
+func TestLoadConfigInvalidSelector(t *testing.T) {
+	_, err := LoadConfig(writeConfig(t, `
+selector:
+  matchExpressions:
+  - key: configurator
+    operator: Sometimes
+`))
+	if err == nil {
+		t.Error("LoadConfig accepted an invalid selector")
+	}
+}
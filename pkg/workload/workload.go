@@ -0,0 +1,189 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package workload describes custom workload resources carrying a pod template,
+//so that configurator can annotate, roll and purge them through unstructured
+//objects the way it does for deployments and statefulsets.
+package workload
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	//RevisionHistoryReplicaSet keeps the previous pod templates in owned replicaSets
+	RevisionHistoryReplicaSet = "ReplicaSet"
+	//RevisionHistoryControllerRevision keeps the previous pod templates in owned controllerRevisions
+	RevisionHistoryControllerRevision = "ControllerRevision"
+	//RevisionHistoryNone keeps no previous pod template
+	RevisionHistoryNone = "None"
+)
+
+//Adapter describes a custom workload resource
+type Adapter struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+	//Resource is the plural name of the resource, used by the webhook rules and the purge job
+	Resource string `json:"resource"`
+	//PodTemplatePath is the path of the pod template in the object, e.g. spec.template
+	PodTemplatePath string `json:"podTemplatePath"`
+	//RevisionHistory is where the previous pod templates are kept:
+	//ReplicaSet, ControllerRevision or None
+	RevisionHistory string `json:"revisionHistory,omitempty"`
+}
+
+//Config is the content of the workload config file
+type Config struct {
+	Workloads []Adapter `json:"workloads"`
+}
+
+var adapters []Adapter
+
+//LoadConfig reads and validates the workload config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	for i := range config.Workloads {
+		adapter := &config.Workloads[i]
+		if adapter.Version == "" || adapter.Kind == "" || adapter.Resource == "" || adapter.PodTemplatePath == "" {
+			return nil, fmt.Errorf("workload %d: version, kind, resource and podTemplatePath are required", i)
+		}
+		switch adapter.RevisionHistory {
+		case "":
+			adapter.RevisionHistory = RevisionHistoryNone
+		case RevisionHistoryReplicaSet, RevisionHistoryControllerRevision, RevisionHistoryNone:
+		default:
+			return nil, fmt.Errorf("workload %s: unknown revisionHistory %s, expected ReplicaSet, ControllerRevision or None", adapter.Kind, adapter.RevisionHistory)
+		}
+	}
+	return config, nil
+}
+
+//Register makes the adapters known to the reconcilers, the webhook and the purge job
+func Register(workloads ...Adapter) {
+	adapters = append(adapters, workloads...)
+}
+
+//Adapters returns the registered adapters
+func Adapters() []Adapter {
+	return adapters
+}
+
+//ForGroupVersionKind returns the registered adapter of gvk
+func ForGroupVersionKind(gvk schema.GroupVersionKind) (Adapter, bool) {
+	for _, adapter := range adapters {
+		if adapter.GroupVersionKind() == gvk {
+			return adapter, true
+		}
+	}
+	return Adapter{}, false
+}
+
+func (a Adapter) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: a.Group, Version: a.Version, Kind: a.Kind}
+}
+
+func (a Adapter) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: a.Group, Version: a.Version, Resource: a.Resource}
+}
+
+//ConsumerAnnotation is the annotation in which earlier releases listed the
+//workloads of this kind referencing a configMap or secret, like deployments or
+//statefulsets. Consumers are now looked up from the index, the annotation is
+//only removed from secrets.
+func (a Adapter) ConsumerAnnotation() string {
+	if a.Group == "" {
+		return a.Resource
+	}
+	return a.Resource + "." + a.Group
+}
+
+//NewObject returns an empty object of the adapter's kind
+func (a Adapter) NewObject() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(a.GroupVersionKind())
+	return obj
+}
+
+//NewList returns an empty list of the adapter's kind
+func (a Adapter) NewList() *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(a.GroupVersionKind().GroupVersion().WithKind(a.Kind + "List"))
+	return list
+}
+
+//templateFields splits PodTemplatePath, given either as spec.template or /spec/template
+func (a Adapter) templateFields() []string {
+	return strings.FieldsFunc(a.PodTemplatePath, func(r rune) bool {
+		return r == '.' || r == '/'
+	})
+}
+
+func (a Adapter) annotationFields() []string {
+	return append(a.templateFields(), "metadata", "annotations")
+}
+
+//AnnotationsPatchPath is the json patch path of the pod template annotations
+func (a Adapter) AnnotationsPatchPath() string {
+	return "/" + strings.Join(a.annotationFields(), "/")
+}
+
+//PodTemplate returns the pod template of the object
+func (a Adapter) PodTemplate(obj map[string]interface{}) (*corev1.PodTemplateSpec, error) {
+	content, found, err := unstructured.NestedMap(obj, a.templateFields()...)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s has no pod template at %s", a.Kind, a.PodTemplatePath)
+	}
+	template := &corev1.PodTemplateSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+//TemplateAnnotations returns the pod template annotations of the object
+func (a Adapter) TemplateAnnotations(obj map[string]interface{}) map[string]string {
+	annotations, _, _ := unstructured.NestedStringMap(obj, a.annotationFields()...)
+	return annotations
+}
+
+//SetTemplateAnnotation sets an annotation on the pod template of the object
+func (a Adapter) SetTemplateAnnotation(obj map[string]interface{}, key string, value string) error {
+	annotations := a.TemplateAnnotations(obj)
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[key] = value
+	return unstructured.SetNestedStringMap(obj, annotations, a.annotationFields()...)
+}
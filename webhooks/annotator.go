@@ -0,0 +1,151 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	corecontrollers "github.com/gopaddle-io/configurator/controllers/core"
+	"github.com/gopaddle-io/configurator/pkg/index"
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+	"github.com/gopaddle-io/configurator/pkg/podtemplate"
+	"github.com/gopaddle-io/configurator/pkg/selection"
+	"github.com/gopaddle-io/configurator/pkg/workload"
+	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	batchV1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//templateAnnotationsPath is the json patch path of the pod template annotations
+//of deployments, statefulsets, daemonsets and jobs
+const templateAnnotationsPath = "/spec/template/metadata/annotations"
+
+//jobTemplateAnnotationsPath is the json patch path of the pod template annotations
+//of the jobTemplate of a cronjob
+const jobTemplateAnnotationsPath = "/spec/jobTemplate/spec/template/metadata/annotations"
+
+//WorkloadAnnotator pins the pod template of a workload to the current version of
+//every configMap and secret it references, with a ccm-<name> or cs-<name> annotation
+type WorkloadAnnotator struct {
+	Client client.Reader
+	//Endpoint is the path the annotator is served on
+	Endpoint string
+}
+
+//Handle patches the pod template annotations of the workload in the request
+func (a *WorkloadAnnotator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	defer metrics.ObserveAdmission(a.Endpoint, time.Now())
+
+	obj, path, err := workloadObject(req.Kind)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		klog.Errorf("Could not unmarshal raw object: %v", err)
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	//the namespace isn't always set on the object on create
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(req.Namespace)
+	}
+	klog.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v UID=%v patchOperation=%v DryRun=%v UserInfo=%v",
+		req.Kind, req.Namespace, req.Name, req.UID, req.Operation, dryRun(req.DryRun), req.UserInfo)
+
+	annotations, configMapTargets, secretTargets, err := a.templateAnnotations(ctx, obj)
+	if err != nil {
+		klog.Infof("AdmissionResponse: create patch failed %v", err.Error())
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	patch := podtemplate.Patch(index.PodTemplate(obj), path, annotations, configMapTargets, secretTargets)
+	return admission.Patched("", patch...)
+}
+
+//templateAnnotations returns the pod template annotations of the workload pinned to
+//the current version of the configMaps and secrets it references, and the names
+//the references point at
+func (a *WorkloadAnnotator) templateAnnotations(ctx context.Context, obj client.Object) (map[string]string, map[string]string, map[string]string, error) {
+	namespace := obj.GetNamespace()
+	configMapVersion := func(name string) (string, bool, error) {
+		var configMap corev1.ConfigMap
+		if err := a.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &configMap); err != nil {
+			if errors.IsNotFound(err) {
+				//an optional or not yet created configMap has no version to pin
+				klog.Infof("configMap %s/%s referenced by the pod template not found, not pinning it", namespace, name)
+				return "", false, nil
+			}
+			return "", false, err
+		}
+		if !selection.ConfigMap(&configMap) {
+			return "", false, nil
+		}
+		version, err := corecontrollers.CurrentVersion(ctx, a.Client, &configMap)
+		return version, err == nil, err
+	}
+	secretVersion := func(name string) (string, bool, error) {
+		var secret corev1.Secret
+		if err := a.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+			if errors.IsNotFound(err) {
+				//an optional or not yet created secret has no version to pin
+				klog.Infof("secret %s/%s referenced by the pod template not found, not pinning it", namespace, name)
+				return "", false, nil
+			}
+			return "", false, err
+		}
+		if !selection.Secret(&secret) {
+			return "", false, nil
+		}
+		version, err := corecontrollers.CurrentVersion(ctx, a.Client, &secret)
+		return version, err == nil, err
+	}
+	return podtemplate.Annotations(namespace, index.PodTemplate(obj), configMapVersion, secretVersion)
+}
+
+//workloadObject returns an empty object of the workload kind and the json patch
+//path of its pod template annotations
+func workloadObject(kind metav1.GroupVersionKind) (client.Object, string, error) {
+	gvk := schema.GroupVersionKind{Group: kind.Group, Version: kind.Version, Kind: kind.Kind}
+	switch gvk {
+	case appsV1.SchemeGroupVersion.WithKind("Deployment"):
+		return &appsV1.Deployment{}, templateAnnotationsPath, nil
+	case appsV1.SchemeGroupVersion.WithKind("StatefulSet"):
+		return &appsV1.StatefulSet{}, templateAnnotationsPath, nil
+	case appsV1.SchemeGroupVersion.WithKind("DaemonSet"):
+		return &appsV1.DaemonSet{}, templateAnnotationsPath, nil
+	case batchV1.SchemeGroupVersion.WithKind("Job"):
+		return &batchV1.Job{}, templateAnnotationsPath, nil
+	case batchV1beta1.SchemeGroupVersion.WithKind("CronJob"):
+		return &batchV1beta1.CronJob{}, jobTemplateAnnotationsPath, nil
+	}
+	adapter, ok := workload.ForGroupVersionKind(gvk)
+	if !ok {
+		return nil, "", fmt.Errorf("no workload adapter configured for %s", gvk.String())
+	}
+	return &unstructured.Unstructured{}, adapter.AnnotationsPatchPath(), nil
+}
@@ -0,0 +1,352 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;patch
+//+kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations;validatingwebhookconfigurations,verbs=get;list;watch;patch
+
+const (
+	//DefaultCertValidity is how long the generated CA and serving certificate are valid
+	DefaultCertValidity = 365 * 24 * time.Hour
+	//DefaultRotateBefore is how long before expiry the serving certificate is rotated
+	DefaultRotateBefore = 30 * 24 * time.Hour
+	//DefaultCheckInterval is how often the serving certificate is checked for expiry
+	DefaultCheckInterval = time.Hour
+
+	caCertKey = "ca.crt"
+	certKey   = corev1.TLSCertKey
+	keyKey    = corev1.TLSPrivateKeyKey
+)
+
+//CertRotator keeps a self-signed CA and a serving certificate for the webhook
+//service in a secret shared by the replicas. It writes the certificate to the
+//directory the webhook server reads it from, rotates it before it expires, and
+//patches the caBundle of the webhook configurations with the CA.
+type CertRotator struct {
+	client.Client
+	//Reader reads the secret and the webhook configurations without the cache,
+	//so that the certificate can be ensured before the manager starts
+	Reader client.Reader
+	//Namespace is the namespace of the webhook service and the certificate secret
+	Namespace string
+	//Service is the name of the webhook service the certificate is served for
+	Service string
+	//SecretName is the secret holding the CA and serving certificate
+	SecretName string
+	//WebhookConfiguration is the name of the mutating and validating webhook
+	//configurations whose caBundle is patched
+	WebhookConfiguration string
+	//CertDir is the directory the webhook server reads tls.crt and tls.key from
+	CertDir string
+
+	Validity      time.Duration
+	RotateBefore  time.Duration
+	CheckInterval time.Duration
+}
+
+//NeedLeaderElection runs the rotator on every replica, each one writes the shared
+//certificate to its own certificate directory
+func (r *CertRotator) NeedLeaderElection() bool {
+	return false
+}
+
+//Start checks the certificate on the check interval until the manager stops
+func (r *CertRotator) Start(ctx context.Context) error {
+	interval := r.CheckInterval
+	if interval == 0 {
+		interval = DefaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.Ensure(ctx); err != nil {
+				klog.Errorf("Failed on rotating the webhook certificate: %v", err.Error())
+			}
+		}
+	}
+}
+
+//Ensure generates the certificate when the secret holds none or it is about to
+//expire, writes it to the certificate directory and patches the caBundle
+func (r *CertRotator) Ensure(ctx context.Context) error {
+	secret := &corev1.Secret{}
+	err := r.Reader.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.SecretName}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if errors.IsNotFound(err) || r.needsRotation(secret.Data) {
+		data, err := r.generate(secret.Data)
+		if err != nil {
+			return err
+		}
+		err = r.applySecret(ctx, secret, data)
+		if err == nil || errors.IsConflict(err) {
+			//read the certificate back, another replica may have rotated it first
+			secret = &corev1.Secret{}
+			err = r.Reader.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.SecretName}, secret)
+		}
+		if err != nil {
+			return err
+		}
+		klog.Infof("webhook certificate for %s generated in secret '%s/%s'", r.dnsNames()[0], r.Namespace, r.SecretName)
+	}
+
+	if err := r.writeCertDir(secret.Data); err != nil {
+		return err
+	}
+	return r.patchCABundle(ctx, secret.Data[caCertKey])
+}
+
+//needsRotation reports whether the serving certificate is missing, does not parse
+//or expires within RotateBefore
+func (r *CertRotator) needsRotation(data map[string][]byte) bool {
+	if len(data[caCertKey]) == 0 || len(data[keyKey]) == 0 {
+		return true
+	}
+	block, _ := pem.Decode(data[certKey])
+	if block == nil {
+		return true
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return true
+	}
+	rotateBefore := r.RotateBefore
+	if rotateBefore == 0 {
+		rotateBefore = DefaultRotateBefore
+	}
+	return time.Now().Add(rotateBefore).After(cert.NotAfter)
+}
+
+//generate returns a new CA and a serving certificate signed by it. The previous
+//CA stays in the caBundle, so that the replicas still serving the previous
+//certificate are trusted until they pick up the new one.
+func (r *CertRotator) generate(previous map[string][]byte) (map[string][]byte, error) {
+	validity := r.Validity
+	if validity == 0 {
+		validity = DefaultCertValidity
+	}
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := notBefore.Add(validity)
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{CommonName: r.Service + "-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	dnsNames := r.dnsNames()
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	if block, _ := pem.Decode(previous[caCertKey]); block != nil {
+		if previousCA, err := x509.ParseCertificate(block.Bytes); err == nil && time.Now().Before(previousCA.NotAfter) {
+			caBundle = append(caBundle, pem.EncodeToMemory(block)...)
+		}
+	}
+	return map[string][]byte{
+		caCertKey: caBundle,
+		certKey:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		keyKey:    pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+	}, nil
+}
+
+//dnsNames returns the names the webhook service is reached by
+func (r *CertRotator) dnsNames() []string {
+	return []string{
+		r.Service + "." + r.Namespace + ".svc",
+		r.Service + "." + r.Namespace + ".svc.cluster.local",
+		r.Service + "." + r.Namespace,
+		r.Service,
+	}
+}
+
+//writeCertDir writes the serving certificate to the certificate directory when it
+//changed, the webhook server reloads it on write
+func (r *CertRotator) writeCertDir(data map[string][]byte) error {
+	if err := os.MkdirAll(r.CertDir, 0700); err != nil {
+		return err
+	}
+	for _, key := range []string{keyKey, certKey} {
+		path := filepath.Join(r.CertDir, key)
+		if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, data[key]) {
+			continue
+		}
+		if err := ioutil.WriteFile(path, data[key], 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//applySecret writes the certificate to the secret with a server-side apply, so
+//that configurator only owns the keys it writes. The resourceVersion of the secret
+//read, when it exists, is a precondition of the apply: a replica rotating the
+//certificate concurrently makes it fail with a conflict.
+func (r *CertRotator) applySecret(ctx context.Context, current *corev1.Secret, data map[string][]byte) error {
+	secretType := current.Type
+	if secretType == "" {
+		secretType = corev1.SecretTypeTLS
+	}
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            r.SecretName,
+			Namespace:       r.Namespace,
+			ResourceVersion: current.ResourceVersion,
+		},
+		Type: secretType,
+		Data: data,
+	}
+	return r.Patch(ctx, secret, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+}
+
+//patchCABundle sets the caBundle of every webhook of the mutating and validating
+//webhook configurations, the configurations not installed are skipped
+func (r *CertRotator) patchCABundle(ctx context.Context, caBundle []byte) error {
+	if r.WebhookConfiguration == "" {
+		return nil
+	}
+	mutating := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	err := r.Reader.Get(ctx, types.NamespacedName{Name: r.WebhookConfiguration}, mutating)
+	if err == nil {
+		var names []string
+		changed := false
+		for _, webhook := range mutating.Webhooks {
+			names = append(names, webhook.Name)
+			changed = changed || !bytes.Equal(webhook.ClientConfig.CABundle, caBundle)
+		}
+		if changed {
+			err = r.applyCABundle(ctx, "MutatingWebhookConfiguration", names, caBundle)
+		}
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed on patching caBundle of MutatingWebhookConfiguration %s: %v", r.WebhookConfiguration, err)
+	}
+
+	validating := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	err = r.Reader.Get(ctx, types.NamespacedName{Name: r.WebhookConfiguration}, validating)
+	if err == nil {
+		var names []string
+		changed := false
+		for _, webhook := range validating.Webhooks {
+			names = append(names, webhook.Name)
+			changed = changed || !bytes.Equal(webhook.ClientConfig.CABundle, caBundle)
+		}
+		if changed {
+			err = r.applyCABundle(ctx, "ValidatingWebhookConfiguration", names, caBundle)
+		}
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed on patching caBundle of ValidatingWebhookConfiguration %s: %v", r.WebhookConfiguration, err)
+	}
+	return nil
+}
+
+//applyCABundle sets the caBundle of the named webhooks with a server-side apply.
+//The webhooks are merged by name, so configurator only owns their caBundle and
+//the other fields stay with the manager that installed the configuration.
+func (r *CertRotator) applyCABundle(ctx context.Context, kind string, webhooks []string, caBundle []byte) error {
+	entries := make([]interface{}, 0, len(webhooks))
+	for _, name := range webhooks {
+		entries = append(entries, map[string]interface{}{
+			"name": name,
+			"clientConfig": map[string]interface{}{
+				"caBundle": base64.StdEncoding.EncodeToString(caBundle),
+			},
+		})
+	}
+	configuration := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": admissionregistrationv1.SchemeGroupVersion.String(),
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name": r.WebhookConfiguration,
+		},
+		"webhooks": entries,
+	}}
+	return r.Patch(ctx, configuration, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+}
+
+func serialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return serial
+}
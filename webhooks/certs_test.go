@@ -0,0 +1,141 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testRotator(t *testing.T) *CertRotator {
+	return &CertRotator{Namespace: "configurator", Service: "configurator-webhook", CertDir: t.TempDir()}
+}
+
+//parseCerts returns every certificate of a pem bundle
+func parseCerts(t *testing.T, data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("parsing certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	r := testRotator(t)
+	data, err := r.generate(nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	cas := parseCerts(t, data[caCertKey])
+	if len(cas) != 1 || !cas[0].IsCA {
+		t.Fatalf("caBundle holds %d certificates, want the new CA only", len(cas))
+	}
+	certs := parseCerts(t, data[certKey])
+	if len(certs) != 1 {
+		t.Fatalf("tls.crt holds %d certificates, want 1", len(certs))
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cas[0])
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:   "configurator-webhook.configurator.svc",
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}); err != nil {
+		t.Errorf("serving certificate does not verify against the CA: %v", err)
+	}
+	if block, _ := pem.Decode(data[keyKey]); block == nil {
+		t.Errorf("tls.key does not hold a pem key")
+	}
+}
+
+func TestGenerateKeepsPreviousCA(t *testing.T) {
+	r := testRotator(t)
+	previous, err := r.generate(nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	data, err := r.generate(previous)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	cas := parseCerts(t, data[caCertKey])
+	if len(cas) != 2 {
+		t.Fatalf("caBundle holds %d certificates, want the new and the previous CA", len(cas))
+	}
+	if !bytes.Equal(cas[1].Raw, parseCerts(t, previous[caCertKey])[0].Raw) {
+		t.Errorf("caBundle does not keep the previous CA")
+	}
+}
+
+func TestNeedsRotation(t *testing.T) {
+	r := testRotator(t)
+	data, err := r.generate(nil)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	tests := []struct {
+		name         string
+		data         map[string][]byte
+		rotateBefore time.Duration
+		want         bool
+	}{
+		{name: "missing", data: map[string][]byte{}, want: true},
+		{name: "not pem", data: map[string][]byte{caCertKey: data[caCertKey], keyKey: data[keyKey], certKey: []byte("garbage")}, want: true},
+		{name: "valid", data: data, want: false},
+		{name: "about to expire", data: data, rotateBefore: 2 * DefaultCertValidity, want: true},
+	}
+	for _, test := range tests {
+		r.RotateBefore = test.rotateBefore
+		if got := r.needsRotation(test.data); got != test.want {
+			t.Errorf("%s: needsRotation = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWriteCertDir(t *testing.T) {
+	r := testRotator(t)
+	r.CertDir = filepath.Join(r.CertDir, "certs")
+	data := map[string][]byte{certKey: []byte("cert"), keyKey: []byte("key"), caCertKey: []byte("ca")}
+	if err := r.writeCertDir(data); err != nil {
+		t.Fatalf("writeCertDir: %v", err)
+	}
+	for _, key := range []string{certKey, keyKey} {
+		written, err := ioutil.ReadFile(filepath.Join(r.CertDir, key))
+		if err != nil {
+			t.Fatalf("reading %s: %v", key, err)
+		}
+		if !bytes.Equal(written, data[key]) {
+			t.Errorf("%s = %q, want %q", key, written, data[key])
+		}
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(r.CertDir, caCertKey)); err == nil {
+		t.Errorf("ca.crt written to the certificate directory")
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	corecontrollers "github.com/gopaddle-io/configurator/controllers/core"
+	"github.com/gopaddle-io/configurator/pkg/metrics"
+	"github.com/gopaddle-io/configurator/pkg/podtemplate"
+	"github.com/gopaddle-io/configurator/pkg/selection"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//PodValidator warns when a pod pins another version of a configMap or secret than
+//the one it holds. It has no side effects, the controller restores the pinned
+//version once the replicaSet or controllerRevision of the pod becomes active.
+type PodValidator struct {
+	Client client.Reader
+	//Endpoint is the path the validator is served on
+	Endpoint string
+}
+
+//Handle validates the pod in the request, it is always allowed
+func (v *PodValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	defer metrics.ObserveAdmission(v.Endpoint, time.Now())
+
+	var pod corev1.Pod
+	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+		klog.Errorf("Could not unmarshal raw object: %v", err)
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = req.Namespace
+	}
+	klog.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v UID=%v validateOperation=%v DryRun=%v UserInfo=%v",
+		req.Kind, req.Namespace, req.Name, req.UID, req.Operation, dryRun(req.DryRun), req.UserInfo)
+
+	// it only checks the deployment/statefulset/daemonset/job created pod for the version match
+	if pod.Annotations["config-sync-controller"] != "configurator" || !selection.Namespace(pod.Namespace) {
+		return admission.Allowed("")
+	}
+	var warnings []string
+	configMaps, secrets := podtemplate.References(&corev1.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec})
+	for _, name := range configMaps {
+		if warning := v.checkConfigMap(ctx, &pod, name); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	for _, name := range secrets {
+		if warning := v.checkSecret(ctx, &pod, name); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+//checkConfigMap returns a warning when the configMap holds another version than
+//the one pinned on the pod
+func (v *PodValidator) checkConfigMap(ctx context.Context, pod *corev1.Pod, name string) string {
+	version := pod.Annotations["ccm-"+name]
+	if version == "" {
+		return ""
+	}
+	var configMap corev1.ConfigMap
+	if err := v.Client.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: name}, &configMap); err != nil {
+		klog.Infof("failed on getting configMap %s/%s: %v", pod.Namespace, name, err.Error())
+		return ""
+	}
+	if !selection.ConfigMap(&configMap) {
+		return ""
+	}
+	current, err := corecontrollers.CurrentVersion(ctx, v.Client, &configMap)
+	if err != nil || current == version {
+		return ""
+	}
+	return fmt.Sprintf("pod pins version %s of configMap %s which holds version %s", version, name, current)
+}
+
+//checkSecret returns a warning when the secret holds another version than the
+//one pinned on the pod
+func (v *PodValidator) checkSecret(ctx context.Context, pod *corev1.Pod, name string) string {
+	version := pod.Annotations["cs-"+name]
+	if version == "" {
+		return ""
+	}
+	var secret corev1.Secret
+	if err := v.Client.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: name}, &secret); err != nil {
+		klog.Infof("failed on getting secret %s/%s: %v", pod.Namespace, name, err.Error())
+		return ""
+	}
+	if !selection.Secret(&secret) {
+		return ""
+	}
+	current, err := corecontrollers.CurrentVersion(ctx, v.Client, &secret)
+	if err != nil || current == version {
+		return ""
+	}
+	return fmt.Sprintf("pod pins version %s of secret %s which holds version %s", version, name, current)
+}
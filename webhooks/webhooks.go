@@ -0,0 +1,58 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//Package webhooks serves the admission webhooks of configurator from the webhook
+//server of the manager: the workload annotators pinning pod templates to the
+//current version of their configMaps and secrets, and the pod validator.
+package webhooks
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+//endpoints the workload annotators are served on, the same paths the
+//controllerWebhook binary serves
+var workloadEndpoints = []string{"/deploycontroller", "/stscontroller", "/dscontroller", "/jobcontroller", "/cronjobcontroller", "/workloadcontroller"}
+
+//podEndpoint is the path the pod validator is served on
+const podEndpoint = "/podcontroller"
+
+//FieldManager is the field manager of the writes of the webhooks, so that they
+//only own the fields they set
+const FieldManager = "configurator"
+
+//dryRun reports whether the request is a dry-run, like kubectl apply --dry-run=server
+//or kubectl diff. The handlers only read to compute their response, a dry-run
+//request is answered the same way as any other request without side effects.
+func dryRun(dryRun *bool) bool {
+	return dryRun != nil && *dryRun
+}
+
+//SetupWithManager registers the admission webhooks on the webhook server of the manager
+func SetupWithManager(mgr ctrl.Manager) {
+	server := mgr.GetWebhookServer()
+	for _, endpoint := range workloadEndpoints {
+		server.Register(endpoint, &webhook.Admission{Handler: &WorkloadAnnotator{
+			Client:   mgr.GetClient(),
+			Endpoint: endpoint[1:],
+		}})
+	}
+	server.Register(podEndpoint, &webhook.Admission{Handler: &PodValidator{
+		Client:   mgr.GetClient(),
+		Endpoint: podEndpoint[1:],
+	}})
+}